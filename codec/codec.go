@@ -0,0 +1,144 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package codec encrypts Temporal payloads, so order details aren't stored in
+// plain text in the workflow history.
+package codec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	MetadataEncoding = "binary/encrypted"
+	MetadataCipher   = "AES256-GCM"
+
+	MetadataEncodingKey = converter.MetadataEncoding
+	MetadataCipherKey   = "encryption-cipher"
+	MetadataKeyIDKey    = "encryption-key-id"
+)
+
+// EncryptionCodec encrypts the whole payload, including its metadata, with
+// AES-GCM. The key ID's left in plain text so the right key can decrypt it.
+type EncryptionCodec struct {
+	keys KeyProvider
+}
+
+func NewEncryptionCodec(keys KeyProvider) *EncryptionCodec {
+	return &EncryptionCodec{
+		keys: keys,
+	}
+}
+
+// NewDataConverter encrypts everything the default data converter produces
+func NewDataConverter(keys KeyProvider) converter.DataConverter {
+	return converter.NewCodecDataConverter(converter.GetDefaultDataConverter(), NewEncryptionCodec(keys))
+}
+
+func newGCM(key *Key) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (c *EncryptionCodec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	key, err := c.keys.Current()
+	if err != nil {
+		return payloads, fmt.Errorf("error getting current key: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return payloads, fmt.Errorf("error creating cipher: %w", err)
+	}
+
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		data, err := proto.Marshal(p)
+		if err != nil {
+			return payloads, fmt.Errorf("error marshalling payload: %w", err)
+		}
+
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return payloads, fmt.Errorf("error generating nonce: %w", err)
+		}
+
+		result[i] = &commonpb.Payload{
+			Metadata: map[string][]byte{
+				MetadataEncodingKey: []byte(MetadataEncoding),
+				MetadataCipherKey:   []byte(MetadataCipher),
+				MetadataKeyIDKey:    []byte(key.ID),
+			},
+			// The key ID's authenticated, so it can't be swapped for another key's
+			Data: gcm.Seal(nonce, nonce, data, []byte(key.ID)),
+		}
+	}
+
+	return result, nil
+}
+
+func (c *EncryptionCodec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		// Payloads written before encryption was turned on are left alone
+		if string(p.GetMetadata()[MetadataEncodingKey]) != MetadataEncoding {
+			result[i] = p
+			continue
+		}
+
+		if cipherName := string(p.GetMetadata()[MetadataCipherKey]); cipherName != MetadataCipher {
+			return payloads, fmt.Errorf("unsupported cipher: %s", cipherName)
+		}
+
+		keyID := string(p.GetMetadata()[MetadataKeyIDKey])
+		key, err := c.keys.Get(keyID)
+		if err != nil {
+			return payloads, fmt.Errorf("error getting key: %w", err)
+		}
+
+		gcm, err := newGCM(key)
+		if err != nil {
+			return payloads, fmt.Errorf("error creating cipher: %w", err)
+		}
+
+		if len(p.GetData()) < gcm.NonceSize() {
+			return payloads, fmt.Errorf("encrypted payload is too short")
+		}
+		nonce, ciphertext := p.GetData()[:gcm.NonceSize()], p.GetData()[gcm.NonceSize():]
+
+		data, err := gcm.Open(nil, nonce, ciphertext, []byte(keyID))
+		if err != nil {
+			return payloads, fmt.Errorf("error decrypting payload: %w", err)
+		}
+
+		result[i] = &commonpb.Payload{}
+		if err := proto.Unmarshal(data, result[i]); err != nil {
+			return payloads, fmt.Errorf("error unmarshalling payload: %w", err)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,210 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package codec
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+type testOrder struct {
+	Email    string `json:"email"`
+	PostCode string `json:"postCode"`
+}
+
+var order = testOrder{Email: "test@test.com", PostCode: "DN31 1AA"}
+
+func newSecret(t *testing.T) string {
+	secret := make([]byte, KeySize)
+	_, err := rand.Read(secret)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(secret)
+}
+
+// writeKeys writes a keys file with the given keys
+func writeKeys(t *testing.T, path string, secrets map[string]string, current string) {
+	var file KeysFile
+	file.Current = current
+	for id, secret := range secrets {
+		file.Keys = append(file.Keys, struct {
+			ID     string `json:"id"`
+			Secret string `json:"secret"`
+		}{id, secret})
+	}
+
+	data, err := json.Marshal(file)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func newTestKeys(t *testing.T) (*FileKeyProvider, string, map[string]string) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	secrets := map[string]string{"key-1": newSecret(t)}
+	writeKeys(t, path, secrets, "key-1")
+
+	keys, err := NewFileKeyProvider(path)
+	require.NoError(t, err)
+
+	return keys, path, secrets
+}
+
+func TestDataConverterRoundTrip(t *testing.T) {
+	keys, _, _ := newTestKeys(t)
+	dc := NewDataConverter(keys)
+
+	payload, err := dc.ToPayload(order)
+	require.NoError(t, err)
+
+	assert.Equal(t, MetadataEncoding, string(payload.Metadata[MetadataEncodingKey]))
+	assert.Equal(t, "key-1", string(payload.Metadata[MetadataKeyIDKey]))
+	// Nothing about the order is readable
+	assert.False(t, bytes.Contains(payload.Data, []byte(order.Email)))
+	assert.NotContains(t, payload.Metadata, "json/plain")
+
+	var decoded testOrder
+	require.NoError(t, dc.FromPayload(payload, &decoded))
+	assert.Equal(t, order, decoded)
+}
+
+func TestKeyRotation(t *testing.T) {
+	keys, path, secrets := newTestKeys(t)
+	dc := NewDataConverter(keys)
+
+	old, err := dc.ToPayload(order)
+	require.NoError(t, err)
+
+	// Add a new key and make it current
+	secrets["key-2"] = newSecret(t)
+	writeKeys(t, path, secrets, "key-2")
+	require.NoError(t, keys.Reload())
+
+	rotated, err := dc.ToPayload(order)
+	require.NoError(t, err)
+	assert.Equal(t, "key-2", string(rotated.Metadata[MetadataKeyIDKey]))
+
+	// Both can still be read
+	for _, payload := range []*commonpb.Payload{old, rotated} {
+		var decoded testOrder
+		require.NoError(t, dc.FromPayload(payload, &decoded))
+		assert.Equal(t, order, decoded)
+	}
+
+	// Until the old key's removed
+	delete(secrets, "key-1")
+	writeKeys(t, path, secrets, "key-2")
+	require.NoError(t, keys.Reload())
+
+	var decoded testOrder
+	err = dc.FromPayload(old, &decoded)
+	assert.True(t, errors.Is(err, ErrUnknownKey))
+}
+
+func TestDecodeRejectsTampering(t *testing.T) {
+	keys, path, secrets := newTestKeys(t)
+	codec := NewEncryptionCodec(keys)
+
+	payload, err := converter.GetDefaultDataConverter().ToPayload(order)
+	require.NoError(t, err)
+
+	t.Run("data changed", func(t *testing.T) {
+		encoded, err := codec.Encode([]*commonpb.Payload{payload})
+		require.NoError(t, err)
+
+		encoded[0].Data[len(encoded[0].Data)-1] ^= 1
+		_, err = codec.Decode(encoded)
+		assert.Error(t, err)
+	})
+
+	t.Run("key swapped", func(t *testing.T) {
+		secrets["key-2"] = newSecret(t)
+		writeKeys(t, path, secrets, "key-1")
+		require.NoError(t, keys.Reload())
+
+		encoded, err := codec.Encode([]*commonpb.Payload{payload})
+		require.NoError(t, err)
+
+		encoded[0].Metadata[MetadataKeyIDKey] = []byte("key-2")
+		_, err = codec.Decode(encoded)
+		assert.Error(t, err)
+	})
+}
+
+func TestDecodeLeavesPlainPayloads(t *testing.T) {
+	keys, _, _ := newTestKeys(t)
+
+	// Written before encryption was turned on
+	payload, err := converter.GetDefaultDataConverter().ToPayload(order)
+	require.NoError(t, err)
+
+	decoded, err := NewEncryptionCodec(keys).Decode([]*commonpb.Payload{payload})
+	require.NoError(t, err)
+	assert.Equal(t, payload, decoded[0])
+}
+
+func TestFileKeyProviderValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets map[string]string
+		current string
+	}{
+		{"unknown current key", map[string]string{"key-1": newSecret(t)}, "key-2"},
+		{"short key", map[string]string{"key-1": base64.StdEncoding.EncodeToString([]byte("too short"))}, "key-1"},
+		{"not base64", map[string]string{"key-1": "not base64!"}, "key-1"},
+		{"missing id", map[string]string{"": newSecret(t)}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys.json")
+			writeKeys(t, path, test.secrets, test.current)
+
+			_, err := NewFileKeyProvider(path)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := NewFileKeyProvider(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestDataConverterFromEnv(t *testing.T) {
+	t.Setenv("CODEC_KEYS_FILE", "")
+	dc, err := DataConverterFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, converter.GetDefaultDataConverter(), dc)
+
+	_, path, _ := newTestKeys(t)
+	t.Setenv("CODEC_KEYS_FILE", path)
+	dc, err = DataConverterFromEnv()
+	require.NoError(t, err)
+
+	payload, err := dc.ToPayload(order)
+	require.NoError(t, err)
+	assert.Equal(t, MetadataEncoding, string(payload.Metadata[MetadataEncodingKey]), fmt.Sprint(payload.Metadata))
+}
@@ -14,21 +14,27 @@
  * limitations under the License.
  */
 
-import { Client, Connection } from '@temporalio/client';
+package codec
 
-// Handle singleton
-let client: Client;
+import (
+	"fmt"
+	"os"
 
-export async function ensureConnection(): Promise<Client> {
-  if (!client) {
-    const connection = await Connection.connect({
-      address: process.env.TEMPORAL_ADDRESS,
-    });
+	"go.temporal.io/sdk/converter"
+)
 
-    client = new Client({
-      connection,
-    });
-  }
+// DataConverterFromEnv encrypts payloads with the keys in CODEC_KEYS_FILE.
+// Payloads aren't encrypted if it's not set.
+func DataConverterFromEnv() (converter.DataConverter, error) {
+	path := os.Getenv("CODEC_KEYS_FILE")
+	if path == "" {
+		return converter.GetDefaultDataConverter(), nil
+	}
 
-  return client;
+	keys, err := NewFileKeyProvider(path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading encryption keys: %w", err)
+	}
+
+	return NewDataConverter(keys), nil
 }
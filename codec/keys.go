@@ -0,0 +1,153 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package codec
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// AES-256 needs a 32 byte key
+const KeySize = 32
+
+// ErrUnknownKey is returned when a payload was encrypted with a key the provider doesn't have
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// KeyProvider gives the codec its keys. New payloads are encrypted with the
+// current key and old ones are decrypted with the key they name, so keys are
+// rotated by adding a new one and making it current - keep the old ones
+// until nothing's encrypted with them.
+type KeyProvider interface {
+	Current() (*Key, error)
+	Get(id string) (*Key, error)
+}
+
+func (k Key) Validate() error {
+	if k.ID == "" {
+		return fmt.Errorf("key id is required")
+	}
+	if len(k.Secret) != KeySize {
+		return fmt.Errorf("key %s must be %d bytes", k.ID, KeySize)
+	}
+	return nil
+}
+
+// KeysFile is the file read by the FileKeyProvider
+//
+//	{
+//	  "current": "2025-06",
+//	  "keys": [
+//	    { "id": "2025-01", "secret": "<base64>" },
+//	    { "id": "2025-06", "secret": "<base64>" }
+//	  ]
+//	}
+type KeysFile struct {
+	Current string `json:"current"`
+	Keys    []struct {
+		ID     string `json:"id"`
+		Secret string `json:"secret"` // Base64 encoded - "openssl rand -base64 32"
+	} `json:"keys"`
+}
+
+// FileKeyProvider reads the keys from a local file. Call Reload after
+// changing the file to rotate the keys without restarting.
+type FileKeyProvider struct {
+	current string
+	keys    map[string]*Key
+	mu      sync.RWMutex
+	path    string
+}
+
+func NewFileKeyProvider(path string) (*FileKeyProvider, error) {
+	p := &FileKeyProvider{
+		path: path,
+	}
+
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *FileKeyProvider) Reload() error {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("error reading keys file: %w", err)
+	}
+
+	var file KeysFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("error parsing keys file: %w", err)
+	}
+
+	keys := make(map[string]*Key, len(file.Keys))
+	for _, k := range file.Keys {
+		secret, err := base64.StdEncoding.DecodeString(k.Secret)
+		if err != nil {
+			return fmt.Errorf("error decoding key %s: %w", k.ID, err)
+		}
+
+		key := &Key{ID: k.ID, Secret: secret}
+		if err := key.Validate(); err != nil {
+			return err
+		}
+		if _, ok := keys[key.ID]; ok {
+			return fmt.Errorf("duplicate key: %s", key.ID)
+		}
+		keys[key.ID] = key
+	}
+
+	if _, ok := keys[file.Current]; !ok {
+		return fmt.Errorf("current key %q is not in the keys file", file.Current)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.current = file.Current
+	p.keys = keys
+
+	return nil
+}
+
+func (p *FileKeyProvider) Current() (*Key, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.keys[p.current], nil
+}
+
+func (p *FileKeyProvider) Get(id string) (*Key, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	return key, nil
+}
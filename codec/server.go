@@ -0,0 +1,85 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package codec
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"slices"
+	"strings"
+
+	"go.temporal.io/sdk/converter"
+)
+
+// Authorizer decides who can encode and decode payloads
+type Authorizer func(r *http.Request) bool
+
+// BearerTokenAuthorizer allows requests with one of the tokens. The Temporal
+// UI sends the user's token when the codec endpoint is set to pass it.
+func BearerTokenAuthorizer(tokens ...string) Authorizer {
+	return func(r *http.Request) bool {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			return false
+		}
+
+		for _, t := range tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AllowAll is for local development only
+func AllowAll(r *http.Request) bool {
+	return true
+}
+
+type HandlerOptions struct {
+	Authorize      Authorizer
+	AllowedOrigins []string // Where the Temporal UI is served from
+}
+
+// NewHandler serves the codec for the Temporal UI and CLI on /encode and /decode
+func NewHandler(keys KeyProvider, opts HandlerOptions) http.Handler {
+	codec := converter.NewPayloadCodecHTTPHandler(NewEncryptionCodec(keys))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The UI calls the codec from the browser
+		if origin := r.Header.Get("Origin"); origin != "" && slices.Contains(opts.AllowedOrigins, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization,Content-Type,X-Namespace")
+			w.Header().Set("Access-Control-Allow-Methods", "POST,OPTIONS")
+			w.Header().Add("Vary", "Origin")
+		}
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if opts.Authorize == nil || !opts.Authorize(r) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		codec.ServeHTTP(w, r)
+	})
+}
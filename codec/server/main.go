@@ -0,0 +1,79 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Codec server, so the Temporal UI and CLI can show encrypted payloads to
+// authorised users. Point the UI's codec endpoint at it.
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/mrsimonemms/temporal-demos/codec"
+)
+
+func main() {
+	keys, err := codec.NewFileKeyProvider(os.Getenv("CODEC_KEYS_FILE"))
+	if err != nil {
+		log.Fatalln("Unable to load encryption keys", err)
+	}
+
+	opts := codec.HandlerOptions{
+		AllowedOrigins: []string{"http://localhost:8233"},
+	}
+	if origins := os.Getenv("CODEC_ALLOWED_ORIGINS"); origins != "" {
+		opts.AllowedOrigins = strings.Split(origins, ",")
+	}
+
+	if tokens := os.Getenv("CODEC_AUTH_TOKENS"); tokens != "" {
+		opts.Authorize = codec.BearerTokenAuthorizer(strings.Split(tokens, ",")...)
+	} else if os.Getenv("CODEC_ALLOW_ANONYMOUS") == "true" {
+		log.Println("Anyone can decode payloads - only use this locally")
+		opts.Authorize = codec.AllowAll
+	} else {
+		log.Fatalln("Set CODEC_AUTH_TOKENS, or CODEC_ALLOW_ANONYMOUS=true for local development")
+	}
+
+	addr := os.Getenv("CODEC_ADDRESS")
+	if addr == "" {
+		addr = ":8081"
+	}
+
+	// Reload the keys on SIGHUP to pick up a rotation
+	go reloadOnHangup(keys)
+
+	log.Println("Starting codec server", "address", addr)
+	if err := http.ListenAndServe(addr, codec.NewHandler(keys, opts)); err != nil {
+		log.Fatalln("Unable to start codec server", err)
+	}
+}
+
+func reloadOnHangup(keys *codec.FileKeyProvider) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+
+	for range ch {
+		if err := keys.Reload(); err != nil {
+			log.Println("Unable to reload encryption keys", err)
+			continue
+		}
+		log.Println("Reloaded encryption keys")
+	}
+}
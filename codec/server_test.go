@@ -0,0 +1,109 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+func TestHandler(t *testing.T) {
+	keys, _, _ := newTestKeys(t)
+	dc := NewDataConverter(keys)
+
+	srv := httptest.NewServer(NewHandler(keys, HandlerOptions{
+		AllowedOrigins: []string{"http://localhost:8233"},
+		Authorize:      BearerTokenAuthorizer("secret-token"),
+	}))
+	defer srv.Close()
+
+	payload, err := dc.ToPayload(order)
+	require.NoError(t, err)
+	body, err := json.Marshal(&commonpb.Payloads{Payloads: []*commonpb.Payload{payload}})
+	require.NoError(t, err)
+
+	decode := func(token string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/decode", bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Origin", "http://localhost:8233")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = res.Body.Close()
+		})
+		return res
+	}
+
+	t.Run("no token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, decode("").StatusCode)
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, decode("guess").StatusCode)
+	})
+
+	t.Run("authorised", func(t *testing.T) {
+		res := decode("secret-token")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "http://localhost:8233", res.Header.Get("Access-Control-Allow-Origin"))
+
+		var decoded commonpb.Payloads
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&decoded))
+
+		var result testOrder
+		require.NoError(t, converter.GetDefaultDataConverter().FromPayload(decoded.Payloads[0], &result))
+		assert.Equal(t, order, result)
+	})
+
+	t.Run("preflight", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodOptions, srv.URL+"/decode", nil)
+		require.NoError(t, err)
+		req.Header.Set("Origin", "http://localhost:8233")
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+		assert.Contains(t, res.Header.Get("Access-Control-Allow-Headers"), "Authorization")
+	})
+
+	t.Run("other origins", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodOptions, srv.URL+"/decode", nil)
+		require.NoError(t, err)
+		req.Header.Set("Origin", "http://evil.test")
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		assert.Empty(t, res.Header.Get("Access-Control-Allow-Origin"))
+	})
+}
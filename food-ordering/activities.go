@@ -18,16 +18,218 @@ package foodordering
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/webhook"
+	"github.com/mrsimonemms/temporal-demos/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
 )
 
-type activities struct{}
+// EventPublisher receives the order changes from the outbox. It must ignore
+// changes it's already seen, as they're published at least once.
+type EventPublisher interface {
+	Publish(ctx context.Context, change OrderChange) error
+}
+
+type ActivityOption func(a *activities)
+
+// WithEventPublisher sends the order changes somewhere - they're only logged if
+// not set. Each publisher is sent every change, and a retry only resends it to
+// the publishers that failed.
+func WithEventPublisher(publisher EventPublisher) ActivityOption {
+	return func(a *activities) {
+		a.events = append(a.events, publisher)
+	}
+}
+
+// WithPaymentProvider takes real card payments - they're faked if not set
+func WithPaymentProvider(provider PaymentProvider) ActivityOption {
+	return func(a *activities) {
+		a.payments = provider
+	}
+}
+
+type activities struct {
+	events         []EventPublisher
+	giftCards      GiftCardLedger
+	loyalty        LoyaltyLedger
+	mailer         SalesReportMailer
+	payments       PaymentProvider
+	printerAddress string // Kitchen printer - tickets are only logged if not set
+	reportDir      string // Relative to the working directory unless set
+	reports        SalesReporter
+	risk           RiskHistory
+	webhooks       *webhook.Sender
+}
 
-func (a *activities) RefundPayment(ctx context.Context) error {
+// CheckRisk runs the fraud checks before any payment is taken
+func (a *activities) CheckRisk(ctx context.Context, req RiskCheckRequest) (*RiskAssessment, error) {
 	logger := activity.GetLogger(ctx)
-	logger.Info("Activity started")
+	logger.Info("Checking order risk", "orderId", req.OrderID, "totalInPence", req.TotalInPence)
+
+	signals, err := a.risk.Record(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("error getting risk signals: %w", err)
+	}
+
+	assessment := AssessRisk(req, signals)
+	logger.Info("Order risk assessed", "decision", assessment.Decision, "reasons", assessment.Reasons)
+
+	return &assessment, nil
+}
+
+// Points that can't be given back are recorded against the order, so the number taken is returned
+func (a *activities) ClawbackLoyaltyPoints(ctx context.Context, req LoyaltyRequest) (int, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Clawing back loyalty points", "points", req.Points, "reference", req.Reference)
+
+	return a.loyalty.Clawback(ctx, req.CustomerID, req.Reference, req.Points)
+}
+
+func (a *activities) CreditLoyaltyPoints(ctx context.Context, req LoyaltyRequest) (int, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Crediting loyalty points", "points", req.Points, "reference", req.Reference)
+
+	return a.loyalty.Credit(ctx, req.CustomerID, req.Reference, req.Points)
+}
+
+// CreditGiftCard puts money back on a gift card or wallet, returning the new balance
+func (a *activities) CreditGiftCard(ctx context.Context, req GiftCardRequest) (int, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Crediting gift card", "account", req.Account, "amountInPence", req.AmountInPence, "reference", req.Reference)
+
+	return a.giftCards.Credit(ctx, req.Account, req.Reference, req.AmountInPence)
+}
+
+// DebitGiftCard takes up to the amount from a gift card or wallet, returning how much was taken
+func (a *activities) DebitGiftCard(ctx context.Context, req GiftCardRequest) (int, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Debiting gift card", "account", req.Account, "amountInPence", req.AmountInPence, "reference", req.Reference)
+
+	amount, err := a.giftCards.Debit(ctx, req.Account, req.Reference, req.AmountInPence)
+	if errors.Is(err, ErrUnknownGiftCard) {
+		return 0, temporal.NewNonRetryableApplicationError(err.Error(), UnknownGiftCardErrorType, err)
+	}
+
+	return amount, err
+}
+
+// EmailSalesReport sends the report to the restaurant
+func (a *activities) EmailSalesReport(ctx context.Context, req EmailSalesReportRequest) error {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Emailing sales report", "to", req.To, "date", req.Report.Date)
+
+	if a.mailer == nil {
+		// Retrying won't configure a mail server
+		return temporal.NewNonRetryableApplicationError("no mail server configured", "NoSalesReportMailer", nil)
+	}
+
+	return a.mailer.Send(ctx, req)
+}
+
+func (a *activities) GetDailySales(ctx context.Context, req SalesReportRequest) (*DailySalesReport, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Getting daily sales", "restaurantId", req.RestaurantID, "date", req.Date)
+
+	if a.reports == nil {
+		// Retrying won't configure a database
+		return nil, temporal.NewNonRetryableApplicationError("no reporting database configured", "NoSalesReporter", nil)
+	}
+
+	return a.reports.DailySales(ctx, req)
+}
+
+// GetLoyaltyBalance is how many points the customer can spend
+func (a *activities) GetLoyaltyBalance(ctx context.Context, customerID string) (int, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Getting loyalty balance", "customerId", customerID)
+
+	balance, err := a.loyalty.Balance(ctx, customerID)
+	if err != nil {
+		logger.Error("Error getting loyalty balance", "error", err)
+		return 0, fmt.Errorf("error getting loyalty balance: %w", err)
+	}
+
+	return balance, nil
+}
+
+func (a *activities) GetRestaurant(ctx context.Context) (*Restaurant, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Debug("Getting restaurant configuration")
+
+	return &restaurant, nil
+}
+
+// PrintTicket sends the ticket to the kitchen's thermal printer
+func (a *activities) PrintTicket(ctx context.Context, ticket Ticket) error {
+	logger := activity.GetLogger(ctx)
+
+	if a.printerAddress == "" {
+		logger.Info("No printer configured", "ticket", ticket.Text())
+		return nil
+	}
+
+	logger.Info("Printing ticket", "orderId", ticket.OrderID, "printer", a.printerAddress)
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", a.printerAddress)
+	if err != nil {
+		return fmt.Errorf("error connecting to printer: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetWriteDeadline(deadline); err != nil {
+			return fmt.Errorf("error setting printer deadline: %w", err)
+		}
+	}
+
+	if _, err := conn.Write(ticket.ESCPOS()); err != nil {
+		return fmt.Errorf("error printing ticket: %w", err)
+	}
+
+	return nil
+}
+
+// CancelPayment stops the customer authorising a payment the order has given up on
+func (a *activities) CancelPayment(ctx context.Context, chargeID string) error {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Cancelling payment", "chargeId", chargeID)
+
+	if a.payments == nil {
+		return nil
+	}
+
+	return a.payments.Cancel(ctx, chargeID)
+}
+
+func (a *activities) RefundPayment(ctx context.Context, req RefundRequest) error {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Activity started", "transactionId", req.TransactionID, "amountInPence", req.AmountInPence)
+
+	if a.payments != nil {
+		err := a.payments.Refund(ctx, req)
+		if errors.Is(err, ErrUnknownCharge) {
+			logger.Error("Refunding unknown charge", "error", err)
+			return temporal.NewNonRetryableApplicationError(err.Error(), UnknownChargeErrorType, err)
+		}
+		if err != nil {
+			logger.Error("Error refunding payment", "error", err)
+			return fmt.Errorf("error refunding payment: %w", err)
+		}
+		tracing.AddEvent(ctx, "PaymentRefunded", attribute.Int("payment.amountInPence", req.AmountInPence))
+		return nil
+	}
 
 	time.Sleep(time.Second * 5)
 
@@ -36,6 +238,85 @@ func (a *activities) RefundPayment(ctx context.Context) error {
 	return nil
 }
 
+func (a *activities) PublishOrderChange(ctx context.Context, change OrderChange) error {
+	logger := activity.GetLogger(ctx)
+
+	if len(a.events) == 0 {
+		logger.Info("No event publisher configured", "eventId", change.ID, "status", change.Status)
+		return nil
+	}
+
+	// A retry only sends the change to the publishers that haven't had it yet
+	done := make([]int, 0)
+	if activity.HasHeartbeatDetails(ctx) {
+		if err := activity.GetHeartbeatDetails(ctx, &done); err != nil {
+			logger.Warn("Unable to read previous publishes", "error", err)
+		}
+	}
+
+	logger.Info("Publishing order change", "eventId", change.ID, "status", change.Status)
+	errs := make([]error, 0)
+	for i, publisher := range a.events {
+		if slices.Contains(done, i) {
+			continue
+		}
+
+		// One publisher being down mustn't stop the others getting the change
+		if err := publisher.Publish(ctx, change); err != nil {
+			logger.Error("Error publishing order change", "publisher", i, "error", err)
+			errs = append(errs, err)
+			continue
+		}
+
+		done = append(done, i)
+		activity.RecordHeartbeat(ctx, done)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("error publishing order change: %w", err)
+	}
+
+	return nil
+}
+
+// RecordCustomerOrder adds a completed order to the customer's history, starting
+// the customer's workflow if they've not got one yet
+func (a *activities) RecordCustomerOrder(ctx context.Context, req CustomerOrderRequest) error {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Recording customer order", "customerId", req.CustomerID, "orderId", req.Order.OrderID)
+
+	workflowID := CustomerWorkflowID(req.CustomerID)
+	if _, err := activity.GetClient(ctx).SignalWithStartWorkflow(
+		ctx,
+		workflowID,
+		Signals.ORDER_COMPLETED,
+		req.Order,
+		client.StartWorkflowOptions{
+			ID:        workflowID,
+			TaskQueue: OrderFoodTaskQueue,
+		},
+		CustomerWorkflow,
+		NewCustomerState(req.CustomerID),
+	); err != nil {
+		logger.Error("Error recording customer order", "error", err)
+		return fmt.Errorf("error recording customer order: %w", err)
+	}
+
+	return nil
+}
+
+func (a *activities) RedeemLoyaltyPoints(ctx context.Context, req LoyaltyRequest) (int, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Redeeming loyalty points", "points", req.Points, "reference", req.Reference)
+
+	balance, err := a.loyalty.Debit(ctx, req.CustomerID, req.Reference, req.Points)
+	if errors.Is(err, ErrInsufficientPoints) {
+		// Retrying won't magic up more points
+		return 0, temporal.NewNonRetryableApplicationError(err.Error(), "InsufficientPoints", err)
+	}
+
+	return balance, err
+}
+
 func (a *activities) SendTextMessage(ctx context.Context, status OrderState) error {
 	logger := activity.GetLogger(ctx)
 	logger.Info("Activity started")
@@ -47,17 +328,142 @@ func (a *activities) SendTextMessage(ctx context.Context, status OrderState) err
 	return nil
 }
 
-func (a *activities) TakePayment(ctx context.Context) error {
+// SendWebhook tells the restaurant's partners about the event. Failed deliveries
+// are recorded rather than failing the activity.
+func (a *activities) SendWebhook(ctx context.Context, req WebhookRequest) ([]webhook.Delivery, error) {
 	logger := activity.GetLogger(ctx)
-	logger.Info("Activity started")
+	logger.Info("Sending webhook", "eventId", req.Event.ID, "restaurantId", req.RestaurantID)
+
+	// A retry only sends the event to the partners that haven't had it yet
+	done := make([]webhook.Delivery, 0)
+	if activity.HasHeartbeatDetails(ctx) {
+		if err := activity.GetHeartbeatDetails(ctx, &done); err != nil {
+			logger.Warn("Unable to read previous deliveries", "error", err)
+		}
+	}
+
+	sender := *a.webhooks
+	sender.Previous = done
+	sender.OnAttempt = func(subscription webhook.Subscription, attempt int) {
+		activity.RecordHeartbeat(ctx, done)
+	}
+	sender.OnDelivery = func(delivery webhook.Delivery) {
+		done = append(done, delivery)
+		activity.RecordHeartbeat(ctx, done)
+	}
+
+	deliveries, err := sender.Send(ctx, req.RestaurantID, req.Event)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range deliveries {
+		if !d.Delivered {
+			logger.Warn("Webhook not delivered", "subscriptionId", d.SubscriptionID, "attempts", d.Attempts, "error", d.Error)
+		}
+	}
+
+	return deliveries, nil
+}
+
+// WriteSalesReport saves the report as CSV and JSON, returning where they were written
+func (a *activities) WriteSalesReport(ctx context.Context, report DailySalesReport) (*SalesReportFiles, error) {
+	logger := activity.GetLogger(ctx)
+
+	if err := os.MkdirAll(a.reportDir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating report directory: %w", err)
+	}
+
+	name := filepath.Join(a.reportDir, fmt.Sprintf("sales-%s-%s", report.RestaurantID, report.Date))
+	files := &SalesReportFiles{
+		CSV:  name + ".csv",
+		JSON: name + ".json",
+	}
+
+	csvData, err := report.CSV()
+	if err != nil {
+		return nil, err
+	}
+	jsonData, err := report.JSON()
+	if err != nil {
+		return nil, fmt.Errorf("error encoding report: %w", err)
+	}
+
+	// Reruns for the same day overwrite the report
+	if err := os.WriteFile(files.CSV, csvData, 0o644); err != nil {
+		return nil, fmt.Errorf("error writing csv report: %w", err)
+	}
+	if err := os.WriteFile(files.JSON, jsonData, 0o644); err != nil {
+		return nil, fmt.Errorf("error writing json report: %w", err)
+	}
+
+	logger.Info("Sales report written", "csv", files.CSV, "json", files.JSON)
+
+	return files, nil
+}
+
+// TakePayment charges the card. If the bank wants the customer to authorise it,
+// the challenge is returned and the result is sent to the order by the provider.
+func (a *activities) TakePayment(ctx context.Context, req PaymentRequest) (*Payment, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Activity started", "payer", req.Payer, "amountInPence", req.AmountInPence)
+
+	if a.payments != nil {
+		payment, err := a.payments.Charge(ctx, req)
+		if errors.Is(err, ErrPaymentDeclined) {
+			tracing.AddEvent(ctx, "PaymentDeclined", attribute.Int("payment.amountInPence", req.AmountInPence))
+			// Trying the same card again won't help
+			return nil, temporal.NewNonRetryableApplicationError(err.Error(), PaymentDeclinedErrorType, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error taking payment: %w", err)
+		}
+
+		if payment.Challenge != nil {
+			logger.Info("Payment needs authorising", "chargeId", payment.Challenge.ChargeID, "url", payment.Challenge.URL)
+		}
+		tracing.AddEvent(ctx, "PaymentTaken",
+			attribute.Int("payment.amountInPence", payment.AmountInPence),
+			attribute.Bool("payment.challenged", payment.Challenge != nil),
+		)
+
+		return payment, nil
+	}
 
 	time.Sleep(time.Second * 5)
 
 	logger.Info("Activity finished")
 
-	return nil
+	return &Payment{
+		AmountInPence: req.AmountInPence,
+		Payer:         req.Payer,
+		TransactionID: uuid.NewString(),
+	}, nil
 }
 
-func NewActivities() (*activities, error) {
-	return &activities{}, nil
+func NewActivities(opts ...ActivityOption) (*activities, error) {
+	// Demo partner subscription - normally these would be in a database
+	subscriptions := make([]webhook.Subscription, 0)
+	if url := os.Getenv("WEBHOOK_URL"); url != "" {
+		subscriptions = append(subscriptions, webhook.Subscription{
+			ID:           "demo",
+			RestaurantID: restaurant.ID,
+			Secret:       os.Getenv("WEBHOOK_SECRET"),
+			URL:          url,
+		})
+	}
+
+	a := &activities{
+		giftCards:      NewMemoryGiftCardLedger(DemoGiftCards),
+		loyalty:        NewMemoryLoyaltyLedger(),
+		printerAddress: os.Getenv("PRINTER_ADDRESS"),
+		reportDir:      "reports",
+		risk:           NewMemoryRiskHistory(),
+		webhooks:       webhook.NewSender(webhook.NewMemoryStore(subscriptions...)),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a, nil
 }
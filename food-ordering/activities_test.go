@@ -0,0 +1,72 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.temporal.io/sdk/testsuite"
+)
+
+type testPublisher struct {
+	changes []OrderChange
+	err     error
+}
+
+func (p *testPublisher) Publish(ctx context.Context, change OrderChange) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.changes = append(p.changes, change)
+	return nil
+}
+
+func TestPublishOrderChangeToEachPublisher(t *testing.T) {
+	state := NewOrderState()
+	state.SetStatus(OrderStatusPending, time.Now())
+	change := NewOrderChange("order-1", "codfather", state)
+
+	store := &testPublisher{err: errors.New("database is locked")}
+	feed := &testPublisher{}
+
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestActivityEnvironment()
+
+	a := &activities{}
+	WithEventPublisher(store)(a)
+	WithEventPublisher(feed)(a)
+	env.RegisterActivity(a)
+
+	// The feed still gets the change when the store's down
+	_, err := env.ExecuteActivity(a.PublishOrderChange, change)
+	assert.ErrorContains(t, err, "database is locked")
+	assert.Len(t, feed.changes, 1)
+
+	t.Run("retry only sends to the failed publisher", func(t *testing.T) {
+		store.err = nil
+		env.SetHeartbeatDetails([]int{1})
+
+		_, err := env.ExecuteActivity(a.PublishOrderChange, change)
+		assert.NoError(t, err)
+		assert.Len(t, store.changes, 1)
+		assert.Len(t, feed.changes, 1)
+	})
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/mrsimonemms/temporal-demos/codec"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/httpapi"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/projection"
+	"github.com/mrsimonemms/temporal-demos/tracing"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+)
+
+func main() {
+	// Order details are encrypted before they're stored in the workflow history
+	dataConverter, err := codec.DataConverterFromEnv()
+	if err != nil {
+		log.Fatalln("Unable to create data converter", err)
+	}
+
+	// Traces are only exported if OTEL_TRACES_EXPORTER is set
+	shutdownTracing, err := tracing.Setup(context.Background(), "food-ordering-api")
+	if err != nil {
+		log.Fatalln("Unable to configure tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	tracingInterceptor, err := tracing.NewInterceptor()
+	if err != nil {
+		log.Fatalln("Unable to create tracing interceptor", err)
+	}
+
+	// The client is a heavyweight object that should be created once per process.
+	c, err := client.Dial(client.Options{
+		DataConverter: dataConverter,
+		HostPort:      os.Getenv("TEMPORAL_ADDRESS"),
+		Interceptors:  []interceptor.ClientInterceptor{tracingInterceptor},
+		Namespace:     os.Getenv("TEMPORAL_NAMESPACE"),
+	})
+	if err != nil {
+		log.Fatalln("Unable to create client", err)
+	}
+	defer c.Close()
+
+	opts := make([]httpapi.Option, 0)
+	if path := os.Getenv("DATABASE_PATH"); path != "" {
+		// Reports use the database the worker projects the orders into - this must
+		// be the same file, so the API runs on the same machine as the workers
+		store, err := projection.Open(context.Background(), path)
+		if err != nil {
+			log.Fatalln("Unable to open database", err)
+		}
+		defer store.Close()
+
+		opts = append(opts, httpapi.WithReadModel(store))
+	}
+
+	if secret := os.Getenv("EVENTS_SECRET"); secret != "" {
+		// The worker pushes the order changes to the event streams, which aren't
+		// served without them
+		opts = append(opts, httpapi.WithChangeFeed(secret))
+	}
+
+	// Only these proxies are trusted to set X-Forwarded-For
+	proxies, err := httpapi.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalln("Unable to configure trusted proxies", err)
+	}
+	opts = append(opts, httpapi.WithTrustedProxies(proxies...))
+
+	addr := os.Getenv("LISTEN_ADDRESS")
+	if addr == "" {
+		addr = ":3000"
+	}
+
+	log.Println("Starting API server", "address", addr)
+	if err := http.ListenAndServe(addr, tracing.Middleware(httpapi.New(c, opts...))); err != nil {
+		log.Fatalln("Unable to start API server", err)
+	}
+}
@@ -18,24 +18,74 @@ package foodordering
 
 const OrderFoodTaskQueue = "order-food"
 
+// Error type returned when an update is rejected by its validator
+const UpdateRejectedErrorType = "UpdateRejected"
+
+// Webhook event sent to partners when an order changes status
+const OrderStatusChangedEvent = "order.status_changed"
+
+// Webhook event sent to partners when the customer tips after the order's completed
+const OrderTippedEvent = "order.tipped"
+
+// Webhook event sent to partners when money's given back without a status change
+const OrderRefundedEvent = "order.refunded"
+
 var Queries = struct {
-	GET_STATUS string
+	GET_CUSTOMER           string
+	GET_STATUS             string
+	GET_WEBHOOK_DELIVERIES string
 }{
-	GET_STATUS: "GET_STATUS",
+	GET_CUSTOMER:           "GET_CUSTOMER",
+	GET_STATUS:             "GET_STATUS",
+	GET_WEBHOOK_DELIVERIES: "GET_WEBHOOK_DELIVERIES",
 }
 
 var Signals = struct {
-	CHECKOUT string // Submits order for payment
+	CHECKOUT         string // Submits order for payment, locking group baskets
+	ORDER_COMPLETED  string // Order tells the customer it's been completed
+	PAYMENT_CALLBACK string // Card provider sends the result of a payment challenge
 }{
-	CHECKOUT: "CHECKOUT",
+	CHECKOUT:         "CHECKOUT",
+	ORDER_COMPLETED:  "ORDER_COMPLETED",
+	PAYMENT_CALLBACK: "PAYMENT_CALLBACK",
 }
 
 var Updates = struct {
-	ADD_ITEM      string // Adds an item to the order
-	REMOVE_ITEM   string // Remove an item from the order
-	UPDATE_STATUS string // Restaurant updates status of order
+	ADD_ITEM             string // Adds an item to the order
+	CANCEL               string // Customer cancels a scheduled order before it's released
+	COMPLAINT            string // Customer complains about items after completion
+	JOIN_GROUP           string // Participant joins a group order with the join code
+	PAY_SHARE            string // Participant pays for their items in a split payment group order
+	RATE                 string // Customer rates the order after completion
+	REMOVE_ITEM          string // Remove an item from the order
+	RESOLVE_COMPLAINT    string // Restaurant approves or declines a complaint
+	RESOLVE_INTERVENTION string // Ops retry, resolve by hand or cancel a failed money operation
+	REVIEW_RISK          string // Operator approves or declines an order held by the fraud checks
+	TIP                  string // Customer tips the kitchen and courier at checkout or after completion
+	UPDATE_STATUS        string // Restaurant updates status of order
+	WAIT_FOR_CHANGE      string // Long-polls for the next status change
+
+	REMOVE_ADDRESS string // Customer deletes a saved address
+	REORDER        string // Customer places a previous order again
+	SAVE_ADDRESS   string // Customer adds or changes a saved address
+	UPDATE_CONTACT string // Customer changes their default contact details
 }{
-	ADD_ITEM:      "ADD_ITEM",
-	REMOVE_ITEM:   "REMOVE_ITEM",
-	UPDATE_STATUS: "UPDATE_STATUS",
+	ADD_ITEM:             "ADD_ITEM",
+	CANCEL:               "CANCEL",
+	COMPLAINT:            "COMPLAINT",
+	JOIN_GROUP:           "JOIN_GROUP",
+	PAY_SHARE:            "PAY_SHARE",
+	RATE:                 "RATE",
+	REMOVE_ITEM:          "REMOVE_ITEM",
+	RESOLVE_COMPLAINT:    "RESOLVE_COMPLAINT",
+	RESOLVE_INTERVENTION: "RESOLVE_INTERVENTION",
+	REVIEW_RISK:          "REVIEW_RISK",
+	TIP:                  "TIP",
+	UPDATE_STATUS:        "UPDATE_STATUS",
+	WAIT_FOR_CHANGE:      "WAIT_FOR_CHANGE",
+
+	REMOVE_ADDRESS: "REMOVE_ADDRESS",
+	REORDER:        "REORDER",
+	SAVE_ADDRESS:   "SAVE_ADDRESS",
+	UPDATE_CONTACT: "UPDATE_CONTACT",
 }
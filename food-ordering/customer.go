@@ -0,0 +1,171 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Number of orders kept in the customer's history
+const maxOrderHistory = 50
+
+type ContactDetails struct {
+	Email string `json:"email" pii:"true"`
+	Name  string `json:"name"`
+	Phone string `json:"phone" pii:"true"`
+}
+
+type SavedAddress struct {
+	Address Address `json:"address" pii:"true"`
+	Default bool    `json:"default"`
+	Label   string  `json:"label"` // eg, Home or Work
+}
+
+type OrderSummary struct {
+	Collection      bool           `json:"collection"`
+	CompletedAt     time.Time      `json:"completedAt"`
+	DeliveryAddress *Address       `json:"deliveryAddress" pii:"true"`
+	OrderID         string         `json:"orderId"`
+	Products        []OrderProduct `json:"products"`
+	TotalInPence    int            `json:"totalInPence"`
+}
+
+// CustomerOrderRequest adds a completed order to the customer's history
+type CustomerOrderRequest struct {
+	CustomerID string       `json:"customerId"`
+	Order      OrderSummary `json:"order"`
+}
+
+type ReorderRequest struct {
+	DeliveryAddress *Address `json:"deliveryAddress" pii:"true"` // Optional - delivers to the previous order's address if not set
+	OrderID         string   `json:"orderId"`
+}
+
+type CustomerState struct {
+	Addresses    []SavedAddress `json:"addresses"`
+	Contact      ContactDetails `json:"contact"`
+	CustomerID   string         `json:"customerId"`
+	OrderHistory []OrderSummary `json:"orderHistory"` // Most recent first
+}
+
+func (c *CustomerState) DefaultAddress() *Address {
+	for _, a := range c.Addresses {
+		if a.Default {
+			return &a.Address
+		}
+	}
+	return nil
+}
+
+func (c *CustomerState) GetOrder(orderID string) (*OrderSummary, error) {
+	for _, o := range c.OrderHistory {
+		if o.OrderID == orderID {
+			return &o, nil
+		}
+	}
+	return nil, fmt.Errorf("order not in history: %s", orderID)
+}
+
+// RecordOrder adds the order to the history, dropping the oldest if it's full.
+// Orders are only recorded once, so the signal can be safely retried.
+func (c *CustomerState) RecordOrder(order OrderSummary) {
+	if _, err := c.GetOrder(order.OrderID); err == nil {
+		return
+	}
+
+	c.OrderHistory = append([]OrderSummary{order}, c.OrderHistory...)
+	if len(c.OrderHistory) > maxOrderHistory {
+		c.OrderHistory = c.OrderHistory[:maxOrderHistory]
+	}
+}
+
+func (c *CustomerState) RemoveAddress(label string) {
+	addresses := make([]SavedAddress, 0)
+	for _, a := range c.Addresses {
+		if !strings.EqualFold(a.Label, label) {
+			addresses = append(addresses, a)
+		}
+	}
+	c.Addresses = addresses
+}
+
+// SaveAddress adds or replaces an address by its label
+func (c *CustomerState) SaveAddress(address SavedAddress) {
+	c.RemoveAddress(address.Label)
+
+	// The first address is always the default
+	if len(c.Addresses) == 0 {
+		address.Default = true
+	}
+
+	if address.Default {
+		for i := range c.Addresses {
+			c.Addresses[i].Default = false
+		}
+	}
+
+	c.Addresses = append(c.Addresses, address)
+}
+
+// Reorder creates a new order from one in the history. The order starts with
+// the previous order's basket, which the customer can change before checkout.
+func (c *CustomerState) Reorder(req ReorderRequest) (*OrderState, error) {
+	previous, err := c.GetOrder(req.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	order := NewOrderState()
+	order.Collection = previous.Collection
+	order.CustomerID = c.CustomerID
+	order.Email = c.Contact.Email
+
+	if !order.Collection {
+		order.DeliveryAddress = previous.DeliveryAddress
+		if req.DeliveryAddress != nil {
+			order.DeliveryAddress = req.DeliveryAddress
+		}
+	}
+
+	// Only include things that are still on the menu
+	for _, p := range previous.Products {
+		p.Owner = ""
+		if p.Validate() == nil {
+			order.AddItem(p)
+		}
+	}
+
+	if err := order.ValidateBasket(); err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+func CustomerWorkflowID(customerID string) string {
+	return "CUSTOMER-" + customerID
+}
+
+func NewCustomerState(customerID string) CustomerState {
+	return CustomerState{
+		Addresses:    make([]SavedAddress, 0),
+		CustomerID:   customerID,
+		OrderHistory: make([]OrderSummary, 0),
+	}
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func newTestCustomer() CustomerState {
+	customer := NewCustomerState("customer-1")
+	customer.Contact.Email = "test@test.com"
+	customer.SaveAddress(SavedAddress{
+		Address: Address{AddressLine1: "1 Work Street", PostCode: "W1 1AA"},
+		Label:   "Work",
+	})
+	customer.RecordOrder(OrderSummary{
+		DeliveryAddress: &Address{AddressLine1: "1 Home Street", PostCode: "H1 1AA"},
+		OrderID:         "order-1",
+		Products: []OrderProduct{
+			{ProductID: 1, Quantity: 2, Owner: "Alice"},
+			{ProductID: 999, Quantity: 1},
+		},
+	})
+	return customer
+}
+
+func TestCustomerRecordOrderOnce(t *testing.T) {
+	customer := newTestCustomer()
+	customer.RecordOrder(OrderSummary{OrderID: "order-1"})
+	customer.RecordOrder(OrderSummary{OrderID: "order-2"})
+
+	require.Len(t, customer.OrderHistory, 2)
+	assert.Equal(t, "order-2", customer.OrderHistory[0].OrderID)
+	assert.Len(t, customer.OrderHistory[1].Products, 2)
+}
+
+func TestCustomerReorder(t *testing.T) {
+	customer := newTestCustomer()
+
+	// Delivered where the previous order went, not the default address
+	order, err := customer.Reorder(ReorderRequest{OrderID: "order-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "1 Home Street", order.DeliveryAddress.AddressLine1)
+	assert.Equal(t, "customer-1", order.CustomerID)
+	assert.Equal(t, "test@test.com", order.Email)
+	assert.False(t, order.ExpressCheckout)
+
+	// Products no longer on the menu are dropped
+	assert.Equal(t, []OrderProduct{{ProductID: 1, Quantity: 2}}, order.Products)
+
+	order, err = customer.Reorder(ReorderRequest{
+		DeliveryAddress: &customer.Addresses[0].Address,
+		OrderID:         "order-1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "1 Work Street", order.DeliveryAddress.AddressLine1)
+
+	_, err = customer.Reorder(ReorderRequest{OrderID: "order-2"})
+	assert.Error(t, err)
+}
+
+func TestCustomerWorkflowReorder(t *testing.T) {
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(OrderWorkflow)
+
+	// The new order's basket is left open for the customer to change
+	env.OnWorkflow(OrderWorkflow, mock.Anything, mock.MatchedBy(func(order OrderState) bool {
+		return !order.ExpressCheckout && order.DeliveryAddress.AddressLine1 == "1 Home Street"
+	})).Return(nil).Once()
+
+	var newOrderID string
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(Signals.ORDER_COMPLETED, OrderSummary{
+			DeliveryAddress: &Address{AddressLine1: "1 Home Street", PostCode: "H1 1AA"},
+			OrderID:         "order-2",
+			Products:        []OrderProduct{{ProductID: 2, Quantity: 1}},
+		})
+	}, time.Minute)
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(Updates.REORDER, "", &testsuite.TestUpdateCallback{
+			OnReject: func(err error) {
+				assert.Fail(t, "reorder rejected", err)
+			},
+			OnAccept: func() {},
+			OnComplete: func(result any, err error) {
+				assert.NoError(t, err)
+				newOrderID, _ = result.(string)
+			},
+		}, ReorderRequest{OrderID: "order-2"})
+	}, 2*time.Minute)
+	env.RegisterDelayedCallback(func() {
+		res, err := env.QueryWorkflow(Queries.GET_CUSTOMER)
+		require.NoError(t, err)
+
+		var customer CustomerState
+		require.NoError(t, res.Get(&customer))
+		assert.Len(t, customer.OrderHistory, 2)
+
+		env.CancelWorkflow()
+	}, 3*time.Minute)
+
+	env.ExecuteWorkflow(CustomerWorkflow, newTestCustomer())
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.Contains(t, newOrderID, "ORDER-")
+	env.AssertExpectations(t)
+}
+
+func TestCustomerSaveAddress(t *testing.T) {
+	customer := NewCustomerState("customer-1")
+
+	// The first address is the default until another one's chosen
+	customer.SaveAddress(SavedAddress{Address: Address{AddressLine1: "1 Home Street"}, Label: "Home"})
+	customer.SaveAddress(SavedAddress{Address: Address{AddressLine1: "1 Work Street"}, Label: "Work"})
+	assert.Equal(t, "1 Home Street", customer.DefaultAddress().AddressLine1)
+
+	customer.SaveAddress(SavedAddress{Address: Address{AddressLine1: "2 Work Street"}, Default: true, Label: "work"})
+	require.Len(t, customer.Addresses, 2)
+	assert.Equal(t, "2 Work Street", customer.DefaultAddress().AddressLine1)
+
+	customer.RemoveAddress("WORK")
+	require.Len(t, customer.Addresses, 1)
+	assert.Nil(t, customer.DefaultAddress())
+}
+
+func TestCustomerWorkflowDetails(t *testing.T) {
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+
+	update := func(name string, arg any) *error {
+		var result error
+		env.UpdateWorkflow(name, "", &testsuite.TestUpdateCallback{
+			OnReject: func(err error) {
+				result = err
+			},
+			OnAccept: func() {},
+			OnComplete: func(_ any, err error) {
+				result = err
+			},
+		}, arg)
+		return &result
+	}
+
+	var invalid, saved, removed, contact *error
+	env.RegisterDelayedCallback(func() {
+		invalid = update(Updates.SAVE_ADDRESS, SavedAddress{Label: "Gym"})
+		saved = update(Updates.SAVE_ADDRESS, SavedAddress{
+			Address: Address{AddressLine1: "1 Gym Street", PostCode: "G1 1AA"},
+			Default: true,
+			Label:   "Gym",
+		})
+	}, time.Minute)
+	env.RegisterDelayedCallback(func() {
+		removed = update(Updates.REMOVE_ADDRESS, "work")
+		contact = update(Updates.UPDATE_CONTACT, ContactDetails{Email: "new@test.com", Name: "Test"})
+	}, 2*time.Minute)
+	env.RegisterDelayedCallback(func() {
+		res, err := env.QueryWorkflow(Queries.GET_CUSTOMER)
+		require.NoError(t, err)
+
+		var customer CustomerState
+		require.NoError(t, res.Get(&customer))
+		require.Len(t, customer.Addresses, 1)
+		assert.Equal(t, "1 Gym Street", customer.DefaultAddress().AddressLine1)
+		assert.Equal(t, "new@test.com", customer.Contact.Email)
+
+		env.CancelWorkflow()
+	}, 3*time.Minute)
+
+	env.ExecuteWorkflow(CustomerWorkflow, newTestCustomer())
+
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.Error(t, *invalid)
+	assert.NoError(t, *saved)
+	assert.NoError(t, *removed)
+	assert.NoError(t, *contact)
+}
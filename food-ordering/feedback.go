@@ -0,0 +1,147 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type ComplaintStatus string
+
+const (
+	ComplaintStatusPending  ComplaintStatus = "PENDING"  // Waiting for the restaurant to approve
+	ComplaintStatusApproved ComplaintStatus = "APPROVED" // Customer has been refunded
+	ComplaintStatusDeclined ComplaintStatus = "DECLINED" // Restaurant declined to refund
+
+	// The restaurant didn't decide before the feedback window closed - needs a person
+	ComplaintStatusUnresolved ComplaintStatus = "UNRESOLVED"
+)
+
+type Rating struct {
+	Comment string    `json:"comment"`
+	RatedAt time.Time `json:"ratedAt"`
+	Stars   int       `json:"stars"` // 1-5
+}
+
+type ComplaintItem struct {
+	Owner     string `json:"owner" pii:"true"` // Only needed for group orders
+	ProductID int    `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+type ComplaintRequest struct {
+	Items  []ComplaintItem `json:"items"`
+	Reason string          `json:"reason"`
+}
+
+type Complaint struct {
+	ComplaintID   string          `json:"complaintId"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	Items         []ComplaintItem `json:"items"`
+	Reason        string          `json:"reason"`
+	RefundInPence int             `json:"refundInPence"`
+	Status        ComplaintStatus `json:"status"`
+}
+
+type ComplaintResolution struct {
+	Approved    bool   `json:"approved"`
+	ComplaintID string `json:"complaintId"`
+}
+
+func (r Rating) Validate() error {
+	if r.Stars < 1 || r.Stars > 5 {
+		return fmt.Errorf("rating must be between 1 and 5 stars")
+	}
+	return nil
+}
+
+func (i ComplaintItem) RefundInPence() int {
+	return OrderProduct{ProductID: i.ProductID, Quantity: i.Quantity}.TotalInPence()
+}
+
+func (o *OrderState) GetComplaint(complaintID string) *Complaint {
+	for i := range o.Complaints {
+		if o.Complaints[i].ComplaintID == complaintID {
+			return &o.Complaints[i]
+		}
+	}
+	return nil
+}
+
+// complainedQuantity is how many of the line item have already been complained about
+func (o *OrderState) complainedQuantity(productID int, owner string) int {
+	total := 0
+	for _, c := range o.Complaints {
+		if c.Status == ComplaintStatusDeclined {
+			continue
+		}
+		for _, i := range c.Items {
+			if i.ProductID == productID && strings.EqualFold(i.Owner, owner) {
+				total += i.Quantity
+			}
+		}
+	}
+	return total
+}
+
+// ValidateComplaint checks the items were ordered and haven't already been complained about
+func (o *OrderState) ValidateComplaint(req ComplaintRequest) error {
+	if strings.TrimSpace(req.Reason) == "" {
+		return fmt.Errorf("reason is required")
+	}
+	if len(req.Items) == 0 {
+		return fmt.Errorf("no items selected")
+	}
+
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			return fmt.Errorf("quantity must be positive")
+		}
+
+		ordered := 0
+		for _, p := range o.Products {
+			if p.ProductID == item.ProductID && strings.EqualFold(p.Owner, item.Owner) {
+				ordered += p.Quantity
+			}
+		}
+
+		if item.Quantity > ordered-o.complainedQuantity(item.ProductID, item.Owner) {
+			return fmt.Errorf("product %d: more items than were ordered", item.ProductID)
+		}
+	}
+
+	return nil
+}
+
+// NewComplaint creates a pending complaint from the request
+func (o *OrderState) NewComplaint(req ComplaintRequest, now time.Time) Complaint {
+	refund := 0
+	for _, i := range req.Items {
+		refund += i.RefundInPence()
+	}
+
+	return Complaint{
+		ComplaintID:   fmt.Sprintf("C%d", len(o.Complaints)+1),
+		CreatedAt:     now,
+		Items:         req.Items,
+		Reason:        req.Reason,
+		RefundInPence: refund,
+		Status:        ComplaintStatusPending,
+	}
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRatingValidate(t *testing.T) {
+	assert.NoError(t, Rating{Stars: 1}.Validate())
+	assert.NoError(t, Rating{Stars: 5}.Validate())
+	assert.Error(t, Rating{Stars: 0}.Validate())
+	assert.Error(t, Rating{Stars: 6}.Validate())
+}
+
+func TestValidateComplaint(t *testing.T) {
+	state := NewOrderState()
+	state.AddItem(OrderProduct{ProductID: 1, Quantity: 2, Owner: "Alice"})
+	state.AddItem(OrderProduct{ProductID: 2, Quantity: 1, Owner: "Bob"})
+
+	item := func(productID, quantity int, owner string) ComplaintRequest {
+		return ComplaintRequest{
+			Items:  []ComplaintItem{{Owner: owner, ProductID: productID, Quantity: quantity}},
+			Reason: "Cold",
+		}
+	}
+
+	assert.NoError(t, state.ValidateComplaint(item(1, 2, "alice")))
+	assert.ErrorContains(t, state.ValidateComplaint(ComplaintRequest{Items: item(1, 1, "Alice").Items}), "reason is required")
+	assert.ErrorContains(t, state.ValidateComplaint(ComplaintRequest{Reason: "Cold"}), "no items selected")
+	assert.ErrorContains(t, state.ValidateComplaint(item(1, 0, "Alice")), "quantity must be positive")
+	assert.ErrorContains(t, state.ValidateComplaint(item(1, 3, "Alice")), "more items than were ordered")
+	assert.ErrorContains(t, state.ValidateComplaint(item(2, 1, "Alice")), "more items than were ordered")
+
+	// Items can't be complained about twice, unless the complaint was declined
+	state.Complaints = append(state.Complaints, state.NewComplaint(item(1, 2, "Alice"), time.Now()))
+	assert.Error(t, state.ValidateComplaint(item(1, 1, "Alice")))
+
+	state.Complaints[0].Status = ComplaintStatusDeclined
+	assert.NoError(t, state.ValidateComplaint(item(1, 1, "Alice")))
+}
+
+func TestNewComplaint(t *testing.T) {
+	now := time.Date(2025, time.June, 6, 12, 0, 0, 0, time.UTC)
+
+	state := NewOrderState()
+	state.Complaints = []Complaint{{ComplaintID: "C1"}}
+
+	complaint := state.NewComplaint(ComplaintRequest{
+		Items:  []ComplaintItem{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}},
+		Reason: "Cold",
+	}, now)
+
+	assert.Equal(t, "C2", complaint.ComplaintID)
+	assert.Equal(t, now, complaint.CreatedAt)
+	assert.Equal(t, testOrderTotal, complaint.RefundInPence)
+	assert.Equal(t, ComplaintStatusPending, complaint.Status)
+}
@@ -0,0 +1,318 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Error type returned when a gift card doesn't exist
+const UnknownGiftCardErrorType = "UnknownGiftCard"
+
+var ErrUnknownGiftCard = errors.New("unknown gift card")
+
+type PaymentMethodType string
+
+const (
+	PaymentMethodCard     PaymentMethodType = "CARD"
+	PaymentMethodGiftCard PaymentMethodType = "GIFT_CARD"
+	PaymentMethodWallet   PaymentMethodType = "WALLET" // The customer's stored balance - demo only, see PaymentMethod.Account
+)
+
+// DemoGiftCards are issued when the worker starts - normally they'd be sold
+var DemoGiftCards = map[string]int{
+	"GIFT-1000": 1000,
+	"GIFT-5000": 5000,
+}
+
+// PaymentMethod is a way the customer wants to pay. Gift cards and wallets are
+// used first, with the card paying whatever's left.
+type PaymentMethod struct {
+	AmountInPence int               `json:"amountInPence"` // Optional - most to take from a gift card or wallet
+	Code          string            `json:"code"`          // Gift card code
+	Type          PaymentMethodType `json:"type"`
+}
+
+type GiftCardRequest struct {
+	Account       string `json:"account"`
+	AmountInPence int    `json:"amountInPence"`
+	Reference     string `json:"reference"` // Unique per movement so retries aren't applied twice
+}
+
+type GiftCardEntry struct {
+	AmountInPence int       `json:"amountInPence"` // Negative for debits
+	CreatedAt     time.Time `json:"createdAt"`
+	Reference     string    `json:"reference"`
+}
+
+// GiftCardLedger stores the balances of gift cards and customer wallets. All
+// movements are idempotent on their reference and balances never go below zero.
+type GiftCardLedger interface {
+	Balance(ctx context.Context, account string) (int, error)
+	// Credit adds to the balance, returning the new balance
+	Credit(ctx context.Context, account, reference string, amountInPence int) (int, error)
+	// Debit takes up to the amount from the balance, returning how much was taken
+	Debit(ctx context.Context, account, reference string, amountInPence int) (int, error)
+}
+
+// WithGiftCardLedger shares the balances between workers - each worker keeps
+// its own in memory if not set
+func WithGiftCardLedger(ledger GiftCardLedger) ActivityOption {
+	return func(a *activities) {
+		a.giftCards = ledger
+	}
+}
+
+// IsStoredValue is true for methods paid from the ledger rather than a card
+func (t PaymentMethodType) IsStoredValue() bool {
+	return t == PaymentMethodGiftCard || t == PaymentMethodWallet
+}
+
+func (m PaymentMethod) IsStoredValue() bool {
+	return m.Type.IsStoredValue()
+}
+
+// Account is the ledger account the method is paid from. Wallets belong to the
+// customer ID on the order, which isn't authenticated, so anyone who knows the
+// ID can spend the wallet - this is only safe in the demo.
+func (m PaymentMethod) Account(customerID string) string {
+	if m.Type == PaymentMethodWallet {
+		return "wallet/" + customerID
+	}
+	return "giftcard/" + strings.ToUpper(strings.TrimSpace(m.Code))
+}
+
+// IsWalletAccount is true for the customers' wallets, which exist even when empty
+func IsWalletAccount(account string) bool {
+	return strings.HasPrefix(account, "wallet/")
+}
+
+func (m PaymentMethod) Validate() error {
+	switch m.Type {
+	case PaymentMethodCard:
+	case PaymentMethodGiftCard:
+		if strings.TrimSpace(m.Code) == "" {
+			return fmt.Errorf("gift card code is required")
+		}
+	case PaymentMethodWallet:
+	default:
+		return fmt.Errorf("unknown payment method: %q", m.Type)
+	}
+
+	if m.AmountInPence < 0 {
+		return fmt.Errorf("amount cannot be negative")
+	}
+
+	return nil
+}
+
+// ValidatePaymentMethods checks the methods can be used for the order
+func (o *OrderState) ValidatePaymentMethods() error {
+	cards := 0
+	for _, m := range o.PaymentMethods {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+
+		switch m.Type {
+		case PaymentMethodCard:
+			cards++
+		case PaymentMethodWallet:
+			if o.CustomerID == "" {
+				return fmt.Errorf("wallet can only be used by a customer")
+			}
+		}
+	}
+
+	if cards > 1 {
+		return fmt.Errorf("only one card can be used")
+	}
+	if len(o.PaymentMethods) > 0 && o.Group != nil && o.Group.SplitPayment {
+		return fmt.Errorf("split payment orders are paid by card")
+	}
+
+	return nil
+}
+
+// Tenders are the payment methods in the order they're used - paying by card if none are given
+func (o *OrderState) Tenders() []PaymentMethod {
+	if len(o.PaymentMethods) == 0 {
+		return []PaymentMethod{{Type: PaymentMethodCard}}
+	}
+
+	tenders := make([]PaymentMethod, 0, len(o.PaymentMethods))
+	for _, m := range o.PaymentMethods {
+		if m.IsStoredValue() {
+			tenders = append(tenders, m)
+		}
+	}
+	for _, m := range o.PaymentMethods {
+		if !m.IsStoredValue() {
+			tenders = append(tenders, m)
+		}
+	}
+
+	return tenders
+}
+
+// RefundSplit shares the refund between the payments in proportion to how much
+// was paid with each, optionally only those made by the payer
+func RefundSplit(payments []Payment, amountInPence int, payer string) []int {
+	shares := make([]int, len(payments))
+
+	eligible := func(p Payment) bool {
+		return payer == "" || strings.EqualFold(p.Payer, payer)
+	}
+
+	paid := 0
+	refundable := 0
+	for _, p := range payments {
+		if eligible(p) {
+			paid += p.AmountInPence
+			refundable += p.Refundable()
+		}
+	}
+	if paid == 0 {
+		return shares
+	}
+	amountInPence = min(amountInPence, refundable)
+
+	allocated := 0
+	for i, p := range payments {
+		if eligible(p) {
+			shares[i] = min(amountInPence*p.AmountInPence/paid, p.Refundable())
+			allocated += shares[i]
+		}
+	}
+
+	// Pennies lost to rounding go to whatever can still be refunded
+	for i, p := range payments {
+		if allocated == amountInPence {
+			break
+		}
+		if eligible(p) {
+			extra := min(amountInPence-allocated, p.Refundable()-shares[i])
+			shares[i] += extra
+			allocated += extra
+		}
+	}
+
+	return shares
+}
+
+// In-memory ledger - each worker has its own balances, so a card can be spent
+// once on every worker
+type memoryGiftCardLedger struct {
+	mu       sync.Mutex
+	accounts map[string][]GiftCardEntry
+}
+
+func (l *memoryGiftCardLedger) balance(account string) int {
+	total := 0
+	for _, e := range l.accounts[account] {
+		total += e.AmountInPence
+	}
+	return total
+}
+
+func (l *memoryGiftCardLedger) find(account, reference string) *GiftCardEntry {
+	for _, e := range l.accounts[account] {
+		if e.Reference == reference {
+			return &e
+		}
+	}
+	return nil
+}
+
+func (l *memoryGiftCardLedger) exists(account string) bool {
+	_, ok := l.accounts[account]
+	return ok || IsWalletAccount(account)
+}
+
+func (l *memoryGiftCardLedger) add(account, reference string, amountInPence int) {
+	l.accounts[account] = append(l.accounts[account], GiftCardEntry{
+		AmountInPence: amountInPence,
+		CreatedAt:     time.Now(),
+		Reference:     reference,
+	})
+}
+
+func (l *memoryGiftCardLedger) Balance(_ context.Context, account string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.exists(account) {
+		return 0, ErrUnknownGiftCard
+	}
+
+	return l.balance(account), nil
+}
+
+func (l *memoryGiftCardLedger) Credit(_ context.Context, account, reference string, amountInPence int) (int, error) {
+	if amountInPence < 0 {
+		return 0, fmt.Errorf("cannot credit a negative amount")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.exists(account) {
+		return 0, ErrUnknownGiftCard
+	}
+	if l.find(account, reference) == nil {
+		l.add(account, reference, amountInPence)
+	}
+
+	return l.balance(account), nil
+}
+
+func (l *memoryGiftCardLedger) Debit(_ context.Context, account, reference string, amountInPence int) (int, error) {
+	if amountInPence < 0 {
+		return 0, fmt.Errorf("cannot debit a negative amount")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.exists(account) {
+		return 0, ErrUnknownGiftCard
+	}
+	if e := l.find(account, reference); e != nil {
+		return -e.AmountInPence, nil
+	}
+
+	amountInPence = min(amountInPence, l.balance(account))
+	l.add(account, reference, -amountInPence)
+
+	return amountInPence, nil
+}
+
+// NewMemoryGiftCardLedger creates a ledger with the gift cards and their balances
+func NewMemoryGiftCardLedger(giftCards map[string]int) GiftCardLedger {
+	l := &memoryGiftCardLedger{
+		accounts: map[string][]GiftCardEntry{},
+	}
+	for code, balance := range giftCards {
+		l.add(PaymentMethod{Type: PaymentMethodGiftCard, Code: code}.Account(""), "issued", balance)
+	}
+	return l
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryGiftCardLedger(t *testing.T) {
+	l := NewMemoryGiftCardLedger(map[string]int{"GIFT-1000": 1000})
+	account := PaymentMethod{Type: PaymentMethodGiftCard, Code: " gift-1000 "}.Account("")
+
+	debited, err := l.Debit(context.Background(), account, "order-1", 600)
+	assert.NoError(t, err)
+	assert.Equal(t, 600, debited)
+
+	// Retries don't take the money twice
+	debited, err = l.Debit(context.Background(), account, "order-1", 600)
+	assert.NoError(t, err)
+	assert.Equal(t, 600, debited)
+
+	// Only what's left is taken
+	debited, err = l.Debit(context.Background(), account, "order-2", 600)
+	assert.NoError(t, err)
+	assert.Equal(t, 400, debited)
+
+	balance, err := l.Credit(context.Background(), account, "order-1/refund", 250)
+	assert.NoError(t, err)
+	assert.Equal(t, 250, balance)
+
+	_, err = l.Debit(context.Background(), "giftcard/NOPE", "order-3", 100)
+	assert.ErrorIs(t, err, ErrUnknownGiftCard)
+
+	// Wallets exist for every customer, even if they're empty
+	balance, err = l.Balance(context.Background(), PaymentMethod{Type: PaymentMethodWallet}.Account("customer-1"))
+	assert.NoError(t, err)
+	assert.Zero(t, balance)
+}
+
+func TestValidatePaymentMethods(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   OrderState
+		wantErr bool
+	}{
+		{"no methods", OrderState{}, false},
+		{"split tender", OrderState{PaymentMethods: []PaymentMethod{{Type: PaymentMethodGiftCard, Code: "GIFT-1000"}, {Type: PaymentMethodCard}}}, false},
+		{"gift card without code", OrderState{PaymentMethods: []PaymentMethod{{Type: PaymentMethodGiftCard}}}, true},
+		{"two cards", OrderState{PaymentMethods: []PaymentMethod{{Type: PaymentMethodCard}, {Type: PaymentMethodCard}}}, true},
+		{"wallet without customer", OrderState{PaymentMethods: []PaymentMethod{{Type: PaymentMethodWallet}}}, true},
+		{"wallet", OrderState{CustomerID: "customer-1", PaymentMethods: []PaymentMethod{{Type: PaymentMethodWallet}}}, false},
+		{"unknown type", OrderState{PaymentMethods: []PaymentMethod{{Type: "CASH"}}}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.state.ValidatePaymentMethods()
+			assert.Equal(t, test.wantErr, err != nil)
+		})
+	}
+}
+
+func TestTenders(t *testing.T) {
+	assert.Equal(t, []PaymentMethod{{Type: PaymentMethodCard}}, (&OrderState{}).Tenders())
+
+	state := OrderState{PaymentMethods: []PaymentMethod{
+		{Type: PaymentMethodCard},
+		{Type: PaymentMethodGiftCard, Code: "GIFT-1000"},
+	}}
+	assert.Equal(t, []PaymentMethod{
+		{Type: PaymentMethodGiftCard, Code: "GIFT-1000"},
+		{Type: PaymentMethodCard},
+	}, state.Tenders())
+}
+
+func TestRefundSplit(t *testing.T) {
+	payments := []Payment{
+		{AmountInPence: 1000, Method: PaymentMethodGiftCard, Payer: "a"},
+		{AmountInPence: 3000, Method: PaymentMethodCard, Payer: "a"},
+	}
+
+	tests := []struct {
+		name     string
+		payments []Payment
+		amount   int
+		payer    string
+		expected []int
+	}{
+		{"full refund", payments, 4000, "", []int{1000, 3000}},
+		{"partial refund", payments, 2000, "", []int{500, 1500}},
+		{"rounding", payments, 999, "", []int{250, 749}},
+		{"more than paid", payments, 5000, "", []int{1000, 3000}},
+		{"other payer", payments, 1000, "b", []int{0, 0}},
+		{
+			"already refunded",
+			[]Payment{
+				{AmountInPence: 1000, RefundedInPence: 1000},
+				{AmountInPence: 3000},
+			},
+			2000, "", []int{0, 2000},
+		},
+		{
+			"gift card used up",
+			[]Payment{
+				{AmountInPence: 1000, RefundedInPence: 900},
+				{AmountInPence: 1000},
+			},
+			1000, "", []int{100, 900},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, RefundSplit(test.payments, test.amount, test.payer))
+		})
+	}
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Characters that can't be confused with one another when read out
+const joinCodeCharacters = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const joinCodeLength = 6
+
+type Participant struct {
+	Name string `json:"name" pii:"true"`
+	Paid bool   `json:"paid"` // Only used for split payments
+}
+
+type GroupOrder struct {
+	JoinCode     string        `json:"joinCode"` // Shared by the organiser so others can add to the basket
+	Locked       bool          `json:"locked"`   // Basket is closed to changes
+	Organiser    string        `json:"organiser" pii:"true"`
+	Participants []Participant `json:"participants"`
+	SplitPayment bool          `json:"splitPayment"` // Each participant pays for their own items
+}
+
+type JoinRequest struct {
+	JoinCode string `json:"joinCode"`
+	Name     string `json:"name"`
+}
+
+func (g *GroupOrder) GetParticipant(name string) *Participant {
+	for i := range g.Participants {
+		if strings.EqualFold(g.Participants[i].Name, name) {
+			return &g.Participants[i]
+		}
+	}
+	return nil
+}
+
+func (g *GroupOrder) ValidateJoin(req JoinRequest) error {
+	if g.Locked {
+		return fmt.Errorf("basket is locked")
+	}
+	if !strings.EqualFold(g.JoinCode, req.JoinCode) {
+		return fmt.Errorf("invalid join code")
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if g.GetParticipant(req.Name) != nil {
+		return fmt.Errorf("%q has already joined", req.Name)
+	}
+
+	return nil
+}
+
+func (g *GroupOrder) Join(req JoinRequest) {
+	g.Participants = append(g.Participants, Participant{
+		Name: req.Name,
+	})
+}
+
+// UnpaidParticipants lists the participants who still owe for items in the basket
+func (o *OrderState) UnpaidParticipants() []Participant {
+	unpaid := make([]Participant, 0)
+	if o.Group == nil {
+		return unpaid
+	}
+
+	for _, p := range o.Group.Participants {
+		if !p.Paid && o.TotalFor(p.Name) > 0 {
+			unpaid = append(unpaid, p)
+		}
+	}
+
+	return unpaid
+}
+
+// This is random, so must only be called from a side effect inside a workflow
+func generateJoinCode() string {
+	code := make([]byte, joinCodeLength)
+	for i := range code {
+		code[i] = joinCodeCharacters[rand.Intn(len(joinCodeCharacters))]
+	}
+	return string(code)
+}
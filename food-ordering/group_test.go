@@ -0,0 +1,73 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupOrderValidateJoin(t *testing.T) {
+	group := GroupOrder{
+		JoinCode:     "ABC234",
+		Organiser:    "Alice",
+		Participants: []Participant{{Name: "Alice"}},
+	}
+
+	assert.NoError(t, group.ValidateJoin(JoinRequest{JoinCode: "abc234", Name: "Bob"}))
+	assert.ErrorContains(t, group.ValidateJoin(JoinRequest{JoinCode: "XYZ789", Name: "Bob"}), "invalid join code")
+	assert.ErrorContains(t, group.ValidateJoin(JoinRequest{JoinCode: "ABC234", Name: " "}), "name is required")
+	assert.ErrorContains(t, group.ValidateJoin(JoinRequest{JoinCode: "ABC234", Name: "alice"}), "already joined")
+
+	group.Locked = true
+	assert.ErrorContains(t, group.ValidateJoin(JoinRequest{JoinCode: "ABC234", Name: "Bob"}), "locked")
+}
+
+func TestGroupOrderJoin(t *testing.T) {
+	group := GroupOrder{Participants: []Participant{{Name: "Alice"}}}
+	group.Join(JoinRequest{Name: "Bob"})
+
+	require.Len(t, group.Participants, 2)
+	assert.Equal(t, "Bob", group.GetParticipant("BOB").Name)
+	assert.Nil(t, group.GetParticipant("Carol"))
+}
+
+func TestUnpaidParticipants(t *testing.T) {
+	state := NewOrderState()
+	assert.Empty(t, state.UnpaidParticipants())
+
+	state.Group = &GroupOrder{
+		Participants: []Participant{{Name: "Alice", Paid: true}, {Name: "Bob"}, {Name: "Carol"}},
+		SplitPayment: true,
+	}
+	state.AddItem(OrderProduct{ProductID: 1, Quantity: 1, Owner: "Alice"})
+	state.AddItem(OrderProduct{ProductID: 2, Quantity: 1, Owner: "Bob"})
+
+	// Carol's not added anything, so has nothing to pay
+	assert.Equal(t, []Participant{{Name: "Bob"}}, state.UnpaidParticipants())
+}
+
+func TestGenerateJoinCode(t *testing.T) {
+	code := generateJoinCode()
+
+	assert.Len(t, code, joinCodeLength)
+	for _, c := range code {
+		assert.Contains(t, joinCodeCharacters, string(c))
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Exports a workflow's history from a running server into testdata, so the
+// replay tests check the workflow code can still run it.
+//
+//	go run ./history <workflowId> [name]
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mrsimonemms/temporal-demos/codec"
+	"github.com/mrsimonemms/temporal-demos/tracing"
+	"go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/api/temporalproto"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatalln("Usage: history <workflowId> [name]")
+	}
+	workflowID := os.Args[1]
+
+	// The file name describes what the history covers, eg order-rejected
+	name := workflowID
+	if len(os.Args) > 2 {
+		name = os.Args[2]
+	}
+
+	dir := os.Getenv("HISTORY_DIR")
+	if dir == "" {
+		dir = "testdata"
+	}
+
+	// Order details are encrypted before they're stored in the workflow history
+	dataConverter, err := codec.DataConverterFromEnv()
+	if err != nil {
+		log.Fatalln("Unable to create data converter", err)
+	}
+
+	// Traces are only exported if OTEL_TRACES_EXPORTER is set
+	shutdownTracing, err := tracing.Setup(context.Background(), "food-ordering-history")
+	if err != nil {
+		log.Fatalln("Unable to configure tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	tracingInterceptor, err := tracing.NewInterceptor()
+	if err != nil {
+		log.Fatalln("Unable to create tracing interceptor", err)
+	}
+
+	c, err := client.Dial(client.Options{
+		DataConverter: dataConverter,
+		HostPort:      os.Getenv("TEMPORAL_ADDRESS"),
+		Interceptors:  []interceptor.ClientInterceptor{tracingInterceptor},
+		Namespace:     os.Getenv("TEMPORAL_NAMESPACE"),
+	})
+	if err != nil {
+		log.Fatalln("Unable to create client", err)
+	}
+	defer c.Close()
+
+	// Latest run - in-flight orders are worth recording too
+	history := &historypb.History{}
+	iter := c.GetWorkflowHistory(context.Background(), workflowID, "", false, enums.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		if err != nil {
+			log.Fatalln("Unable to get history", err)
+		}
+		history.Events = append(history.Events, event)
+	}
+
+	data, err := temporalproto.CustomJSONMarshalOptions{Indent: "  "}.Marshal(history)
+	if err != nil {
+		log.Fatalln("Unable to encode history", err)
+	}
+
+	file := filepath.Join(dir, strings.ReplaceAll(name, "/", "-")+".json")
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		log.Fatalln("Unable to write history", err)
+	}
+
+	log.Println("Exported history", "workflowId", workflowID, "events", len(history.Events), "file", file)
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/webhook"
+)
+
+// Where the worker sends the order changes
+const changeFeedPath = "/internal/order-changes"
+
+// WithChangeFeed lets the worker push the order changes to the event streams,
+// signed with the secret. The streams are only served with the change feed, as
+// they'd otherwise have to query the orders for every change.
+func WithChangeFeed(secret string) Option {
+	return func(s *Server) {
+		s.changeSecret = secret
+	}
+}
+
+func (s *Server) changeRoutes() {
+	if s.changeSecret == "" {
+		return
+	}
+
+	s.mux.HandleFunc("POST "+changeFeedPath, s.receiveChange)
+	s.mux.HandleFunc("GET /orders/{orderId}/events", s.streamOrder)
+	s.mux.HandleFunc("GET /events", s.streamKitchen)
+}
+
+func (s *Server) receiveChange(w http.ResponseWriter, r *http.Request) {
+	body, err := webhook.VerifyRequest(r, s.changeSecret, webhook.DefaultTolerance)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var change foodordering.OrderChange
+	if err := json.Unmarshal(body, &change); err != nil {
+		http.Error(w, "invalid order change", http.StatusBadRequest)
+		return
+	}
+
+	s.events.receive(change)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ChangePublisher sends the order changes to the API's event streams. Only the
+// API at the URL gets them - any others find the changes by querying the orders.
+type ChangePublisher struct {
+	Client *http.Client
+	Secret string
+	URL    string // Base URL of the API
+}
+
+var _ foodordering.EventPublisher = &ChangePublisher{}
+
+func (p *ChangePublisher) Publish(ctx context.Context, change foodordering.OrderChange) error {
+	body, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("error encoding order change: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL+changeFeedPath, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.IDHeader, change.ID)
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(p.Secret, body, time.Now()))
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending order change: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("order change returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func NewChangePublisher(url, secret string) *ChangePublisher {
+	return &ChangePublisher{
+		Client: &http.Client{Timeout: time.Second * 10},
+		Secret: secret,
+		URL:    strings.TrimSuffix(url, "/"),
+	}
+}
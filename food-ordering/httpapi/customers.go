@@ -0,0 +1,101 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpapi
+
+import (
+	"net/http"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+)
+
+// Creates the customer or changes their contact details
+func (s *Server) saveCustomer(w http.ResponseWriter, r *http.Request) {
+	var req ContactRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.orders.SaveCustomer(r.Context(), r.PathValue("customerId"), req.ContactDetails()); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getCustomer(w http.ResponseWriter, r *http.Request) {
+	customer, err := s.orders.GetCustomer(r.Context(), r.PathValue("customerId"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, customer)
+}
+
+func (s *Server) saveAddress(w http.ResponseWriter, r *http.Request) {
+	var req AddressRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.orders.SaveAddress(r.Context(), r.PathValue("customerId"), foodordering.SavedAddress{
+		Address: req.Address,
+		Default: req.Default,
+		Label:   r.PathValue("label"),
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) removeAddress(w http.ResponseWriter, r *http.Request) {
+	if err := s.orders.RemoveAddress(r.Context(), r.PathValue("customerId"), r.PathValue("label")); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Starts a new order from one in the customer's history. It waits for checkout,
+// so the basket can be changed first.
+func (s *Server) reorder(w http.ResponseWriter, r *http.Request) {
+	var req ReorderRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	orderID, err := s.orders.Reorder(r.Context(), r.PathValue("customerId"), foodordering.ReorderRequest{
+		DeliveryAddress: req.DeliveryAddress,
+		OrderID:         req.OrderID,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/orders/"+orderID)
+	writeJSON(w, http.StatusCreated, CreateOrderResponse{
+		OrderID: orderID,
+	})
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/projection"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/operatorservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+)
+
+// TestOrderEndToEnd takes an order from the API, through a real worker, to the
+// read model. It starts a Temporal dev server, downloading the CLI the first
+// time - set TEMPORAL_CLI_PATH to use an installed CLI instead.
+func TestOrderEndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a dev server")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+
+	server, err := testsuite.StartDevServer(ctx, testsuite.DevServerOptions{
+		ClientOptions: &client.Options{},
+		ExistingPath:  os.Getenv("TEMPORAL_CLI_PATH"),
+	})
+	if err != nil && os.Getenv("TEMPORAL_CLI_PATH") == "" {
+		// Most likely offline
+		t.Skipf("unable to download the Temporal CLI: %v", err)
+	}
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, server.Stop())
+	}()
+	c := server.Client()
+
+	attributes := map[string]enums.IndexedValueType{}
+	for _, key := range foodordering.SearchAttributeKeys {
+		attributes[key.GetName()] = key.GetValueType()
+	}
+	_, err = c.OperatorService().AddSearchAttributes(ctx, &operatorservice.AddSearchAttributesRequest{
+		Namespace:        client.DefaultNamespace,
+		SearchAttributes: attributes,
+	})
+	require.NoError(t, err)
+
+	store, err := projection.Open(ctx, filepath.Join(t.TempDir(), "orders.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	// The worker as it's run without a payment provider, so payments are approved
+	activities, err := foodordering.NewActivities(
+		foodordering.WithEventPublisher(store),
+		foodordering.WithRiskHistory(store),
+		foodordering.WithSalesReporter(store),
+	)
+	require.NoError(t, err)
+
+	w := worker.New(c, foodordering.OrderFoodTaskQueue, worker.Options{})
+	w.RegisterWorkflow(foodordering.OrderWorkflow)
+	w.RegisterWorkflow(foodordering.CustomerWorkflow)
+	w.RegisterActivity(activities)
+	require.NoError(t, w.Start())
+	defer w.Stop()
+
+	s := New(c, WithReadModel(store))
+
+	rec, _ := request(t, s, http.MethodPost, "/orders", `{
+		"collection": true,
+		"email": "test@test.com",
+		"expressCheckout": true,
+		"products": [{"productId": 1, "quantity": 2}]
+	}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var created CreateOrderResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+
+	status := func() foodordering.OrderStatus {
+		rec, _ := request(t, s, http.MethodGet, "/orders/"+created.OrderID+"/status", "")
+		if rec.Code != http.StatusOK {
+			return ""
+		}
+
+		var resp StatusResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		return resp.Status
+	}
+
+	// Express checkout pays straight away, then it waits for the restaurant
+	require.Eventually(t, func() bool {
+		return status() == foodordering.OrderStatusPending
+	}, time.Second*30, time.Millisecond*100)
+
+	for _, next := range []foodordering.OrderStatus{
+		foodordering.OrderStatusAccepted,
+		foodordering.OrderStatusPreparing,
+		foodordering.OrderStatusReady,
+		foodordering.OrderStatusCompleted,
+	} {
+		rec, errResp := request(t, s, http.MethodPut, "/orders/"+created.OrderID+"/status", fmt.Sprintf(`{"status": %q}`, next))
+		require.Equal(t, http.StatusNoContent, rec.Code, errResp)
+		assert.Equal(t, next, status())
+	}
+
+	// The outbox projects the order into the read model without holding it up
+	require.Eventually(t, func() bool {
+		rec, _ := request(t, s, http.MethodGet, "/reports/orders/"+created.OrderID, "")
+		if rec.Code != http.StatusOK {
+			return false
+		}
+
+		var order projection.Order
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&order))
+		return order.Status == foodordering.OrderStatusCompleted && order.CompletedAt != nil
+	}, time.Second*30, time.Millisecond*100)
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/mrsimonemms/temporal-demos/food-ordering/orderclient"
+)
+
+// Error codes returned in the error body
+const (
+	CodeAlreadyCompleted = "ALREADY_COMPLETED"
+	CodeInternal         = "INTERNAL"
+	CodeInvalidRequest   = "INVALID_REQUEST"
+	CodeNotFound         = "NOT_FOUND"
+	CodeRejected         = "REJECTED"
+)
+
+// Every error is returned in this format
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors in the request made by the caller
+type requestError struct {
+	message string
+}
+
+func (e requestError) Error() string {
+	return e.message
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Println("Error writing response", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	code := CodeInternal
+	message := "internal server error"
+
+	var reqErr requestError
+	switch {
+	case errors.As(err, &reqErr):
+		status = http.StatusBadRequest
+		code = CodeInvalidRequest
+		message = err.Error()
+	case errors.Is(err, orderclient.ErrNotFound):
+		status = http.StatusNotFound
+		code = CodeNotFound
+		message = err.Error()
+	case errors.Is(err, orderclient.ErrAlreadyCompleted):
+		status = http.StatusConflict
+		code = CodeAlreadyCompleted
+		message = err.Error()
+	case errors.Is(err, orderclient.ErrValidationRejected):
+		status = http.StatusUnprocessableEntity
+		code = CodeRejected
+		message = err.Error()
+	default:
+		// Don't leak internal errors to the caller
+		log.Println("Error handling request", err)
+	}
+
+	writeJSON(w, status, ErrorResponse{
+		Error: ErrorBody{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
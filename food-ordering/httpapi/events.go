@@ -0,0 +1,580 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/orderclient"
+)
+
+const (
+	// Sent as a comment to stop proxies closing idle streams
+	keepaliveInterval = 15 * time.Second
+	// How long finished orders are remembered by the kitchen stream, so they're
+	// not watched again whilst they're still listed as running
+	finishedTTL = time.Minute
+	// The worker pushes the changes, so watched orders are only queried, and the
+	// running orders listed, to catch any that were missed
+	reconcileInterval = 30 * time.Second
+	// Old events are dropped for subscribers this far behind - each event has
+	// the full state, so the newest is all they need
+	subscriberBuffer = 16
+)
+
+// Event is a status change on an order, pushed to subscribers
+type Event struct {
+	foodordering.OrderEvent
+
+	OrderID string `json:"orderId"`
+}
+
+// subscription receives an order's events until the order's workflow finishes,
+// when the channel is closed
+type subscription struct {
+	events chan Event
+	closed bool
+}
+
+// kitchen is told about the running orders, which it then subscribes to
+type kitchen struct {
+	notify chan struct{}
+	orders map[string]struct{} // Found since the kitchen last took them
+}
+
+// Adds the orders for the kitchen to take. Must be called with the lock held.
+func (k *kitchen) add(orderIDs ...string) {
+	for _, orderID := range orderIDs {
+		k.orders[orderID] = struct{}{}
+	}
+
+	select {
+	case k.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Each order being watched has a single feed, however many subscribers it has
+type feed struct {
+	cancel      context.CancelFunc
+	latest      *Event
+	subscribers map[*subscription]struct{}
+}
+
+// broker watches the orders, fanning the changes out to the subscribers. The
+// changes are pushed by the worker's PublishOrderChange activity, with the
+// orders queried in case any are missed - neither adds to the order's history.
+// The running orders are listed once for all the kitchens.
+type broker struct {
+	mu               sync.Mutex
+	discoverInterval time.Duration
+	feeds            map[string]*feed
+	kitchens         map[*kitchen]struct{}
+	orders           *orderclient.Client
+	pollInterval     time.Duration
+	running          []string // Last listed, for kitchens that connect in between
+	stopDiscovery    context.CancelFunc
+}
+
+// subscribe sends the order's events to the subscription until unsubscribed.
+// If the order is already being watched, the latest event is returned.
+func (b *broker) subscribe(orderID string) (sub *subscription, latest *Event, unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	f, ok := b.feeds[orderID]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		f = &feed{
+			cancel:      cancel,
+			subscribers: map[*subscription]struct{}{},
+		}
+		b.feeds[orderID] = f
+
+		go b.run(ctx, orderID, f)
+	}
+
+	sub = &subscription{events: make(chan Event, subscriberBuffer)}
+	f.subscribers[sub] = struct{}{}
+
+	return sub, f.latest, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(f.subscribers, sub)
+		if !sub.closed {
+			sub.closed = true
+			close(sub.events)
+		}
+		if len(f.subscribers) == 0 {
+			// Nobody's listening - stop watching the order
+			f.cancel()
+			if b.feeds[orderID] == f {
+				delete(b.feeds, orderID)
+			}
+		}
+	}
+}
+
+// subscribeKitchen tells the kitchen about the running orders until
+// unsubscribed. The orders are listed whilst any kitchen is subscribed.
+func (b *broker) subscribeKitchen() (k *kitchen, unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	k = &kitchen{
+		notify: make(chan struct{}, 1),
+		orders: map[string]struct{}{},
+	}
+	if len(b.kitchens) == 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		b.stopDiscovery = cancel
+
+		go b.discover(ctx)
+	} else {
+		k.add(b.running...)
+	}
+	b.kitchens[k] = struct{}{}
+
+	return k, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.kitchens, k)
+		if len(b.kitchens) == 0 {
+			b.stopDiscovery()
+			b.running = nil
+		}
+	}
+}
+
+// takeOrders returns the orders found since the kitchen last took them
+func (b *broker) takeOrders(k *kitchen) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	orderIDs := make([]string, 0, len(k.orders))
+	for orderID := range k.orders {
+		orderIDs = append(orderIDs, orderID)
+	}
+	clear(k.orders)
+
+	return orderIDs
+}
+
+// discover lists the running orders for the kitchens until they've all gone
+func (b *broker) discover(ctx context.Context) {
+	for {
+		orderIDs, err := b.orders.ListRunning(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Println("Error finding orders", "error", err)
+		}
+		if err == nil {
+			b.mu.Lock()
+			// The kitchens may have gone whilst the orders were listed
+			if ctx.Err() == nil {
+				b.running = orderIDs
+				for k := range b.kitchens {
+					k.add(orderIDs...)
+				}
+			}
+			b.mu.Unlock()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(b.discoverInterval):
+		}
+	}
+}
+
+// Sends the event, dropping the oldest one if the subscriber's behind. Must be
+// called with the lock held, as only the broker sends to the channel.
+func (sub *subscription) send(event Event) {
+	if sub.closed {
+		return
+	}
+	for {
+		select {
+		case sub.events <- event:
+			return
+		default:
+		}
+
+		select {
+		case <-sub.events:
+		default:
+		}
+	}
+}
+
+// publish sends the event to the subscribers if it's newer than the last one
+func (b *broker) publish(f *feed, event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if f.latest != nil && event.EventID <= f.latest.EventID {
+		return
+	}
+	f.latest = &event
+
+	for sub := range f.subscribers {
+		sub.send(event)
+	}
+}
+
+// receive passes on a change pushed by the worker
+func (b *broker) receive(change foodordering.OrderChange) {
+	b.mu.Lock()
+	f, ok := b.feeds[change.OrderID]
+	if !ok {
+		// Nobody's watching the order - it may be new, so tell the kitchens
+		for k := range b.kitchens {
+			k.add(change.OrderID)
+		}
+	}
+	b.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	b.publish(f, Event{
+		OrderEvent: foodordering.OrderEvent{EventID: change.EventID, State: change.Order},
+		OrderID:    change.OrderID,
+	})
+}
+
+// poll queries the order, returning true once its workflow has finished
+func (b *broker) poll(ctx context.Context, orderID string, f *feed) (bool, error) {
+	state, err := b.orders.GetState(ctx, orderID)
+	if err != nil {
+		return errors.Is(err, orderclient.ErrNotFound), err
+	}
+	b.publish(f, Event{
+		OrderEvent: foodordering.OrderEvent{EventID: state.LastEventID(), State: *state},
+		OrderID:    orderID,
+	})
+
+	// Orders carry on after their last status, eg to take feedback or for ops
+	// to finish a refund, so only the workflow finishing ends the feed
+	if !state.Status.IsTerminal() {
+		return false, nil
+	}
+	running, err := b.orders.IsRunning(ctx, orderID)
+	if err != nil {
+		return false, err
+	}
+	if running {
+		return false, nil
+	}
+
+	// The workflow may have changed between the query and finishing
+	if state, err := b.orders.GetState(ctx, orderID); err == nil {
+		b.publish(f, Event{
+			OrderEvent: foodordering.OrderEvent{EventID: state.LastEventID(), State: *state},
+			OrderID:    orderID,
+		})
+	}
+	return true, nil
+}
+
+// run watches the order until it finishes or nobody is listening
+func (b *broker) run(ctx context.Context, orderID string, f *feed) {
+	defer func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if b.feeds[orderID] == f {
+			delete(b.feeds, orderID)
+		}
+
+		// Closing the channels can't be missed, unlike an event
+		for sub := range f.subscribers {
+			sub.closed = true
+			close(sub.events)
+		}
+	}()
+
+	for {
+		finished, err := b.poll(ctx, orderID, f)
+		if finished || ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Println("Error polling order", "orderId", orderID, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(b.pollInterval):
+		}
+	}
+}
+
+func newBroker(orders *orderclient.Client) *broker {
+	return &broker{
+		discoverInterval: reconcileInterval,
+		feeds:            map[string]*feed{},
+		kitchens:         map[*kitchen]struct{}{},
+		orders:           orders,
+		pollInterval:     reconcileInterval,
+	}
+}
+
+// sse writes server-sent events to the response
+type sse struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (s *sse) send(id, event string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error encoding event: %w", err)
+	}
+
+	if id != "" {
+		if _, err := fmt.Fprintf(s.w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, body); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+
+	return nil
+}
+
+func (s *sse) keepalive() error {
+	if _, err := fmt.Fprint(s.w, ": keepalive\n\n"); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+
+	return nil
+}
+
+func newSSE(w http.ResponseWriter) (*sse, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("streaming not supported")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &sse{w: w, flusher: flusher}, nil
+}
+
+// Streams an order's status changes to the customer. The current state is
+// sent first, unless the client is reconnecting and has already seen it.
+func (s *Server) streamOrder(w http.ResponseWriter, r *http.Request) {
+	orderID := r.PathValue("orderId")
+
+	lastEventID := 0
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		var err error
+		if lastEventID, err = strconv.Atoi(id); err != nil || lastEventID < 0 {
+			writeError(w, requestError{message: "Last-Event-ID: must be an event ID"})
+			return
+		}
+	}
+
+	// Check the order exists before starting the stream
+	state, err := s.orders.GetState(r.Context(), orderID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	sub, latest, unsubscribe := s.events.subscribe(orderID)
+	defer unsubscribe()
+
+	stream, err := newSSE(w)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	send := func(event foodordering.OrderEvent) error {
+		if event.EventID <= lastEventID {
+			return nil
+		}
+		lastEventID = event.EventID
+
+		return stream.send(strconv.Itoa(event.EventID), "status", event)
+	}
+
+	if err := send(foodordering.OrderEvent{EventID: state.LastEventID(), State: *state}); err != nil {
+		return
+	}
+	// The order may have changed since the state was read
+	if latest != nil {
+		if err := send(latest.OrderEvent); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(keepaliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if err := stream.keepalive(); err != nil {
+				return
+			}
+		case event, ok := <-sub.events:
+			if !ok {
+				// The order's finished
+				return
+			}
+			if err := send(event.OrderEvent); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Streams status changes for every running order to the kitchen. Kitchen
+// screens get the current state of all orders whenever they connect, so the
+// events have no ID to resume from.
+func (s *Server) streamKitchen(w http.ResponseWriter, r *http.Request) {
+	stream, err := newSSE(w)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Every order's events are forwarded here, with the ID of any that finish
+	events := make(chan Event)
+	finished := make(chan string)
+
+	// Orders being watched, with the last event sent, and the orders that have
+	// finished recently
+	lastSent := map[string]int{}
+	finishedAt := map[string]time.Time{}
+	unsubscribes := map[string]func(){}
+	defer func() {
+		for _, unsubscribe := range unsubscribes {
+			unsubscribe()
+		}
+	}()
+
+	send := func(event Event) error {
+		if last, ok := lastSent[event.OrderID]; !ok || event.EventID <= last {
+			return nil
+		}
+		lastSent[event.OrderID] = event.EventID
+
+		return stream.send("", "status", event)
+	}
+
+	watch := func(orderID string, sub *subscription) {
+		for event := range sub.events {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case finished <- orderID:
+		case <-ctx.Done():
+		}
+	}
+
+	k, unsubscribeKitchen := s.events.subscribeKitchen()
+	defer unsubscribeKitchen()
+
+	discover := func() error {
+		for orderID, t := range finishedAt {
+			if time.Since(t) > finishedTTL {
+				delete(finishedAt, orderID)
+			}
+		}
+
+		for _, orderID := range s.events.takeOrders(k) {
+			if _, ok := lastSent[orderID]; ok {
+				continue
+			}
+			if _, ok := finishedAt[orderID]; ok {
+				continue
+			}
+			lastSent[orderID] = 0
+
+			sub, latest, unsubscribe := s.events.subscribe(orderID)
+			unsubscribes[orderID] = unsubscribe
+			go watch(orderID, sub)
+
+			if latest != nil {
+				if err := send(*latest); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	keepaliveTicker := time.NewTicker(keepaliveInterval)
+	defer keepaliveTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-k.notify:
+			if err := discover(); err != nil {
+				return
+			}
+		case <-keepaliveTicker.C:
+			if err := stream.keepalive(); err != nil {
+				return
+			}
+		case orderID := <-finished:
+			unsubscribes[orderID]()
+			delete(unsubscribes, orderID)
+			delete(lastSent, orderID)
+			finishedAt[orderID] = time.Now()
+		case event := <-events:
+			if err := send(event); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/orderclient"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/projection"
+)
+
+// Option configures the server
+type Option func(s *Server)
+
+// WithReadModel enables the reports, which use the projected orders rather than
+// Temporal visibility
+func WithReadModel(store *projection.Store) Option {
+	return func(s *Server) {
+		s.readModel = store
+	}
+}
+
+func (s *Server) reportRoutes() {
+	if s.readModel == nil {
+		return
+	}
+
+	s.mux.HandleFunc("GET /reports/orders", s.reportOrders)
+	s.mux.HandleFunc("GET /reports/orders/{orderId}", s.reportOrder)
+	s.mux.HandleFunc("GET /reports/products", s.reportProducts)
+	s.mux.HandleFunc("GET /reports/summary", s.reportSummary)
+}
+
+// Accepts dates or full timestamps
+func parseReportTime(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, requestError{message: fmt.Sprintf("%s: must be a date or RFC3339 time", name)}
+}
+
+func parseReportFilter(r *http.Request) (projection.OrderFilter, error) {
+	q := r.URL.Query()
+
+	filter := projection.OrderFilter{
+		RestaurantID: q.Get("restaurantId"),
+	}
+
+	var err error
+	if filter.From, err = parseReportTime("from", q.Get("from")); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseReportTime("to", q.Get("to")); err != nil {
+		return filter, err
+	}
+
+	if v := q.Get("status"); v != "" {
+		if filter.Status, err = foodordering.ParseOrderStatus(v); err != nil {
+			return filter, requestError{message: fmt.Sprintf("status: %s", err)}
+		}
+	}
+
+	filter.Limit = defaultPageSize
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 1 || filter.Limit > maxPageSize {
+			return filter, requestError{message: fmt.Sprintf("limit: must be between 1 and %d", maxPageSize)}
+		}
+	}
+
+	return filter, nil
+}
+
+func (s *Server) reportOrders(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseReportFilter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	orders, err := s.readModel.ListOrders(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, orders)
+}
+
+func (s *Server) reportOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := s.readModel.GetOrder(r.Context(), r.PathValue("orderId"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if order == nil {
+		writeError(w, fmt.Errorf("%w: %s", orderclient.ErrNotFound, r.PathValue("orderId")))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
+
+func (s *Server) reportProducts(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseReportFilter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	products, err := s.readModel.ProductSales(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, products)
+}
+
+func (s *Server) reportSummary(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseReportFilter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	summary, err := s.readModel.Summary(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, summary)
+}
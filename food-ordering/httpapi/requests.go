@@ -0,0 +1,416 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/orderclient"
+)
+
+const (
+	// Largest request body accepted
+	maxBodySize = 1 << 20
+
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type AddressRequest struct {
+	Address foodordering.Address `json:"address" pii:"true"`
+	Default bool                 `json:"default"`
+}
+
+type ContactRequest struct {
+	Email string `json:"email" pii:"true"`
+	Name  string `json:"name"`
+	Phone string `json:"phone" pii:"true"`
+}
+
+type CreateGroupRequest struct {
+	Organiser    string `json:"organiser"`
+	SplitPayment bool   `json:"splitPayment"`
+}
+
+type CreateOrderRequest struct {
+	Collection      bool                         `json:"collection"`
+	CustomerID      string                       `json:"customerId"`
+	DeliveryAddress *foodordering.Address        `json:"deliveryAddress" pii:"true"`
+	Email           string                       `json:"email" pii:"true"`
+	ExpressCheckout bool                         `json:"expressCheckout"`
+	FulfilmentTime  *time.Time                   `json:"fulfilmentTime"`
+	Group           *CreateGroupRequest          `json:"group"`
+	Notes           string                       `json:"notes"`
+	PaymentMethods  []foodordering.PaymentMethod `json:"paymentMethods"`
+	Products        []foodordering.OrderProduct  `json:"products"`
+	RedeemPoints    int                          `json:"redeemPoints"`
+}
+
+type CreateOrderResponse struct {
+	OrderID string `json:"orderId"`
+}
+
+type InterventionRequest struct {
+	Action    foodordering.InterventionAction `json:"action"`
+	Notes     string                          `json:"notes"`
+	Operator  string                          `json:"operator" pii:"true"`
+	Reference string                          `json:"reference"`
+}
+
+type ItemRequest struct {
+	Modifiers []string `json:"modifiers"`
+	Notes     string   `json:"notes"`
+	Owner     string   `json:"owner"`
+	ProductID int      `json:"productId"`
+	Quantity  int      `json:"quantity"`
+}
+
+type ListOrdersResponse struct {
+	NextPageToken string                      `json:"nextPageToken,omitempty"`
+	Orders        []orderclient.OrderListItem `json:"orders"`
+}
+
+type ReorderRequest struct {
+	DeliveryAddress *foodordering.Address `json:"deliveryAddress" pii:"true"` // Delivers to the previous order's address if not set
+	OrderID         string                `json:"orderId"`
+}
+
+type RiskReviewRequest struct {
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason"`
+	Reviewer string `json:"reviewer" pii:"true"`
+}
+
+type StatusRequest struct {
+	Status string `json:"status"`
+}
+
+type TipRequest struct {
+	AmountInPence int `json:"amountInPence"`
+}
+
+type StatusResponse struct {
+	OrderID string                   `json:"orderId"`
+	Status  foodordering.OrderStatus `json:"status"`
+}
+
+func (r AddressRequest) Validate() error {
+	if r.Address.AddressLine1 == "" || r.Address.PostCode == "" {
+		return requestError{message: "address: line1 and postCode are required"}
+	}
+	return nil
+}
+
+func (r ContactRequest) Validate() error {
+	if _, err := mail.ParseAddress(r.Email); err != nil {
+		return requestError{message: "email: invalid email address"}
+	}
+	return nil
+}
+
+func (r ContactRequest) ContactDetails() foodordering.ContactDetails {
+	return foodordering.ContactDetails{
+		Email: r.Email,
+		Name:  r.Name,
+		Phone: r.Phone,
+	}
+}
+
+func (r CreateOrderRequest) Validate() error {
+	if _, err := mail.ParseAddress(r.Email); err != nil {
+		return requestError{message: "email: invalid email address"}
+	}
+
+	if !r.Collection {
+		if r.DeliveryAddress == nil {
+			return requestError{message: "deliveryAddress: required for delivery"}
+		}
+		if r.DeliveryAddress.AddressLine1 == "" || r.DeliveryAddress.PostCode == "" {
+			return requestError{message: "deliveryAddress: line1 and postCode are required"}
+		}
+	}
+
+	if r.FulfilmentTime != nil {
+		if err := foodordering.DefaultRestaurant().ValidateFulfilmentTime(*r.FulfilmentTime, time.Now()); err != nil {
+			return requestError{message: fmt.Sprintf("fulfilmentTime: %s", err)}
+		}
+	}
+
+	if r.Group != nil && r.Group.Organiser == "" {
+		return requestError{message: "group.organiser: required"}
+	}
+
+	if r.ExpressCheckout {
+		if r.Group != nil {
+			return requestError{message: "expressCheckout: not available for group orders"}
+		}
+		if len(r.Products) == 0 {
+			return requestError{message: "expressCheckout: products are required"}
+		}
+	}
+
+	if r.RedeemPoints < 0 {
+		return requestError{message: "redeemPoints: cannot be negative"}
+	}
+	if r.RedeemPoints > 0 && r.CustomerID == "" {
+		return requestError{message: "redeemPoints: customerId is required"}
+	}
+
+	for i, p := range r.Products {
+		if err := p.Validate(); err != nil {
+			return requestError{message: fmt.Sprintf("products[%d]: %s", i, err)}
+		}
+	}
+
+	state := r.OrderState()
+	if err := state.ValidatePaymentMethods(); err != nil {
+		return requestError{message: fmt.Sprintf("paymentMethods: %s", err)}
+	}
+
+	return nil
+}
+
+func (r CreateOrderRequest) OrderState() foodordering.OrderState {
+	state := foodordering.NewOrderState()
+	state.Collection = r.Collection
+	state.CustomerID = r.CustomerID
+	state.DeliveryAddress = r.DeliveryAddress
+	state.Email = r.Email
+	state.ExpressCheckout = r.ExpressCheckout
+	state.FulfilmentTime = r.FulfilmentTime
+	state.Notes = r.Notes
+	state.PaymentMethods = r.PaymentMethods
+	state.RedeemPoints = r.RedeemPoints
+
+	if r.Group != nil {
+		state.Group = &foodordering.GroupOrder{
+			Organiser:    r.Group.Organiser,
+			SplitPayment: r.Group.SplitPayment,
+		}
+	}
+
+	for _, p := range r.Products {
+		state.AddItem(p)
+	}
+
+	return state
+}
+
+func (r InterventionRequest) Validate() error {
+	if err := r.InterventionRequest("").Validate(); err != nil {
+		return requestError{message: err.Error()}
+	}
+	return nil
+}
+
+func (r InterventionRequest) InterventionRequest(interventionID string) foodordering.InterventionRequest {
+	return foodordering.InterventionRequest{
+		Action:         r.Action,
+		InterventionID: interventionID,
+		Notes:          r.Notes,
+		Operator:       r.Operator,
+		Reference:      r.Reference,
+	}
+}
+
+func (r ItemRequest) Validate() error {
+	if err := r.OrderProduct().Validate(); err != nil {
+		return requestError{message: err.Error()}
+	}
+	return nil
+}
+
+func (r ItemRequest) OrderProduct() foodordering.OrderProduct {
+	return foodordering.OrderProduct{
+		Modifiers: r.Modifiers,
+		Notes:     r.Notes,
+		Owner:     r.Owner,
+		ProductID: r.ProductID,
+		Quantity:  r.Quantity,
+	}
+}
+
+func (r ReorderRequest) Validate() error {
+	if r.OrderID == "" {
+		return requestError{message: "orderId: required"}
+	}
+	if r.DeliveryAddress != nil && (r.DeliveryAddress.AddressLine1 == "" || r.DeliveryAddress.PostCode == "") {
+		return requestError{message: "deliveryAddress: line1 and postCode are required"}
+	}
+	return nil
+}
+
+func (r RiskReviewRequest) Validate() error {
+	if strings.TrimSpace(r.Reviewer) == "" {
+		return requestError{message: "reviewer: required"}
+	}
+	return nil
+}
+
+func (r TipRequest) Validate() error {
+	if err := (foodordering.TipRequest{AmountInPence: r.AmountInPence}).Validate(foodordering.DefaultRestaurant().Tips); err != nil {
+		return requestError{message: fmt.Sprintf("amountInPence: %s", err)}
+	}
+	return nil
+}
+
+func (r StatusRequest) Validate() error {
+	if _, err := foodordering.ParseOrderStatus(r.Status); err != nil {
+		return requestError{message: fmt.Sprintf("status: %s", err)}
+	}
+	return nil
+}
+
+// parseListFilter reads the filter for listing orders from the query string
+func parseListFilter(r *http.Request) (orderclient.ListFilter, error) {
+	q := r.URL.Query()
+
+	filter := orderclient.ListFilter{
+		CustomerEmail: q.Get("email"),
+		PageSize:      defaultPageSize,
+		RestaurantID:  q.Get("restaurantId"),
+		Running:       q.Get("running") == "true",
+	}
+
+	if v := q.Get("status"); v != "" {
+		status, err := foodordering.ParseOrderStatus(v)
+		if err != nil {
+			return filter, requestError{message: fmt.Sprintf("status: %s", err)}
+		}
+		filter.Status = status
+	}
+
+	if v := q.Get("collection"); v != "" {
+		collection, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, requestError{message: "collection: must be true or false"}
+		}
+		filter.Collection = &collection
+	}
+
+	if v := q.Get("pageSize"); v != "" {
+		pageSize, err := strconv.Atoi(v)
+		if err != nil || pageSize < 1 || pageSize > maxPageSize {
+			return filter, requestError{message: fmt.Sprintf("pageSize: must be between 1 and %d", maxPageSize)}
+		}
+		filter.PageSize = pageSize
+	}
+
+	if v := q.Get("pageToken"); v != "" {
+		token, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil {
+			return filter, requestError{message: "pageToken: invalid"}
+		}
+		filter.PageToken = token
+	}
+
+	if _, err := filter.Query(); err != nil {
+		return filter, requestError{message: err.Error()}
+	}
+
+	return filter, nil
+}
+
+// ParseTrustedProxies reads a comma-separated list of proxy IP addresses and
+// CIDR ranges
+func ParseTrustedProxies(value string) ([]netip.Prefix, error) {
+	proxies := make([]netip.Prefix, 0)
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		if strings.Contains(v, "/") {
+			prefix, err := netip.ParsePrefix(v)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing trusted proxy %q: %w", v, err)
+			}
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing trusted proxy %q: %w", v, err)
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return proxies, nil
+}
+
+func isTrustedProxy(proxies []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range proxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP is where the request came from. X-Forwarded-For is only used when
+// the request came through a trusted proxy, and then only up to the first hop
+// that isn't trusted - anything to the left of that could've been made up.
+func clientIP(r *http.Request, proxies []netip.Prefix) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	hops := make([]string, 0)
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+
+	for i := len(hops) - 1; i >= 0 && isTrustedProxy(proxies, ip); i-- {
+		if hops[i] == "" {
+			break
+		}
+		ip = hops[i]
+	}
+
+	return ip
+}
+
+// decodeJSON reads the request body, rejecting anything that isn't in the schema
+func decodeJSON(r *http.Request, v interface{ Validate() error }) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return requestError{message: fmt.Sprintf("invalid JSON body: %s", err)}
+	}
+
+	return v.Validate()
+}
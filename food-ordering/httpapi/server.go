@@ -0,0 +1,334 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package httpapi is a REST API for the food ordering workflow
+package httpapi
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"net/http"
+	"net/netip"
+	"strconv"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/orderclient"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/projection"
+	"go.temporal.io/sdk/client"
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+type Server struct {
+	changeSecret   string // Optional - the event streams are disabled if not set
+	events         *broker
+	mux            *http.ServeMux
+	orders         *orderclient.Client
+	readModel      *projection.Store // Optional - reports are disabled if not set
+	trustedProxies []netip.Prefix    // X-Forwarded-For is ignored unless set
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /openapi.json", s.getOpenAPI)
+
+	s.mux.HandleFunc("GET /orders", s.listOrders)
+	s.mux.HandleFunc("POST /orders", s.createOrder)
+	s.mux.HandleFunc("GET /orders/{orderId}", s.getOrder)
+	s.mux.HandleFunc("POST /orders/{orderId}/items", s.addItem)
+	s.mux.HandleFunc("DELETE /orders/{orderId}/items/{productId}", s.removeItem)
+	s.mux.HandleFunc("POST /orders/{orderId}/checkout", s.checkout)
+	s.mux.HandleFunc("POST /orders/{orderId}/cancel", s.cancel)
+	s.mux.HandleFunc("POST /orders/{orderId}/review", s.reviewRisk)
+	s.mux.HandleFunc("POST /orders/{orderId}/interventions/{interventionId}", s.resolveIntervention)
+	s.mux.HandleFunc("POST /orders/{orderId}/tips", s.tip)
+	s.mux.HandleFunc("GET /orders/{orderId}/receipt", s.getReceipt)
+	s.mux.HandleFunc("GET /orders/{orderId}/status", s.getStatus)
+	s.mux.HandleFunc("PUT /orders/{orderId}/status", s.setStatus)
+	s.mux.HandleFunc("GET /orders/{orderId}/webhooks", s.getWebhookDeliveries)
+
+	s.mux.HandleFunc("PUT /customers/{customerId}", s.saveCustomer)
+	s.mux.HandleFunc("GET /customers/{customerId}", s.getCustomer)
+	s.mux.HandleFunc("PUT /customers/{customerId}/addresses/{label}", s.saveAddress)
+	s.mux.HandleFunc("DELETE /customers/{customerId}/addresses/{label}", s.removeAddress)
+	s.mux.HandleFunc("POST /customers/{customerId}/reorder", s.reorder)
+
+	s.changeRoutes()
+	s.reportRoutes()
+}
+
+func (s *Server) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPI)
+}
+
+func (s *Server) listOrders(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseListFilter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	list, err := s.orders.List(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ListOrdersResponse{
+		NextPageToken: base64.RawURLEncoding.EncodeToString(list.NextPageToken),
+		Orders:        list.Orders,
+	})
+}
+
+func (s *Server) createOrder(w http.ResponseWriter, r *http.Request) {
+	var req CreateOrderRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	state := req.OrderState()
+	state.IPAddress = clientIP(r, s.trustedProxies)
+
+	orderID, err := s.orders.Create(r.Context(), state)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/orders/"+orderID)
+	writeJSON(w, http.StatusCreated, CreateOrderResponse{
+		OrderID: orderID,
+	})
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, r *http.Request) {
+	state, err := s.orders.GetState(r.Context(), r.PathValue("orderId"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, state)
+}
+
+func (s *Server) addItem(w http.ResponseWriter, r *http.Request) {
+	var req ItemRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.orders.AddItem(r.Context(), r.PathValue("orderId"), req.OrderProduct()); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) removeItem(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(r.PathValue("productId"))
+	if err != nil {
+		writeError(w, requestError{message: "productId: must be a number"})
+		return
+	}
+
+	// Remove one unless told otherwise
+	quantity := 1
+	if q := r.URL.Query().Get("quantity"); q != "" {
+		if quantity, err = strconv.Atoi(q); err != nil {
+			writeError(w, requestError{message: "quantity: must be a number"})
+			return
+		}
+	}
+
+	req := ItemRequest{
+		Modifiers: r.URL.Query()["modifier"],
+		Notes:     r.URL.Query().Get("notes"),
+		Owner:     r.URL.Query().Get("owner"),
+		ProductID: productID,
+		Quantity:  quantity,
+	}
+	if err := req.Validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.orders.RemoveItem(r.Context(), r.PathValue("orderId"), req.OrderProduct()); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) checkout(w http.ResponseWriter, r *http.Request) {
+	if err := s.orders.Checkout(r.Context(), r.PathValue("orderId")); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) cancel(w http.ResponseWriter, r *http.Request) {
+	if err := s.orders.Cancel(r.Context(), r.PathValue("orderId")); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Operator approves or declines an order held by the fraud checks
+func (s *Server) reviewRisk(w http.ResponseWriter, r *http.Request) {
+	var req RiskReviewRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.orders.ReviewRisk(r.Context(), r.PathValue("orderId"), foodordering.RiskReview{
+		Approved: req.Approved,
+		Reason:   req.Reason,
+		Reviewer: req.Reviewer,
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Ops deal with a payment or refund that failed
+func (s *Server) resolveIntervention(w http.ResponseWriter, r *http.Request) {
+	var req InterventionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.orders.ResolveIntervention(r.Context(), r.PathValue("orderId"), req.InterventionRequest(r.PathValue("interventionId"))); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Customer tips the kitchen and courier
+func (s *Server) tip(w http.ResponseWriter, r *http.Request) {
+	var req TipRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	tip, err := s.orders.Tip(r.Context(), r.PathValue("orderId"), foodordering.TipRequest{
+		AmountInPence: req.AmountInPence,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tip)
+}
+
+func (s *Server) getReceipt(w http.ResponseWriter, r *http.Request) {
+	orderID := r.PathValue("orderId")
+
+	state, err := s.orders.GetState(r.Context(), orderID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, foodordering.NewReceipt(orderID, *state, foodordering.DefaultRestaurant()))
+}
+
+func (s *Server) getStatus(w http.ResponseWriter, r *http.Request) {
+	orderID := r.PathValue("orderId")
+
+	state, err := s.orders.GetState(r.Context(), orderID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, StatusResponse{
+		OrderID: orderID,
+		Status:  state.Status,
+	})
+}
+
+// Log of the order's webhooks sent to partners
+func (s *Server) getWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := s.orders.GetWebhookDeliveries(r.Context(), r.PathValue("orderId"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// Restaurant moves the order on
+func (s *Server) setStatus(w http.ResponseWriter, r *http.Request) {
+	var req StatusRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	status, _ := foodordering.ParseOrderStatus(req.Status)
+	if err := s.orders.SetStatus(r.Context(), r.PathValue("orderId"), status); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// WithTrustedProxies trusts the proxies to set X-Forwarded-For, so the risk
+// checks see the customer's IP address rather than the proxy's
+func WithTrustedProxies(proxies ...netip.Prefix) Option {
+	return func(s *Server) {
+		s.trustedProxies = proxies
+	}
+}
+
+func New(c client.Client, opts ...Option) *Server {
+	orders := orderclient.New(c)
+
+	s := &Server{
+		events: newBroker(orders),
+		mux:    http.NewServeMux(),
+		orders: orders,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.routes()
+
+	return s
+}
@@ -0,0 +1,618 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/orderclient"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/projection"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/mocks"
+	"go.temporal.io/sdk/temporal"
+)
+
+const testChangeSecret = "secret"
+
+func request(t *testing.T, s *Server, method, path, body string) (*httptest.ResponseRecorder, ErrorResponse) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	var errResp ErrorResponse
+	if rec.Code >= http.StatusBadRequest {
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&errResp))
+	}
+
+	return rec, errResp
+}
+
+func TestCreateOrder(t *testing.T) {
+	c := &mocks.Client{}
+	run := &mocks.WorkflowRun{}
+
+	c.On("ExecuteWorkflow", mock.Anything, mock.MatchedBy(func(opts client.StartWorkflowOptions) bool {
+		return opts.TaskQueue == foodordering.OrderFoodTaskQueue
+	}), mock.Anything, mock.MatchedBy(func(state foodordering.OrderState) bool {
+		return state.Email == "test@test.com" && state.ExpressCheckout && len(state.Products) == 1
+	})).Return(run, nil)
+
+	rec, _ := request(t, New(c), http.MethodPost, "/orders", `{
+		"collection": true,
+		"email": "test@test.com",
+		"expressCheckout": true,
+		"products": [{"productId": 1, "quantity": 2}]
+	}`)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var resp CreateOrderResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.True(t, strings.HasPrefix(resp.OrderID, "ORDER-"))
+	assert.Equal(t, "/orders/"+resp.OrderID, rec.Header().Get("Location"))
+	c.AssertExpectations(t)
+}
+
+// The web app's basket is complete, so it's paid for without a checkout
+func TestCreateOrderFromWeb(t *testing.T) {
+	c := &mocks.Client{}
+	c.On("ExecuteWorkflow", mock.Anything, mock.Anything, mock.Anything, mock.MatchedBy(func(state foodordering.OrderState) bool {
+		return state.ExpressCheckout && state.Collection && state.DeliveryAddress == nil && len(state.Products) == 2
+	})).Return(&mocks.WorkflowRun{}, nil)
+
+	rec, errResp := request(t, New(c), http.MethodPost, "/orders", `{
+		"collection": true,
+		"deliveryAddress": null,
+		"email": "test@test.com",
+		"expressCheckout": true,
+		"products": [{"productId": 1, "quantity": 2}, {"productId": 3, "quantity": 1}]
+	}`)
+
+	assert.Equal(t, http.StatusCreated, rec.Code, errResp)
+	c.AssertExpectations(t)
+}
+
+func TestCreateOrderValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{
+			name: "invalid JSON",
+			body: `{`,
+		},
+		{
+			name: "unknown field",
+			body: `{"email": "test@test.com", "collection": true, "hello": "world"}`,
+		},
+		{
+			name: "invalid email",
+			body: `{"email": "not an email", "collection": true}`,
+		},
+		{
+			name: "delivery without address",
+			body: `{"email": "test@test.com", "collection": false}`,
+		},
+		{
+			name: "unknown product",
+			body: `{"email": "test@test.com", "collection": true, "products": [{"productId": 999, "quantity": 1}]}`,
+		},
+		{
+			name: "points without customer",
+			body: `{"email": "test@test.com", "collection": true, "redeemPoints": 100}`,
+		},
+		{
+			name: "express checkout without products",
+			body: `{"email": "test@test.com", "collection": true, "expressCheckout": true}`,
+		},
+		{
+			name: "express checkout for a group",
+			body: `{"email": "test@test.com", "collection": true, "expressCheckout": true, "group": {"organiser": "test@test.com"}, "products": [{"productId": 1, "quantity": 1}]}`,
+		},
+		{
+			name: "fulfilment time in the past",
+			body: `{"email": "test@test.com", "collection": true, "fulfilmentTime": "2020-01-01T12:00:00Z"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &mocks.Client{}
+
+			rec, errResp := request(t, New(c), http.MethodPost, "/orders", test.body)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, CodeInvalidRequest, errResp.Error.Code)
+			assert.NotEmpty(t, errResp.Error.Message)
+			c.AssertNotCalled(t, "ExecuteWorkflow")
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		expected   string
+	}{
+		{
+			name:       "no proxy",
+			remoteAddr: "203.0.113.1:1234",
+			expected:   "203.0.113.1",
+		},
+		{
+			name:       "untrusted proxy",
+			remoteAddr: "203.0.113.1:1234",
+			forwarded:  []string{"198.51.100.1"},
+			expected:   "203.0.113.1",
+		},
+		{
+			name:       "trusted proxy",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{"198.51.100.1"},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "spoofed hop",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{"1.2.3.4, 198.51.100.1"},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "chain of trusted proxies",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{"1.2.3.4, 198.51.100.1", "192.168.1.1, 10.0.0.2"},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "only trusted proxies",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{"10.0.0.3"},
+			expected:   "10.0.0.3",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/orders", nil)
+			r.RemoteAddr = test.remoteAddr
+			for _, v := range test.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+
+			assert.Equal(t, test.expected, clientIP(r, proxies))
+		})
+	}
+}
+
+func TestParseTrustedProxiesInvalid(t *testing.T) {
+	_, err := ParseTrustedProxies("10.0.0.0/8,not-an-ip")
+	assert.Error(t, err)
+}
+
+func TestAddItemRejected(t *testing.T) {
+	c := &mocks.Client{}
+	handle := &mocks.WorkflowUpdateHandle{}
+
+	c.On("UpdateWorkflow", mock.Anything, mock.MatchedBy(func(opts client.UpdateWorkflowOptions) bool {
+		return opts.WorkflowID == "order-1" && opts.UpdateName == foodordering.Updates.ADD_ITEM
+	})).Return(handle, nil)
+	handle.On("Get", mock.Anything, mock.Anything).
+		Return(temporal.NewApplicationError("basket is locked", foodordering.UpdateRejectedErrorType))
+
+	rec, errResp := request(t, New(c), http.MethodPost, "/orders/order-1/items", `{"productId": 1, "quantity": 1}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, CodeRejected, errResp.Error.Code)
+	assert.Contains(t, errResp.Error.Message, "basket is locked")
+}
+
+func TestGetOrderNotFound(t *testing.T) {
+	c := &mocks.Client{}
+
+	c.On("QueryWorkflow", mock.Anything, "order-1", "", foodordering.Queries.GET_STATUS).
+		Return(nil, serviceerror.NewNotFound("workflow not found"))
+	c.On("DescribeWorkflowExecution", mock.Anything, "order-1", "").
+		Return(nil, serviceerror.NewNotFound("workflow not found"))
+
+	rec, errResp := request(t, New(c), http.MethodGet, "/orders/order-1", "")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, CodeNotFound, errResp.Error.Code)
+}
+
+func TestSetStatus(t *testing.T) {
+	c := &mocks.Client{}
+	handle := &mocks.WorkflowUpdateHandle{}
+
+	c.On("UpdateWorkflow", mock.Anything, mock.MatchedBy(func(opts client.UpdateWorkflowOptions) bool {
+		return opts.UpdateName == foodordering.Updates.UPDATE_STATUS && opts.Args[0] == "ACCEPTED"
+	})).Return(handle, nil)
+	handle.On("Get", mock.Anything, mock.Anything).Return(nil)
+
+	rec, _ := request(t, New(c), http.MethodPut, "/orders/order-1/status", `{"status": "accepted"}`)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec, errResp := request(t, New(c), http.MethodPut, "/orders/order-1/status", `{"status": "EATEN"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, CodeInvalidRequest, errResp.Error.Code)
+}
+
+func TestSaveCustomer(t *testing.T) {
+	c := &mocks.Client{}
+	handle := &mocks.WorkflowUpdateHandle{}
+
+	// The customer's started if they don't exist yet
+	c.On("NewWithStartWorkflowOperation", mock.MatchedBy(func(opts client.StartWorkflowOptions) bool {
+		return opts.ID == foodordering.CustomerWorkflowID("customer-1")
+	}), mock.Anything, mock.Anything).Return(nil)
+	c.On("UpdateWithStartWorkflow", mock.Anything, mock.MatchedBy(func(opts client.UpdateWithStartWorkflowOptions) bool {
+		contact, ok := opts.UpdateOptions.Args[0].(foodordering.ContactDetails)
+		return opts.UpdateOptions.UpdateName == foodordering.Updates.UPDATE_CONTACT && ok && contact.Email == "test@test.com"
+	})).Return(handle, nil)
+	handle.On("Get", mock.Anything, mock.Anything).Return(nil)
+
+	rec, _ := request(t, New(c), http.MethodPut, "/customers/customer-1", `{"email": "test@test.com", "name": "Test"}`)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec, errResp := request(t, New(c), http.MethodPut, "/customers/customer-1", `{"email": "not an email"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, CodeInvalidRequest, errResp.Error.Code)
+	c.AssertExpectations(t)
+}
+
+func TestReorder(t *testing.T) {
+	c := &mocks.Client{}
+	handle := &mocks.WorkflowUpdateHandle{}
+
+	c.On("UpdateWorkflow", mock.Anything, mock.MatchedBy(func(opts client.UpdateWorkflowOptions) bool {
+		req, ok := opts.Args[0].(foodordering.ReorderRequest)
+		return opts.WorkflowID == foodordering.CustomerWorkflowID("customer-1") &&
+			opts.UpdateName == foodordering.Updates.REORDER &&
+			ok && req.OrderID == "order-1" && req.DeliveryAddress == nil
+	})).Return(handle, nil)
+	handle.On("Get", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(1).(*string) = "ORDER-2"
+	}).Return(nil)
+
+	rec, _ := request(t, New(c), http.MethodPost, "/customers/customer-1/reorder", `{"orderId": "order-1"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "/orders/ORDER-2", rec.Header().Get("Location"))
+
+	rec, errResp := request(t, New(c), http.MethodPost, "/customers/customer-1/reorder", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, CodeInvalidRequest, errResp.Error.Code)
+}
+
+func TestOpenAPI(t *testing.T) {
+	rec, _ := request(t, New(&mocks.Client{}), http.MethodGet, "/openapi.json", "")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var doc map[string]any
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&doc))
+	assert.Contains(t, doc["paths"], "/orders/{orderId}/status")
+}
+
+// Mocks the order's state query returning each state in turn, with the
+// workflow finishing once the last one's been returned
+func mockOrderStates(c *mocks.Client, orderID string, states ...foodordering.OrderState) {
+	var mu sync.Mutex
+	calls := 0
+
+	value := &mocks.Value{}
+	c.On("QueryWorkflow", mock.Anything, orderID, "", foodordering.Queries.GET_STATUS).Return(value, nil)
+	value.On("Get", mock.Anything).Run(func(args mock.Arguments) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		*args.Get(0).(*foodordering.OrderState) = states[min(calls, len(states)-1)]
+		calls++
+	}).Return(nil)
+
+	c.On("DescribeWorkflowExecution", mock.Anything, orderID, "").Return(func(context.Context, string, string) (*workflowservice.DescribeWorkflowExecutionResponse, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		status := enums.WORKFLOW_EXECUTION_STATUS_RUNNING
+		if calls >= len(states) {
+			status = enums.WORKFLOW_EXECUTION_STATUS_COMPLETED
+		}
+		return &workflowservice.DescribeWorkflowExecutionResponse{
+			WorkflowExecutionInfo: &workflowpb.WorkflowExecutionInfo{Status: status},
+		}, nil
+	})
+}
+
+// Builds the states an order goes through
+func orderStates(statuses ...foodordering.OrderStatus) []foodordering.OrderState {
+	state := foodordering.NewOrderState()
+	states := make([]foodordering.OrderState, 0)
+	for _, status := range statuses {
+		state.SetStatus(status, time.Now())
+		states = append(states, state)
+		state.History = append([]foodordering.StatusChange{}, state.History...)
+	}
+	return states
+}
+
+func newStreamServer(c *mocks.Client, opts ...Option) *Server {
+	s := New(c, append([]Option{WithChangeFeed(testChangeSecret)}, opts...)...)
+	s.events.pollInterval = time.Millisecond
+	return s
+}
+
+func TestStreamsNeedChangeFeed(t *testing.T) {
+	s := New(&mocks.Client{})
+
+	for _, path := range []string{"/events", "/orders/order-1/events"} {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code, path)
+	}
+}
+
+func TestStreamOrder(t *testing.T) {
+	t.Run("from the start", func(t *testing.T) {
+		c := &mocks.Client{}
+		mockOrderStates(c, "order-1", orderStates(
+			foodordering.OrderStatusDefault,
+			foodordering.OrderStatusPending,
+			foodordering.OrderStatusCompleted,
+		)[1:]...)
+
+		rec, _ := request(t, newStreamServer(c), http.MethodGet, "/orders/order-1/events", "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+
+		body := rec.Body.String()
+		assert.Contains(t, body, "id: 2\nevent: status\n")
+		assert.Contains(t, body, "id: 3\nevent: status\n")
+		assert.Contains(t, body, `"status":"COMPLETED"`)
+	})
+
+	t.Run("resume after reconnecting", func(t *testing.T) {
+		c := &mocks.Client{}
+		mockOrderStates(c, "order-1", orderStates(
+			foodordering.OrderStatusDefault,
+			foodordering.OrderStatusPending,
+			foodordering.OrderStatusCompleted,
+		)[2])
+
+		req := httptest.NewRequest(http.MethodGet, "/orders/order-1/events", nil)
+		req.Header.Set("Last-Event-ID", "2")
+		rec := httptest.NewRecorder()
+		newStreamServer(c).ServeHTTP(rec, req)
+
+		body := rec.Body.String()
+		assert.NotContains(t, body, "id: 2\n")
+		assert.Contains(t, body, "id: 3\nevent: status\n")
+	})
+
+	t.Run("open until the order finishes", func(t *testing.T) {
+		c := &mocks.Client{}
+		// The refund failed, so ops had to finish it
+		mockOrderStates(c, "order-1", orderStates(
+			foodordering.OrderStatusDefault,
+			foodordering.OrderStatusPending,
+			foodordering.OrderStatusRejected,
+			foodordering.OrderStatusNeedsAttention,
+			foodordering.OrderStatusRejected,
+		)[2:]...)
+
+		rec, _ := request(t, newStreamServer(c), http.MethodGet, "/orders/order-1/events", "")
+
+		body := rec.Body.String()
+		assert.Contains(t, body, "id: 3\nevent: status\n")
+		assert.Contains(t, body, "id: 4\nevent: status\n")
+		assert.Contains(t, body, "id: 5\nevent: status\n")
+	})
+}
+
+func TestChangeFeed(t *testing.T) {
+	c := &mocks.Client{}
+	states := orderStates(foodordering.OrderStatusDefault, foodordering.OrderStatusPending, foodordering.OrderStatusAccepted)
+	mockOrderStates(c, "order-1", states[1], states[1])
+
+	s := New(c, WithChangeFeed(testChangeSecret))
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	sub, _, unsubscribe := s.events.subscribe("order-1")
+	defer unsubscribe()
+	assert.Equal(t, 2, (<-sub.events).EventID)
+
+	t.Run("unsigned", func(t *testing.T) {
+		resp, err := http.Post(srv.URL+changeFeedPath, "application/json", strings.NewReader(`{"orderId":"order-1"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		resp.Body.Close()
+	})
+
+	t.Run("pushed by the worker", func(t *testing.T) {
+		publisher := NewChangePublisher(srv.URL, testChangeSecret)
+		assert.NoError(t, publisher.Publish(context.Background(), foodordering.NewOrderChange("order-1", "codfather", states[2])))
+
+		select {
+		case event := <-sub.events:
+			assert.Equal(t, 3, event.EventID)
+			assert.Equal(t, foodordering.OrderStatusAccepted, event.State.Status)
+		case <-time.After(time.Second):
+			t.Fatal("change was not received")
+		}
+	})
+}
+
+func TestKitchensShareDiscovery(t *testing.T) {
+	c := &mocks.Client{}
+	c.On("ListWorkflow", mock.Anything, mock.Anything).Return(&workflowservice.ListWorkflowExecutionsResponse{
+		Executions: []*workflowpb.WorkflowExecutionInfo{
+			{Execution: &common.WorkflowExecution{WorkflowId: "order-1"}},
+		},
+	}, nil)
+
+	b := newBroker(orderclient.New(c))
+
+	first, unsubscribeFirst := b.subscribeKitchen()
+	defer unsubscribeFirst()
+	<-first.notify
+	assert.Equal(t, []string{"order-1"}, b.takeOrders(first))
+
+	// The orders already listed are given to the next kitchen
+	second, unsubscribeSecond := b.subscribeKitchen()
+	defer unsubscribeSecond()
+	<-second.notify
+	assert.Equal(t, []string{"order-1"}, b.takeOrders(second))
+	c.AssertNumberOfCalls(t, "ListWorkflow", 1)
+
+	t.Run("new orders are pushed by the worker", func(t *testing.T) {
+		b.receive(foodordering.NewOrderChange("order-2", "codfather", orderStates(foodordering.OrderStatusPending)[0]))
+
+		for _, k := range []*kitchen{first, second} {
+			<-k.notify
+			assert.Equal(t, []string{"order-2"}, b.takeOrders(k))
+		}
+	})
+}
+
+func TestSlowSubscriberGetsLatestEvent(t *testing.T) {
+	b := newBroker(nil)
+	f := &feed{subscribers: map[*subscription]struct{}{}}
+	sub := &subscription{events: make(chan Event, subscriberBuffer)}
+	f.subscribers[sub] = struct{}{}
+
+	// Nothing's read, so the oldest events are dropped
+	for i := 1; i <= subscriberBuffer*2; i++ {
+		b.publish(f, Event{OrderEvent: foodordering.OrderEvent{EventID: i}})
+	}
+
+	last := 0
+	for len(sub.events) > 0 {
+		last = (<-sub.events).EventID
+	}
+	assert.Equal(t, subscriberBuffer*2, last)
+}
+
+func TestListOrders(t *testing.T) {
+	c := &mocks.Client{}
+
+	payload := func(v any) *common.Payload {
+		p, err := converter.GetDefaultDataConverter().ToPayload(v)
+		assert.NoError(t, err)
+		return p
+	}
+
+	c.On("ListWorkflow", mock.Anything, mock.MatchedBy(func(req *workflowservice.ListWorkflowExecutionsRequest) bool {
+		return req.Query == "WorkflowType = 'OrderWorkflow' AND OrderStatus = 'PENDING' AND "+
+			"CustomerEmail = '"+foodordering.HashEmail("test@test.com")+"' AND Collection = false" &&
+			req.PageSize == 10
+	})).Return(&workflowservice.ListWorkflowExecutionsResponse{
+		Executions: []*workflowpb.WorkflowExecutionInfo{
+			{
+				Execution: &common.WorkflowExecution{WorkflowId: "order-1"},
+				SearchAttributes: &common.SearchAttributes{
+					IndexedFields: map[string]*common.Payload{
+						"Collection":   payload(false),
+						"OrderStatus":  payload("PENDING"),
+						"RestaurantID": payload("codfather"),
+						"Total":        payload(1195),
+					},
+				},
+			},
+		},
+		NextPageToken: []byte("next"),
+	}, nil)
+
+	rec, _ := request(t, New(c), http.MethodGet, "/orders?status=pending&email=Test@test.com&collection=false&pageSize=10", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp ListOrdersResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, "bmV4dA", resp.NextPageToken)
+	assert.Len(t, resp.Orders, 1)
+	assert.Equal(t, "order-1", resp.Orders[0].OrderID)
+	assert.Equal(t, foodordering.OrderStatusPending, resp.Orders[0].Status)
+	assert.Equal(t, "codfather", resp.Orders[0].RestaurantID)
+	assert.Equal(t, 1195, resp.Orders[0].TotalInPence)
+
+	rec, errResp := request(t, New(c), http.MethodGet, "/orders?restaurantId=a'b", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, CodeInvalidRequest, errResp.Error.Code)
+}
+
+func TestReports(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := projection.Open(ctx, filepath.Join(t.TempDir(), "orders.db"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	state := foodordering.NewOrderState()
+	state.AddItem(foodordering.OrderProduct{ProductID: 1, Quantity: 1})
+	state.SetStatus(foodordering.OrderStatusCompleted, time.Date(2025, time.June, 6, 12, 0, 0, 0, time.UTC))
+	assert.NoError(t, store.Publish(ctx, foodordering.NewOrderChange("order-1", "codfather", state)))
+
+	s := New(&mocks.Client{}, WithReadModel(store))
+
+	rec, _ := request(t, s, http.MethodGet, "/reports/summary?from=2025-06-06&to=2025-06-07", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var summary projection.SalesSummary
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&summary))
+	assert.Equal(t, 1, summary.CompletedOrders)
+	assert.Equal(t, 350, summary.RevenueInPence)
+
+	rec, _ = request(t, s, http.MethodGet, "/reports/orders/order-1", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec, errResp := request(t, s, http.MethodGet, "/reports/orders/order-2", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, CodeNotFound, errResp.Error.Code)
+
+	rec, errResp = request(t, s, http.MethodGet, "/reports/summary?from=yesterday", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, CodeInvalidRequest, errResp.Error.Code)
+
+	// Reports are only available with a read model
+	rec = httptest.NewRecorder()
+	New(&mocks.Client{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/reports/summary", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInterventionCancelled is returned when ops give up on a failed money operation
+var ErrInterventionCancelled = errors.New("operation cancelled by ops")
+
+type MoneyOperation string
+
+const (
+	MoneyOperationPayment MoneyOperation = "PAYMENT"
+	MoneyOperationRefund  MoneyOperation = "REFUND"
+)
+
+type InterventionAction string
+
+const (
+	InterventionFailed  InterventionAction = "FAILED"  // The operation ran out of retries - set by the workflow
+	InterventionRetry   InterventionAction = "RETRY"   // Try the operation again
+	InterventionResolve InterventionAction = "RESOLVE" // Ops have done the operation by hand
+	InterventionCancel  InterventionAction = "CANCEL"  // Give up on the operation
+)
+
+// InterventionEvent is an entry in the intervention's audit trail
+type InterventionEvent struct {
+	Action    InterventionAction `json:"action"`
+	Error     string             `json:"error,omitempty"`
+	Notes     string             `json:"notes,omitempty"`
+	Operator  string             `json:"operator,omitempty" pii:"true"`
+	Reference string             `json:"reference,omitempty"` // Transaction ID of a payment taken by hand
+	Time      time.Time          `json:"time"`
+}
+
+// Intervention is a money operation that failed and is waiting for ops
+type Intervention struct {
+	AmountInPence  int                 `json:"amountInPence"`
+	Events         []InterventionEvent `json:"events"`
+	ID             string              `json:"id"`
+	Operation      MoneyOperation      `json:"operation"`
+	PreviousStatus OrderStatus         `json:"previousStatus"` // Status to go back to once resolved
+	RaisedAt       time.Time           `json:"raisedAt"`
+	ResolvedAt     *time.Time          `json:"resolvedAt"`
+	TransactionID  string              `json:"transactionId,omitempty"` // Payment being refunded
+}
+
+type InterventionRequest struct {
+	Action         InterventionAction `json:"action"`
+	InterventionID string             `json:"interventionId"`
+	Notes          string             `json:"notes"`
+	Operator       string             `json:"operator" pii:"true"`
+	Reference      string             `json:"reference"` // Required when resolving a payment by hand
+}
+
+// IsOpen is true until ops have dealt with the intervention
+func (i Intervention) IsOpen() bool {
+	return i.ResolvedAt == nil
+}
+
+// AwaitingAction is true when the operation has failed and ops haven't done anything about it yet
+func (i Intervention) AwaitingAction() bool {
+	return i.IsOpen() && len(i.Events) > 0 && i.Events[len(i.Events)-1].Action == InterventionFailed
+}
+
+func (r InterventionRequest) Validate() error {
+	switch r.Action {
+	case InterventionRetry, InterventionResolve, InterventionCancel:
+	default:
+		return fmt.Errorf("action must be %s, %s or %s", InterventionRetry, InterventionResolve, InterventionCancel)
+	}
+
+	if strings.TrimSpace(r.Operator) == "" {
+		return fmt.Errorf("operator is required")
+	}
+
+	return nil
+}
+
+func (o *OrderState) GetIntervention(interventionID string) *Intervention {
+	for i := range o.Interventions {
+		if o.Interventions[i].ID == interventionID {
+			return &o.Interventions[i]
+		}
+	}
+	return nil
+}
+
+// OpenInterventions is how many interventions are waiting for ops
+func (o *OrderState) OpenInterventions() int {
+	open := 0
+	for _, i := range o.Interventions {
+		if i.IsOpen() {
+			open++
+		}
+	}
+	return open
+}
+
+// ValidateIntervention checks the request can be applied to the intervention
+func (o *OrderState) ValidateIntervention(req InterventionRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	intervention := o.GetIntervention(req.InterventionID)
+	if intervention == nil {
+		return fmt.Errorf("unknown intervention: %s", req.InterventionID)
+	}
+	if !intervention.AwaitingAction() {
+		return fmt.Errorf("intervention is not waiting for action")
+	}
+	if req.Action == InterventionResolve && intervention.Operation == MoneyOperationPayment && req.Reference == "" {
+		return fmt.Errorf("reference is required when a payment is taken by hand")
+	}
+
+	return nil
+}
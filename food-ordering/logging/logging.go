@@ -0,0 +1,90 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.temporal.io/sdk/log"
+)
+
+// Key names that are always redacted, whatever the value is. They're matched
+// case-insensitively, ignoring "-" and "_".
+var DefaultRedactKeys = []string{
+	"address",
+	"deliveryAddress",
+	"email",
+	"ipAddress",
+	"operator",
+	"organiser",
+	"owner",
+	"participant",
+	"payer",
+	"phone",
+	"postcode",
+	"reviewer",
+	"to",
+}
+
+// Options configures the logger. Each worker can have its own.
+type Options struct {
+	Level      slog.Level
+	Output     io.Writer // Defaults to stderr
+	RedactKeys []string  // Added to DefaultRedactKeys
+}
+
+// OptionsFromEnv reads the logger config. LOG_LEVEL is debug, info, warn or
+// error and LOG_REDACT_KEYS is a comma-separated list of extra keys to redact.
+func OptionsFromEnv() (*Options, error) {
+	opts := &Options{
+		Level: slog.LevelInfo,
+	}
+
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		if err := opts.Level.UnmarshalText([]byte(value)); err != nil {
+			return nil, fmt.Errorf("error parsing LOG_LEVEL: %w", err)
+		}
+	}
+
+	if value := os.Getenv("LOG_REDACT_KEYS"); value != "" {
+		for _, key := range strings.Split(value, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				opts.RedactKeys = append(opts.RedactKeys, key)
+			}
+		}
+	}
+
+	return opts, nil
+}
+
+// NewLogger writes JSON logs through slog, with personal data redacted
+func NewLogger(opts Options) log.Logger {
+	out := opts.Output
+	if out == nil {
+		out = os.Stderr
+	}
+
+	handler := slog.NewJSONHandler(out, &slog.HandlerOptions{
+		Level: opts.Level,
+	})
+
+	return NewRedactingLogger(log.NewStructuredLogger(slog.New(handler)), append(DefaultRedactKeys, opts.RedactKeys...)...)
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/stretchr/testify/assert"
+	"go.temporal.io/sdk/log"
+)
+
+// Logs one line and returns it as JSON
+func logLine(t *testing.T, fn func(logger log.Logger)) map[string]any {
+	t.Helper()
+
+	var buf bytes.Buffer
+	fn(NewLogger(Options{Level: slog.LevelDebug, Output: &buf}))
+
+	var line map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	return line
+}
+
+func TestRedactsTaggedFields(t *testing.T) {
+	state := foodordering.OrderState{
+		DeliveryAddress: &foodordering.Address{AddressLine1: "1 High Street", PostCode: "AB1 2CD"},
+		Email:           "test@test.com",
+		Products:        []foodordering.OrderProduct{{Quantity: 2}},
+		Status:          foodordering.OrderStatusPending,
+	}
+
+	line := logLine(t, func(logger log.Logger) {
+		logger.Info("Updating order status", "state", state, "pointer", &state)
+	})
+
+	for _, key := range []string{"state", "pointer"} {
+		logged := line[key].(map[string]any)
+		assert.Equal(t, Redacted, logged["deliveryAddress"])
+		assert.Equal(t, Redacted, logged["email"])
+		assert.Equal(t, "", logged["ipAddress"], "empty values aren't redacted")
+		assert.Equal(t, string(foodordering.OrderStatusPending), logged["status"])
+		assert.Len(t, logged["products"], 1)
+	}
+}
+
+func TestRedactsKeys(t *testing.T) {
+	line := logLine(t, func(logger log.Logger) {
+		logger.Warn("Checking order", "Email", "test@test.com", "delivery_address", "1 High Street", "orderId", "order-1")
+	})
+
+	assert.Equal(t, Redacted, line["Email"])
+	assert.Equal(t, Redacted, line["delivery_address"])
+	assert.Equal(t, "order-1", line["orderId"])
+	assert.Equal(t, "Checking order", line["msg"])
+	assert.Equal(t, "WARN", line["level"])
+}
+
+func TestRedactsPeople(t *testing.T) {
+	line := logLine(t, func(logger log.Logger) {
+		logger.Info("Taking payment",
+			"payment", foodordering.PaymentRequest{AmountInPence: 100, Payer: "test@test.com"},
+			"operator", "Ops Person",
+			"participant", "Someone",
+			"to", "restaurant@test.com",
+		)
+	})
+
+	assert.Equal(t, Redacted, line["payment"].(map[string]any)["payer"])
+	assert.Equal(t, float64(100), line["payment"].(map[string]any)["amountInPence"])
+	for _, key := range []string{"operator", "participant", "to"} {
+		assert.Equal(t, Redacted, line[key], key)
+	}
+}
+
+// Refer to each other, with the tagged field after the loop
+type recursiveA struct {
+	B     *recursiveB `json:"b"`
+	Email string      `json:"email" pii:"true"`
+}
+
+type recursiveB struct {
+	A *recursiveA `json:"a"`
+}
+
+func TestRedactsRecursiveTypes(t *testing.T) {
+	a := recursiveA{Email: "test@test.com"}
+	assert.Equal(t, map[string]any{"b": nil, "email": Redacted}, Redact(a))
+
+	// Found by way of the other type, which was looked at first
+	b := recursiveB{A: &a}
+	assert.Equal(t, map[string]any{"a": map[string]any{"b": nil, "email": Redacted}}, Redact(b))
+}
+
+func TestRedactsWith(t *testing.T) {
+	line := logLine(t, func(logger log.Logger) {
+		log.With(logger, "email", "test@test.com").Info("Order placed", "error", errors.New("some error"))
+	})
+
+	assert.Equal(t, Redacted, line["email"])
+	assert.Equal(t, "some error", line["error"])
+}
+
+func TestLeavesValuesWithoutPII(t *testing.T) {
+	discount := foodordering.Discount{AmountInPence: 100, Description: "Meal deal"}
+	assert.Equal(t, discount, Redact(discount))
+	assert.Equal(t, "test", Redact("test"))
+	assert.Nil(t, Redact(nil))
+}
+
+func TestLogsCaller(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{AddSource: true})
+	logger := NewRedactingLogger(log.NewStructuredLogger(slog.New(handler)))
+
+	logger.Info("Order placed")
+
+	var line struct {
+		Source struct {
+			File string `json:"file"`
+		} `json:"source"`
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.True(t, strings.HasSuffix(line.Source.File, "logging_test.go"), line.Source.File)
+}
+
+func TestOptionsFromEnv(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("LOG_REDACT_KEYS", "name, phone")
+
+	opts, err := OptionsFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, opts.Level)
+	assert.Equal(t, []string{"name", "phone"}, opts.RedactKeys)
+
+	t.Setenv("LOG_LEVEL", "loud")
+	_, err = OptionsFromEnv()
+	assert.Error(t, err)
+}
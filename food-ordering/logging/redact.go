@@ -0,0 +1,293 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logging
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.temporal.io/sdk/log"
+)
+
+// Redacted replaces the personal data in the logs
+const Redacted = "[REDACTED]"
+
+// Struct fields with this tag set to "true" are redacted, eg `pii:"true"`
+const TagName = "pii"
+
+// RedactingLogger wraps a Temporal logger, redacting values logged under the
+// keys and struct fields tagged as personal data
+type RedactingLogger struct {
+	keys   map[string]bool
+	logger log.Logger
+}
+
+var _ interface {
+	log.Logger
+	log.WithLogger
+	log.WithSkipCallers
+} = &RedactingLogger{}
+
+func NewRedactingLogger(logger log.Logger, keys ...string) *RedactingLogger {
+	l := &RedactingLogger{
+		keys:   make(map[string]bool, len(keys)),
+		logger: logger,
+	}
+	for _, key := range keys {
+		l.keys[normaliseKey(key)] = true
+	}
+
+	// Skip this logger when the caller is reported
+	if s, ok := logger.(log.WithSkipCallers); ok {
+		l.logger = s.WithCallerSkip(1)
+	}
+
+	return l
+}
+
+func (l *RedactingLogger) Debug(msg string, keyvals ...interface{}) {
+	l.logger.Debug(msg, l.redact(keyvals)...)
+}
+
+func (l *RedactingLogger) Info(msg string, keyvals ...interface{}) {
+	l.logger.Info(msg, l.redact(keyvals)...)
+}
+
+func (l *RedactingLogger) Warn(msg string, keyvals ...interface{}) {
+	l.logger.Warn(msg, l.redact(keyvals)...)
+}
+
+func (l *RedactingLogger) Error(msg string, keyvals ...interface{}) {
+	l.logger.Error(msg, l.redact(keyvals)...)
+}
+
+func (l *RedactingLogger) With(keyvals ...interface{}) log.Logger {
+	return &RedactingLogger{
+		keys:   l.keys,
+		logger: log.With(l.logger, l.redact(keyvals)...),
+	}
+}
+
+func (l *RedactingLogger) WithCallerSkip(depth int) log.Logger {
+	return &RedactingLogger{
+		keys:   l.keys,
+		logger: log.Skip(l.logger, depth),
+	}
+}
+
+func (l *RedactingLogger) redact(keyvals []interface{}) []interface{} {
+	out := make([]interface{}, len(keyvals))
+	for i := 0; i < len(keyvals); i += 2 {
+		out[i] = keyvals[i]
+		if i+1 == len(keyvals) {
+			// Odd number of keyvals - let the logger deal with it
+			break
+		}
+
+		if key, ok := keyvals[i].(string); ok && l.keys[normaliseKey(key)] {
+			out[i+1] = Redacted
+			continue
+		}
+		out[i+1] = Redact(keyvals[i+1])
+	}
+	return out
+}
+
+func normaliseKey(key string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(key))
+}
+
+// Redact replaces the tagged fields in a value. Values without any tagged
+// fields are returned as they are, otherwise structs are converted to maps
+// that are logged the same way as the JSON.
+func Redact(value any) any {
+	if value == nil {
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	if !hasPII(v.Type()) {
+		return value
+	}
+	return redactValue(v)
+}
+
+func redactValue(v reflect.Value) any {
+	if !hasPII(v.Type()) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = redactValue(v.Index(i))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[mapKey(iter.Key())] = redactValue(iter.Value())
+		}
+		return out
+	case reflect.Struct:
+		out := make(map[string]any)
+		redactStruct(v, out)
+		return out
+	default:
+		return v.Interface()
+	}
+}
+
+func redactStruct(v reflect.Value, out map[string]any) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, omitEmpty, ok := jsonName(field)
+		if !ok {
+			continue
+		}
+
+		value := v.Field(i)
+		if omitEmpty && value.IsZero() {
+			continue
+		}
+
+		// Embedded structs are flattened, as they are in the JSON
+		if field.Anonymous && name == "" {
+			if value.Kind() == reflect.Pointer {
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			if value.Kind() == reflect.Struct {
+				redactStruct(value, out)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		if field.Tag.Get(TagName) == "true" && !value.IsZero() {
+			// Empty values are left as they are, so it's clear they're missing
+			out[name] = Redacted
+			continue
+		}
+		out[name] = redactValue(value)
+	}
+}
+
+// jsonName returns the field's name in the JSON - it's empty if the field's
+// not renamed
+func jsonName(field reflect.StructField) (name string, omitEmpty, ok bool) {
+	if !field.IsExported() && !field.Anonymous {
+		return "", false, false
+	}
+
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+
+	name, opts, _ := strings.Cut(tag, ",")
+	return name, strings.Contains(opts, "omitempty"), true
+}
+
+func mapKey(v reflect.Value) string {
+	if m, ok := v.Interface().(json.Marshaler); ok {
+		if b, err := m.MarshalJSON(); err == nil {
+			return strings.Trim(string(b), `"`)
+		}
+	}
+	if s, ok := v.Interface().(interface{ String() string }); ok {
+		return s.String()
+	}
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+	b, _ := json.Marshal(v.Interface())
+	return string(b)
+}
+
+// Whether a type has any tagged fields is cached, as it can't change
+var piiTypes sync.Map
+
+func hasPII(t reflect.Type) bool {
+	if cached, ok := piiTypes.Load(t); ok {
+		return cached.(bool)
+	}
+
+	found := findPII(t, make(map[reflect.Type]bool))
+	piiTypes.Store(t, found)
+	return found
+}
+
+var (
+	errorType         = reflect.TypeFor[error]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+)
+
+// findPII looks through the type for tagged fields. A type that refers to
+// itself is only looked through once - anything it has is found the first
+// time. Only the answer for the whole type is cached, as the types part way
+// through haven't been fully looked through.
+func findPII(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if cached, ok := piiTypes.Load(t); ok {
+		return cached.(bool)
+	}
+	if visited[t] {
+		return false
+	}
+	visited[t] = true
+
+	if t.Implements(errorType) || t.Implements(jsonMarshalerType) {
+		// These aren't logged as their fields
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Array, reflect.Map, reflect.Pointer, reflect.Slice:
+		return findPII(t.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if _, _, ok := jsonName(field); !ok {
+				continue
+			}
+			if field.Tag.Get(TagName) == "true" || findPII(field.Type, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	LoyaltyPointsPerPound = 10 // Points earned for every pound paid
+	LoyaltyPointValue     = 1  // Value of a point in pence when redeemed
+)
+
+var ErrInsufficientPoints = errors.New("insufficient loyalty points")
+
+type LoyaltyState struct {
+	PointsClawedBack int `json:"pointsClawedBack"`
+	PointsEarned     int `json:"pointsEarned"`
+	PointsRedeemed   int `json:"pointsRedeemed"`
+	PointsReturned   int `json:"pointsReturned"`
+}
+
+type LoyaltyRequest struct {
+	CustomerID string `json:"customerId"`
+	Points     int    `json:"points"`
+	Reference  string `json:"reference"` // Unique per movement so retries aren't applied twice
+}
+
+type LoyaltyEntry struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Points    int       `json:"points"` // Negative for debits
+	Reference string    `json:"reference"`
+}
+
+// LoyaltyLedger stores each customer's points. All movements are idempotent
+// on their reference and the balance can never go below zero.
+type LoyaltyLedger interface {
+	Balance(ctx context.Context, customerID string) (int, error)
+	// Credit adds points to the balance
+	Credit(ctx context.Context, customerID, reference string, points int) (int, error)
+	// Debit removes points, failing with ErrInsufficientPoints if the balance is too low
+	Debit(ctx context.Context, customerID, reference string, points int) (int, error)
+	// Clawback removes up to the given points, returning how many were taken
+	Clawback(ctx context.Context, customerID, reference string, points int) (int, error)
+}
+
+// WithLoyaltyLedger shares the points between workers - each worker keeps its
+// own in memory if not set
+func WithLoyaltyLedger(ledger LoyaltyLedger) ActivityOption {
+	return func(a *activities) {
+		a.loyalty = ledger
+	}
+}
+
+// LoyaltyPointsEarned is the number of points earned for the amount paid
+func LoyaltyPointsEarned(amountInPence int) int {
+	return amountInPence * LoyaltyPointsPerPound / 100
+}
+
+// In-memory ledger - each worker only knows the points it has moved itself
+type memoryLoyaltyLedger struct {
+	mu       sync.Mutex
+	accounts map[string][]LoyaltyEntry
+}
+
+func (l *memoryLoyaltyLedger) balance(customerID string) int {
+	total := 0
+	for _, e := range l.accounts[customerID] {
+		total += e.Points
+	}
+	return total
+}
+
+func (l *memoryLoyaltyLedger) find(customerID, reference string) *LoyaltyEntry {
+	for _, e := range l.accounts[customerID] {
+		if e.Reference == reference {
+			return &e
+		}
+	}
+	return nil
+}
+
+func (l *memoryLoyaltyLedger) add(customerID, reference string, points int) {
+	l.accounts[customerID] = append(l.accounts[customerID], LoyaltyEntry{
+		CreatedAt: time.Now(),
+		Points:    points,
+		Reference: reference,
+	})
+}
+
+func (l *memoryLoyaltyLedger) Balance(_ context.Context, customerID string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.balance(customerID), nil
+}
+
+func (l *memoryLoyaltyLedger) Credit(_ context.Context, customerID, reference string, points int) (int, error) {
+	if points < 0 {
+		return 0, fmt.Errorf("cannot credit negative points")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.find(customerID, reference) == nil {
+		l.add(customerID, reference, points)
+	}
+
+	return l.balance(customerID), nil
+}
+
+func (l *memoryLoyaltyLedger) Debit(_ context.Context, customerID, reference string, points int) (int, error) {
+	if points < 0 {
+		return 0, fmt.Errorf("cannot debit negative points")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.find(customerID, reference) == nil {
+		if l.balance(customerID) < points {
+			return 0, ErrInsufficientPoints
+		}
+		l.add(customerID, reference, -points)
+	}
+
+	return l.balance(customerID), nil
+}
+
+func (l *memoryLoyaltyLedger) Clawback(_ context.Context, customerID, reference string, points int) (int, error) {
+	if points < 0 {
+		return 0, fmt.Errorf("cannot claw back negative points")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e := l.find(customerID, reference); e != nil {
+		return -e.Points, nil
+	}
+
+	// Anything already spent can't be taken back
+	points = min(points, l.balance(customerID))
+	l.add(customerID, reference, -points)
+
+	return points, nil
+}
+
+func NewMemoryLoyaltyLedger() LoyaltyLedger {
+	return &memoryLoyaltyLedger{
+		accounts: map[string][]LoyaltyEntry{},
+	}
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoyaltyPointsEarned(t *testing.T) {
+	assert.Equal(t, 157, LoyaltyPointsEarned(testOrderTotal))
+	assert.Zero(t, LoyaltyPointsEarned(9))
+}
+
+func TestMemoryLoyaltyLedger(t *testing.T) {
+	ctx := context.Background()
+	ledger := NewMemoryLoyaltyLedger()
+
+	balance, err := ledger.Credit(ctx, "customer-1", "order-1/earn", 100)
+	require.NoError(t, err)
+	assert.Equal(t, 100, balance)
+
+	// Retries are only applied once
+	balance, err = ledger.Credit(ctx, "customer-1", "order-1/earn", 100)
+	require.NoError(t, err)
+	assert.Equal(t, 100, balance)
+
+	_, err = ledger.Debit(ctx, "customer-1", "order-2/redeem", 150)
+	assert.ErrorIs(t, err, ErrInsufficientPoints)
+
+	balance, err = ledger.Debit(ctx, "customer-1", "order-2/redeem", 60)
+	require.NoError(t, err)
+	assert.Equal(t, 40, balance)
+
+	balance, err = ledger.Debit(ctx, "customer-1", "order-2/redeem", 60)
+	require.NoError(t, err)
+	assert.Equal(t, 40, balance)
+
+	// Other customers' points are kept apart
+	balance, err = ledger.Balance(ctx, "customer-2")
+	require.NoError(t, err)
+	assert.Zero(t, balance)
+
+	_, err = ledger.Credit(ctx, "customer-1", "order-3/earn", -1)
+	assert.Error(t, err)
+}
+
+func TestMemoryLoyaltyLedgerClawback(t *testing.T) {
+	ctx := context.Background()
+	ledger := NewMemoryLoyaltyLedger()
+
+	_, err := ledger.Credit(ctx, "customer-1", "order-1/earn", 100)
+	require.NoError(t, err)
+	_, err = ledger.Debit(ctx, "customer-1", "order-2/redeem", 70)
+	require.NoError(t, err)
+
+	// Points already spent can't be taken back
+	clawedBack, err := ledger.Clawback(ctx, "customer-1", "order-1/clawback", 100)
+	require.NoError(t, err)
+	assert.Equal(t, 30, clawedBack)
+
+	clawedBack, err = ledger.Clawback(ctx, "customer-1", "order-1/clawback", 100)
+	require.NoError(t, err)
+	assert.Equal(t, 30, clawedBack)
+
+	balance, err := ledger.Balance(ctx, "customer-1")
+	require.NoError(t, err)
+	assert.Zero(t, balance)
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Metrics recorded by the workflows. They're sent to the worker's metrics
+// handler, which ignores them while the workflow's replaying.
+const (
+	MetricOrdersFinished      = "orders_finished"       // Counter, by the status the order ended in
+	MetricOrderStatusDuration = "order_status_duration" // Timer, by the status the order was in
+	MetricPaymentsSent        = "payments_sent"         // Counter, by method
+	MetricPaymentsSentPence   = "payments_sent_pence"   // Counter, by method
+	MetricPaymentsFailed      = "payments_failed"       // Counter, by method
+	MetricPaymentsFailedPence = "payments_failed_pence" // Counter, by method
+)
+
+// recordStatusChange records how long the order was in its last status. Call
+// it before the status is changed.
+func recordStatusChange(ctx workflow.Context, state *OrderState) {
+	handler := workflow.GetMetricsHandler(ctx)
+
+	if len(state.History) > 0 {
+		last := state.History[len(state.History)-1]
+		handler.WithTags(map[string]string{"status": string(last.Status)}).
+			Timer(MetricOrderStatusDuration).
+			Record(workflow.Now(ctx).Sub(last.Time))
+	}
+}
+
+// recordOrderFinished counts the order once the workflow ends. A terminal
+// status isn't the end, as a failed refund can move the order on again.
+func recordOrderFinished(ctx workflow.Context, state *OrderState) {
+	if !state.Status.IsTerminal() {
+		return
+	}
+
+	workflow.GetMetricsHandler(ctx).
+		WithTags(map[string]string{"status": string(state.Status)}).
+		Counter(MetricOrdersFinished).
+		Inc(1)
+}
+
+// recordPayment counts the payment and its amount. Payments stopped because
+// the order was cancelled haven't failed.
+func recordPayment(ctx workflow.Context, method PaymentMethodType, amountInPence int, err error) {
+	if temporal.IsCanceledError(err) {
+		return
+	}
+
+	handler := workflow.GetMetricsHandler(ctx).WithTags(map[string]string{"method": string(method)})
+	if err != nil {
+		handler.Counter(MetricPaymentsFailed).Inc(1)
+		handler.Counter(MetricPaymentsFailedPence).Inc(int64(amountInPence))
+		return
+	}
+
+	handler.Counter(MetricPaymentsSent).Inc(1)
+	handler.Counter(MetricPaymentsSentPence).Inc(int64(amountInPence))
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/mrsimonemms/temporal-demos/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/temporal"
+)
+
+// scrape reads the metrics the way Prometheus does
+func (s *OrderWorkflowTestSuite) scrape() string {
+	srv := httptest.NewServer(metrics.NewHTTPHandler(s.metrics))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/metrics")
+	s.Require().NoError(err)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	s.Require().NoError(err)
+	return string(body)
+}
+
+// assertMetrics waits for the metrics to be reported, then checks them
+func (s *OrderWorkflowTestSuite) assertMetrics(fn func(c *assert.CollectT, body string)) {
+	s.EventuallyWithT(func(c *assert.CollectT) {
+		fn(c, s.scrape())
+	}, time.Second, testMetricsInterval)
+}
+
+func (s *OrderWorkflowTestSuite) Test_Metrics() {
+	s.complete(time.Minute)
+
+	// Whilst the customer can still leave feedback
+	s.at(time.Hour, func() {
+		s.assertMetrics(func(c *assert.CollectT, body string) {
+			assert.Regexp(c, `payments_sent_total\{.*method="CARD".*\} 1\n`, body)
+			assert.Regexp(c, `payments_sent_pence_total\{.*method="CARD".*\} `+strconv.Itoa(testOrderTotal)+`\n`, body)
+			// A minute in each of the kitchen's statuses
+			assert.Regexp(c, `order_status_duration_seconds_sum\{.*status="PREPARING".*\} 60\n`, body)
+			assert.Regexp(c, `order_status_duration_seconds_count\{.*status="ACCEPTED".*\} 1\n`, body)
+			// The order's not over yet
+			assert.NotContains(c, body, "orders_finished_total{")
+		})
+	})
+
+	s.run(newTestOrder())
+	s.NoError(s.env.GetWorkflowError())
+
+	s.assertMetrics(func(c *assert.CollectT, body string) {
+		assert.Regexp(c, `orders_finished_total\{.*status="COMPLETED".*\} 1\n`, body)
+		assert.NotContains(c, body, "payments_failed_total{")
+	})
+}
+
+func (s *OrderWorkflowTestSuite) Test_MetricsPaymentDeclined() {
+	s.env.OnActivity(s.a.TakePayment, mock.Anything, mock.Anything).
+		Return(nil, temporal.NewNonRetryableApplicationError("card declined", PaymentDeclinedErrorType, ErrPaymentDeclined)).Once()
+
+	s.run(newTestOrder())
+	s.NoError(s.env.GetWorkflowError())
+
+	s.assertMetrics(func(c *assert.CollectT, body string) {
+		assert.Regexp(c, `orders_finished_total\{.*status="CANCELLED".*\} 1\n`, body)
+		assert.Regexp(c, `payments_failed_total\{.*method="CARD".*\} 1\n`, body)
+		assert.Regexp(c, `payments_failed_pence_total\{.*method="CARD".*\} `+strconv.Itoa(testOrderTotal)+`\n`, body)
+	})
+}
+
+func (s *OrderWorkflowTestSuite) Test_MetricsFinishedOnce() {
+	// The refund fails, so the order goes from REJECTED to NEEDS_ATTENTION and back
+	s.env.OnActivity(s.a.RefundPayment, mock.Anything, mock.Anything).Return(errors.New("provider down")).Times(int(moneyRetryPolicy.MaximumAttempts))
+
+	s.at(time.Minute, func() {
+		s.setStatus(OrderStatusRejected)
+	})
+	s.at(time.Hour, func() {
+		state := s.query()
+		s.Require().Equal(1, state.OpenInterventions())
+
+		s.update(Updates.RESOLVE_INTERVENTION, InterventionRequest{
+			Action:         InterventionResolve,
+			InterventionID: state.Interventions[0].ID,
+			Operator:       "ops@test.com",
+			Reference:      "manual-refund-1",
+		})
+	})
+
+	s.run(newTestOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+	s.Equal(OrderStatusRejected, s.query().Status)
+
+	s.assertMetrics(func(c *assert.CollectT, body string) {
+		assert.Regexp(c, `orders_finished_total\{.*status="REJECTED".*\} 1\n`, body)
+	})
+}
@@ -0,0 +1,285 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package orderclient is a typed client for the food ordering workflow. Use
+// this rather than sending queries, signals and updates by name.
+package orderclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/webhook"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+)
+
+type Client struct {
+	client client.Client
+}
+
+// Create starts a new order, returning the order ID
+func (c *Client) Create(ctx context.Context, state foodordering.OrderState) (string, error) {
+	// Caught here rather than failing the order once it's started
+	if state.FulfilmentTime != nil {
+		if err := foodordering.DefaultRestaurant().ValidateFulfilmentTime(*state.FulfilmentTime, time.Now()); err != nil {
+			return "", fmt.Errorf("%w: %s", ErrValidationRejected, err)
+		}
+	}
+
+	orderID := "ORDER-" + uuid.NewString()
+
+	if _, err := c.client.ExecuteWorkflow(ctx, client.StartWorkflowOptions{
+		ID:        orderID,
+		TaskQueue: foodordering.OrderFoodTaskQueue,
+	}, foodordering.OrderWorkflow, state); err != nil {
+		return "", fmt.Errorf("error creating order: %w", err)
+	}
+
+	return orderID, nil
+}
+
+func (c *Client) AddItem(ctx context.Context, orderID string, item foodordering.OrderProduct) error {
+	return c.update(ctx, orderID, foodordering.Updates.ADD_ITEM, nil, item)
+}
+
+func (c *Client) RemoveItem(ctx context.Context, orderID string, item foodordering.OrderProduct) error {
+	return c.update(ctx, orderID, foodordering.Updates.REMOVE_ITEM, nil, item)
+}
+
+func (c *Client) JoinGroup(ctx context.Context, orderID string, req foodordering.JoinRequest) error {
+	return c.update(ctx, orderID, foodordering.Updates.JOIN_GROUP, nil, req)
+}
+
+// Checkout submits the order for payment, locking the basket
+func (c *Client) Checkout(ctx context.Context, orderID string) error {
+	if err := c.client.SignalWorkflow(ctx, orderID, "", foodordering.Signals.CHECKOUT, nil); err != nil {
+		return c.convertError(ctx, orderID, err)
+	}
+	return nil
+}
+
+// PayShare pays for a participant's items in a split payment group order
+func (c *Client) PayShare(ctx context.Context, orderID, name string) error {
+	return c.update(ctx, orderID, foodordering.Updates.PAY_SHARE, nil, name)
+}
+
+// Cancel cancels a scheduled order before it's sent to the restaurant
+func (c *Client) Cancel(ctx context.Context, orderID string) error {
+	return c.update(ctx, orderID, foodordering.Updates.CANCEL, nil)
+}
+
+// SetStatus moves the order on - this is used by the restaurant
+func (c *Client) SetStatus(ctx context.Context, orderID string, status foodordering.OrderStatus) error {
+	return c.update(ctx, orderID, foodordering.Updates.UPDATE_STATUS, nil, string(status))
+}
+
+func (c *Client) Rate(ctx context.Context, orderID string, rating foodordering.Rating) error {
+	return c.update(ctx, orderID, foodordering.Updates.RATE, nil, rating)
+}
+
+func (c *Client) Complain(ctx context.Context, orderID string, req foodordering.ComplaintRequest) (*foodordering.Complaint, error) {
+	var complaint foodordering.Complaint
+	if err := c.update(ctx, orderID, foodordering.Updates.COMPLAINT, &complaint, req); err != nil {
+		return nil, err
+	}
+	return &complaint, nil
+}
+
+// Tip charges the tip straight away after completion, or with the order at checkout
+func (c *Client) Tip(ctx context.Context, orderID string, req foodordering.TipRequest) (*foodordering.Tip, error) {
+	var tip foodordering.Tip
+	if err := c.update(ctx, orderID, foodordering.Updates.TIP, &tip, req); err != nil {
+		return nil, err
+	}
+	return &tip, nil
+}
+
+// ResolveComplaint approves or declines a complaint - this is used by the restaurant
+func (c *Client) ResolveComplaint(ctx context.Context, orderID string, resolution foodordering.ComplaintResolution) error {
+	return c.update(ctx, orderID, foodordering.Updates.RESOLVE_COMPLAINT, nil, resolution)
+}
+
+// ReviewRisk approves or declines an order held by the fraud checks - this is used by an operator
+func (c *Client) ReviewRisk(ctx context.Context, orderID string, review foodordering.RiskReview) error {
+	return c.update(ctx, orderID, foodordering.Updates.REVIEW_RISK, nil, review)
+}
+
+// ResolveIntervention retries, resolves or cancels a failed payment or refund - this is used by ops
+func (c *Client) ResolveIntervention(ctx context.Context, orderID string, req foodordering.InterventionRequest) error {
+	return c.update(ctx, orderID, foodordering.Updates.RESOLVE_INTERVENTION, nil, req)
+}
+
+func (c *Client) GetState(ctx context.Context, orderID string) (*foodordering.OrderState, error) {
+	resp, err := c.client.QueryWorkflow(ctx, orderID, "", foodordering.Queries.GET_STATUS)
+	if err != nil {
+		return nil, c.convertError(ctx, orderID, err)
+	}
+
+	var state foodordering.OrderState
+	if err := resp.Get(&state); err != nil {
+		return nil, fmt.Errorf("unable to decode state query: %w", err)
+	}
+
+	return &state, nil
+}
+
+// GetWebhookDeliveries returns the log of the order's webhooks sent to partners
+func (c *Client) GetWebhookDeliveries(ctx context.Context, orderID string) ([]webhook.Delivery, error) {
+	resp, err := c.client.QueryWorkflow(ctx, orderID, "", foodordering.Queries.GET_WEBHOOK_DELIVERIES)
+	if err != nil {
+		return nil, c.convertError(ctx, orderID, err)
+	}
+
+	deliveries := make([]webhook.Delivery, 0)
+	if err := resp.Get(&deliveries); err != nil {
+		return nil, fmt.Errorf("unable to decode webhook deliveries query: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// Watch calls the handler with the current state and every time the status
+// changes, long-polling the order rather than querying it over and over. As
+// with WaitForChange, don't use it to watch lots of orders. It returns once the
+// order reaches its last status or finishes, the handler errors or the context
+// is done - the order may stay open for a while after, eg to take feedback, but
+// watching it would only grow its history.
+func (c *Client) Watch(ctx context.Context, orderID string, handler func(state foodordering.OrderState) error) error {
+	state, err := c.GetState(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if err := handler(*state); err != nil || state.Status.IsTerminal() {
+		return err
+	}
+	lastEventID := state.LastEventID()
+
+	for {
+		event, err := c.WaitForChange(ctx, orderID, lastEventID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			// The long-poll fails once the order finishes
+			if running, runErr := c.IsRunning(ctx, orderID); runErr != nil || running {
+				return err
+			}
+			return c.watchFinished(ctx, orderID, lastEventID, handler)
+		}
+
+		// Nothing changed before the long-poll timed out
+		if event.EventID <= lastEventID {
+			continue
+		}
+
+		lastEventID = event.EventID
+		if err := handler(event.State); err != nil || event.State.Status.IsTerminal() {
+			return err
+		}
+	}
+}
+
+// Sends the final state if the order finished with a change the watcher hasn't seen
+func (c *Client) watchFinished(ctx context.Context, orderID string, lastEventID int, handler func(state foodordering.OrderState) error) error {
+	if err := c.client.GetWorkflow(ctx, orderID, "").Get(ctx, nil); err != nil {
+		return fmt.Errorf("order failed: %w", c.convertError(ctx, orderID, err))
+	}
+
+	state, err := c.GetState(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if state.LastEventID() > lastEventID {
+		return handler(*state)
+	}
+	return nil
+}
+
+// IsRunning is false once the order's workflow has finished
+func (c *Client) IsRunning(ctx context.Context, orderID string) (bool, error) {
+	desc, err := c.client.DescribeWorkflowExecution(ctx, orderID, "")
+	if err != nil {
+		return false, c.convertError(ctx, orderID, err)
+	}
+	return desc.GetWorkflowExecutionInfo().GetStatus() == enums.WORKFLOW_EXECUTION_STATUS_RUNNING, nil
+}
+
+// WaitForChange blocks until the order's status changes after the given event.
+// If nothing changes for a while, the latest event is returned anyway so it's
+// safe to call again with the returned event ID. Each call adds to the order's
+// history, so don't use it to watch lots of orders.
+func (c *Client) WaitForChange(ctx context.Context, orderID string, afterEventID int) (*foodordering.OrderEvent, error) {
+	var event foodordering.OrderEvent
+	if err := c.update(ctx, orderID, foodordering.Updates.WAIT_FOR_CHANGE, &event, afterEventID); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// ListRunning returns the IDs of all orders that haven't finished
+func (c *Client) ListRunning(ctx context.Context) ([]string, error) {
+	orderIDs := make([]string, 0)
+
+	var nextPageToken []byte
+	for {
+		resp, err := c.client.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Query:         "WorkflowType='OrderWorkflow' AND ExecutionStatus='Running'",
+			NextPageToken: nextPageToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error listing orders: %w", err)
+		}
+
+		for _, e := range resp.GetExecutions() {
+			orderIDs = append(orderIDs, e.GetExecution().GetWorkflowId())
+		}
+
+		nextPageToken = resp.GetNextPageToken()
+		if len(nextPageToken) == 0 {
+			return orderIDs, nil
+		}
+	}
+}
+
+func (c *Client) update(ctx context.Context, orderID, updateName string, result any, args ...any) error {
+	handle, err := c.client.UpdateWorkflow(ctx, client.UpdateWorkflowOptions{
+		WorkflowID:   orderID,
+		UpdateName:   updateName,
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+		Args:         args,
+	})
+	if err != nil {
+		return c.convertError(ctx, orderID, err)
+	}
+
+	if err := handle.Get(ctx, result); err != nil {
+		return c.convertError(ctx, orderID, err)
+	}
+
+	return nil
+}
+
+func New(c client.Client) *Client {
+	return &Client{
+		client: c,
+	}
+}
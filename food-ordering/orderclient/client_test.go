@@ -0,0 +1,261 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package orderclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/mocks"
+	"go.temporal.io/sdk/temporal"
+)
+
+func describeResponse(status enums.WorkflowExecutionStatus) *workflowservice.DescribeWorkflowExecutionResponse {
+	return &workflowservice.DescribeWorkflowExecutionResponse{
+		WorkflowExecutionInfo: &workflowpb.WorkflowExecutionInfo{Status: status},
+	}
+}
+
+// Mocks the order's state query returning each state in turn
+func mockStates(c *mocks.Client, orderID string, states ...foodordering.OrderState) {
+	value := &mocks.Value{}
+	c.On("QueryWorkflow", mock.Anything, orderID, "", foodordering.Queries.GET_STATUS).Return(value, nil)
+	for _, state := range states {
+		value.On("Get", mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*foodordering.OrderState) = state
+		}).Return(nil).Once()
+	}
+}
+
+// Mocks a long-poll for changes after the event returning the event
+func mockWaitForChange(c *mocks.Client, orderID string, afterEventID int, event foodordering.OrderEvent) {
+	handle := &mocks.WorkflowUpdateHandle{}
+	c.On("UpdateWorkflow", mock.Anything, mock.MatchedBy(func(opts client.UpdateWorkflowOptions) bool {
+		return opts.WorkflowID == orderID &&
+			opts.UpdateName == foodordering.Updates.WAIT_FOR_CHANGE &&
+			opts.Args[0] == afterEventID
+	})).Return(handle, nil).Once()
+	handle.On("Get", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(1).(*foodordering.OrderEvent) = event
+	}).Return(nil)
+}
+
+func TestCreate(t *testing.T) {
+	c := &mocks.Client{}
+	c.On("ExecuteWorkflow", mock.Anything, mock.MatchedBy(func(opts client.StartWorkflowOptions) bool {
+		return strings.HasPrefix(opts.ID, "ORDER-") && opts.TaskQueue == foodordering.OrderFoodTaskQueue
+	}), mock.Anything, mock.Anything).Return(&mocks.WorkflowRun{}, nil)
+
+	orderID, err := New(c).Create(context.Background(), foodordering.NewOrderState())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(orderID, "ORDER-"))
+
+	// Orders for the past are rejected before they're started
+	past := time.Now().Add(-time.Hour)
+	state := foodordering.NewOrderState()
+	state.FulfilmentTime = &past
+
+	_, err = New(c).Create(context.Background(), state)
+	assert.ErrorIs(t, err, ErrValidationRejected)
+	c.AssertNumberOfCalls(t, "ExecuteWorkflow", 1)
+}
+
+func TestTypedErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("update rejected", func(t *testing.T) {
+		c := &mocks.Client{}
+		handle := &mocks.WorkflowUpdateHandle{}
+		c.On("UpdateWorkflow", mock.Anything, mock.Anything).Return(handle, nil)
+		handle.On("Get", mock.Anything, mock.Anything).
+			Return(temporal.NewApplicationError("basket is locked", foodordering.UpdateRejectedErrorType))
+
+		err := New(c).AddItem(ctx, "order-1", foodordering.OrderProduct{ProductID: 1, Quantity: 1})
+		assert.ErrorIs(t, err, ErrValidationRejected)
+		assert.ErrorContains(t, err, "basket is locked")
+	})
+
+	t.Run("order finished", func(t *testing.T) {
+		c := &mocks.Client{}
+		c.On("UpdateWorkflow", mock.Anything, mock.Anything).
+			Return(nil, serviceerror.NewNotFound("workflow execution already completed"))
+		c.On("DescribeWorkflowExecution", mock.Anything, "order-1", "").
+			Return(describeResponse(enums.WORKFLOW_EXECUTION_STATUS_COMPLETED), nil)
+
+		err := New(c).SetStatus(ctx, "order-1", foodordering.OrderStatusAccepted)
+		assert.ErrorIs(t, err, ErrAlreadyCompleted)
+	})
+
+	t.Run("order not found", func(t *testing.T) {
+		c := &mocks.Client{}
+		c.On("QueryWorkflow", mock.Anything, "order-1", "", foodordering.Queries.GET_STATUS).
+			Return(nil, serviceerror.NewNotFound("workflow not found"))
+		c.On("DescribeWorkflowExecution", mock.Anything, "order-1", "").
+			Return(nil, serviceerror.NewNotFound("workflow not found"))
+
+		_, err := New(c).GetState(ctx, "order-1")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestConvertError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		status   enums.WorkflowExecutionStatus
+		expected error
+	}{
+		{
+			name:     "rejected",
+			err:      temporal.NewApplicationError("basket is locked", foodordering.UpdateRejectedErrorType),
+			expected: ErrValidationRejected,
+		},
+		{
+			name:     "not found while running",
+			err:      serviceerror.NewNotFound("not found"),
+			status:   enums.WORKFLOW_EXECUTION_STATUS_RUNNING,
+			expected: ErrNotFound,
+		},
+		{
+			name:     "not found once finished",
+			err:      serviceerror.NewNotFound("not found"),
+			status:   enums.WORKFLOW_EXECUTION_STATUS_TERMINATED,
+			expected: ErrAlreadyCompleted,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &mocks.Client{}
+			c.On("DescribeWorkflowExecution", mock.Anything, "order-1", "").Return(describeResponse(test.status), nil)
+
+			err := New(c).convertError(context.Background(), "order-1", test.err)
+			assert.ErrorIs(t, err, test.expected)
+		})
+	}
+
+	t.Run("other errors are unchanged", func(t *testing.T) {
+		c := New(&mocks.Client{})
+		err := errors.New("boom")
+
+		assert.NoError(t, c.convertError(context.Background(), "order-1", nil))
+		assert.Equal(t, err, c.convertError(context.Background(), "order-1", err))
+
+		// Other application errors aren't rejections
+		appErr := temporal.NewApplicationError("payment failed", "PaymentError")
+		assert.Equal(t, appErr, c.convertError(context.Background(), "order-1", appErr))
+	})
+}
+
+func TestWatch(t *testing.T) {
+	now := time.Now()
+
+	pending := foodordering.NewOrderState()
+	pending.SetStatus(foodordering.OrderStatusPending, now)
+	accepted := pending.Clone()
+	accepted.SetStatus(foodordering.OrderStatusAccepted, now)
+	completed := accepted.Clone()
+	completed.SetStatus(foodordering.OrderStatusCompleted, now)
+
+	c := &mocks.Client{}
+	mockStates(c, "order-1", pending)
+	// Nothing changes before the first long-poll times out
+	mockWaitForChange(c, "order-1", 1, foodordering.OrderEvent{EventID: 1, State: pending})
+	mockWaitForChange(c, "order-1", 1, foodordering.OrderEvent{EventID: 2, State: accepted})
+	mockWaitForChange(c, "order-1", 2, foodordering.OrderEvent{EventID: 3, State: completed})
+
+	statuses := make([]foodordering.OrderStatus, 0)
+	err := New(c).Watch(context.Background(), "order-1", func(state foodordering.OrderState) error {
+		statuses = append(statuses, state.Status)
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []foodordering.OrderStatus{
+		foodordering.OrderStatusPending,
+		foodordering.OrderStatusAccepted,
+		foodordering.OrderStatusCompleted,
+	}, statuses)
+	// The order stays open for feedback, but isn't watched once it's completed
+	c.AssertNumberOfCalls(t, "UpdateWorkflow", 3)
+}
+
+func TestWatchOrderFinished(t *testing.T) {
+	pending := foodordering.NewOrderState()
+	pending.SetStatus(foodordering.OrderStatusPending, time.Now())
+	completed := pending.Clone()
+	completed.SetStatus(foodordering.OrderStatusCompleted, time.Now())
+
+	c := &mocks.Client{}
+	mockStates(c, "order-1", pending, completed)
+	// The order finishes between long-polls
+	c.On("UpdateWorkflow", mock.Anything, mock.Anything).
+		Return(nil, serviceerror.NewNotFound("workflow execution already completed"))
+	c.On("DescribeWorkflowExecution", mock.Anything, "order-1", "").
+		Return(describeResponse(enums.WORKFLOW_EXECUTION_STATUS_COMPLETED), nil)
+	run := &mocks.WorkflowRun{}
+	c.On("GetWorkflow", mock.Anything, "order-1", "").Return(run)
+	run.On("Get", mock.Anything, nil).Return(nil)
+
+	statuses := make([]foodordering.OrderStatus, 0)
+	err := New(c).Watch(context.Background(), "order-1", func(state foodordering.OrderState) error {
+		statuses = append(statuses, state.Status)
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []foodordering.OrderStatus{
+		foodordering.OrderStatusPending,
+		foodordering.OrderStatusCompleted,
+	}, statuses)
+}
+
+func TestWatchOrderFailed(t *testing.T) {
+	pending := foodordering.NewOrderState()
+	pending.SetStatus(foodordering.OrderStatusPending, time.Now())
+
+	c := &mocks.Client{}
+	mockStates(c, "order-1", pending)
+	c.On("UpdateWorkflow", mock.Anything, mock.Anything).
+		Return(nil, serviceerror.NewNotFound("workflow execution already completed"))
+	c.On("DescribeWorkflowExecution", mock.Anything, "order-1", "").
+		Return(describeResponse(enums.WORKFLOW_EXECUTION_STATUS_FAILED), nil)
+	run := &mocks.WorkflowRun{}
+	c.On("GetWorkflow", mock.Anything, "order-1", "").Return(run)
+	run.On("Get", mock.Anything, nil).Return(errors.New("error refunding payments"))
+
+	calls := 0
+	err := New(c).Watch(context.Background(), "order-1", func(state foodordering.OrderState) error {
+		calls++
+		return nil
+	})
+
+	assert.ErrorContains(t, err, "order failed: error refunding payments")
+	assert.Equal(t, 1, calls)
+}
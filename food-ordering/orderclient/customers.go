@@ -0,0 +1,93 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package orderclient
+
+import (
+	"context"
+	"fmt"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+)
+
+// SaveCustomer sets the customer's contact details, starting their workflow if
+// they've not got one yet. Orders start it too, so the customer might already
+// exist.
+func (c *Client) SaveCustomer(ctx context.Context, customerID string, contact foodordering.ContactDetails) error {
+	workflowID := foodordering.CustomerWorkflowID(customerID)
+
+	start := c.client.NewWithStartWorkflowOperation(client.StartWorkflowOptions{
+		ID:                       workflowID,
+		TaskQueue:                foodordering.OrderFoodTaskQueue,
+		WorkflowIDConflictPolicy: enums.WORKFLOW_ID_CONFLICT_POLICY_USE_EXISTING,
+	}, foodordering.CustomerWorkflow, foodordering.NewCustomerState(customerID))
+
+	handle, err := c.client.UpdateWithStartWorkflow(ctx, client.UpdateWithStartWorkflowOptions{
+		StartWorkflowOperation: start,
+		UpdateOptions: client.UpdateWorkflowOptions{
+			WorkflowID:   workflowID,
+			UpdateName:   foodordering.Updates.UPDATE_CONTACT,
+			WaitForStage: client.WorkflowUpdateStageCompleted,
+			Args:         []any{contact},
+		},
+	})
+	if err != nil {
+		return c.convertError(ctx, workflowID, err)
+	}
+
+	if err := handle.Get(ctx, nil); err != nil {
+		return c.convertError(ctx, workflowID, err)
+	}
+
+	return nil
+}
+
+func (c *Client) GetCustomer(ctx context.Context, customerID string) (*foodordering.CustomerState, error) {
+	workflowID := foodordering.CustomerWorkflowID(customerID)
+
+	resp, err := c.client.QueryWorkflow(ctx, workflowID, "", foodordering.Queries.GET_CUSTOMER)
+	if err != nil {
+		return nil, c.convertError(ctx, workflowID, err)
+	}
+
+	var customer foodordering.CustomerState
+	if err := resp.Get(&customer); err != nil {
+		return nil, fmt.Errorf("unable to decode customer query: %w", err)
+	}
+
+	return &customer, nil
+}
+
+// SaveAddress adds or replaces one of the customer's addresses by its label
+func (c *Client) SaveAddress(ctx context.Context, customerID string, address foodordering.SavedAddress) error {
+	return c.update(ctx, foodordering.CustomerWorkflowID(customerID), foodordering.Updates.SAVE_ADDRESS, nil, address)
+}
+
+func (c *Client) RemoveAddress(ctx context.Context, customerID, label string) error {
+	return c.update(ctx, foodordering.CustomerWorkflowID(customerID), foodordering.Updates.REMOVE_ADDRESS, nil, label)
+}
+
+// Reorder starts a new order from one in the customer's history, returning the
+// new order's ID. The basket can be changed until the order's checked out.
+func (c *Client) Reorder(ctx context.Context, customerID string, req foodordering.ReorderRequest) (string, error) {
+	var orderID string
+	if err := c.update(ctx, foodordering.CustomerWorkflowID(customerID), foodordering.Updates.REORDER, &orderID, req); err != nil {
+		return "", err
+	}
+	return orderID, nil
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package orderclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/temporal"
+)
+
+var (
+	// ErrAlreadyCompleted is returned when changing an order that has finished
+	ErrAlreadyCompleted = errors.New("order already completed")
+	// ErrNotFound is returned when there is no order or customer with the given ID
+	ErrNotFound = errors.New("not found")
+	// ErrValidationRejected is returned when the order refuses a change, eg adding to a locked basket
+	ErrValidationRejected = errors.New("validation rejected")
+)
+
+// Converts errors from the Temporal client into the typed errors
+func (c *Client) convertError(ctx context.Context, workflowID string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) && appErr.Type() == foodordering.UpdateRejectedErrorType {
+		return fmt.Errorf("%w: %s", ErrValidationRejected, appErr.Message())
+	}
+
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		// Closed workflows can't receive signals or updates, but they still exist
+		desc, descErr := c.client.DescribeWorkflowExecution(ctx, workflowID, "")
+		if descErr == nil && desc.GetWorkflowExecutionInfo().GetStatus() != enums.WORKFLOW_EXECUTION_STATUS_RUNNING {
+			return fmt.Errorf("%w: %s", ErrAlreadyCompleted, workflowID)
+		}
+
+		return fmt.Errorf("%w: %s", ErrNotFound, workflowID)
+	}
+
+	return err
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package orderclient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+// ListFilter narrows down the orders returned by List. Empty fields are ignored.
+type ListFilter struct {
+	Collection    *bool
+	CustomerEmail string
+	PageSize      int
+	PageToken     []byte
+	RestaurantID  string
+	Running       bool // Only orders that haven't finished
+	Status        foodordering.OrderStatus
+}
+
+// OrderListItem is an order as stored in the search attributes
+type OrderListItem struct {
+	Collection   bool                     `json:"collection"`
+	OrderID      string                   `json:"orderId"`
+	RestaurantID string                   `json:"restaurantId"`
+	StartTime    time.Time                `json:"startTime"`
+	Status       foodordering.OrderStatus `json:"status"`
+	TotalInPence int                      `json:"totalInPence"`
+}
+
+type OrderList struct {
+	NextPageToken []byte          `json:"nextPageToken"`
+	Orders        []OrderListItem `json:"orders"`
+}
+
+// Query is the visibility query for the filter
+func (f ListFilter) Query() (string, error) {
+	clauses := []string{"WorkflowType = 'OrderWorkflow'"}
+
+	add := func(key, value string) error {
+		if strings.ContainsAny(value, `'"\`) {
+			return fmt.Errorf("invalid %s", key)
+		}
+		clauses = append(clauses, fmt.Sprintf("%s = '%s'", key, value))
+		return nil
+	}
+
+	if f.Running {
+		clauses = append(clauses, "ExecutionStatus = 'Running'")
+	}
+	if f.Status != "" {
+		if err := add(foodordering.OrderStatusSearchAttribute.GetName(), string(f.Status)); err != nil {
+			return "", err
+		}
+	}
+	if f.RestaurantID != "" {
+		if err := add(foodordering.RestaurantIDSearchAttribute.GetName(), f.RestaurantID); err != nil {
+			return "", err
+		}
+	}
+	if f.CustomerEmail != "" {
+		if err := add(foodordering.CustomerEmailSearchAttribute.GetName(), foodordering.HashEmail(f.CustomerEmail)); err != nil {
+			return "", err
+		}
+	}
+	if f.Collection != nil {
+		clauses = append(clauses, fmt.Sprintf("%s = %t", foodordering.CollectionSearchAttribute.GetName(), *f.Collection))
+	}
+
+	// No ORDER BY - SQL visibility doesn't support it. The default order is
+	// running orders first, then the most recently finished.
+	return strings.Join(clauses, " AND "), nil
+}
+
+// List finds orders from their search attributes, without querying each workflow
+func (c *Client) List(ctx context.Context, filter ListFilter) (*OrderList, error) {
+	query, err := filter.Query()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+		NextPageToken: filter.PageToken,
+		PageSize:      int32(filter.PageSize),
+		Query:         query,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing orders: %w", err)
+	}
+
+	list := &OrderList{
+		NextPageToken: resp.GetNextPageToken(),
+		Orders:        make([]OrderListItem, 0, len(resp.GetExecutions())),
+	}
+	for _, e := range resp.GetExecutions() {
+		fields := e.GetSearchAttributes().GetIndexedFields()
+
+		item := OrderListItem{
+			OrderID:   e.GetExecution().GetWorkflowId(),
+			StartTime: e.GetStartTime().AsTime(),
+		}
+
+		var status string
+		var total int64
+		for _, field := range []struct {
+			name  string
+			value any
+		}{
+			{name: foodordering.CollectionSearchAttribute.GetName(), value: &item.Collection},
+			{name: foodordering.OrderStatusSearchAttribute.GetName(), value: &status},
+			{name: foodordering.RestaurantIDSearchAttribute.GetName(), value: &item.RestaurantID},
+			{name: foodordering.TotalSearchAttribute.GetName(), value: &total},
+		} {
+			if err := decodeSearchAttribute(fields[field.name], field.value); err != nil {
+				return nil, fmt.Errorf("error decoding %s for %s: %w", field.name, item.OrderID, err)
+			}
+		}
+		item.Status = foodordering.OrderStatus(status)
+		item.TotalInPence = int(total)
+
+		list.Orders = append(list.Orders, item)
+	}
+
+	return list, nil
+}
+
+// Orders started before the attributes were registered won't have them set
+func decodeSearchAttribute(payload *common.Payload, value any) error {
+	if payload == nil {
+		return nil
+	}
+	return converter.GetDefaultDataConverter().FromPayload(payload, value)
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"context"
+	"errors"
+)
+
+// Error type for payments the customer failed to authorise
+const PaymentDeclinedErrorType = "PaymentDeclined"
+
+// Error type for refunds of charges the provider doesn't know about
+const UnknownChargeErrorType = "UnknownCharge"
+
+var (
+	// ErrPaymentDeclined is the customer's problem, rather than one for ops
+	ErrPaymentDeclined = errors.New("payment declined")
+	// ErrUnknownCharge won't go away by trying again
+	ErrUnknownCharge = errors.New("unknown charge")
+)
+
+type PaymentStatus string
+
+const (
+	PaymentStatusSucceeded PaymentStatus = "SUCCEEDED"
+	PaymentStatusDeclined  PaymentStatus = "DECLINED"
+)
+
+// PaymentChallenge is a payment waiting for the customer to authorise it with their bank (3-D Secure)
+type PaymentChallenge struct {
+	ChargeID string `json:"chargeId"`
+	Payer    string `json:"payer" pii:"true"`
+	URL      string `json:"url"` // Where the customer authorises the payment
+}
+
+// PaymentCallback is sent by the card provider once a challenge is finished
+type PaymentCallback struct {
+	ChargeID string        `json:"chargeId"`
+	Reason   string        `json:"reason"` // Why the payment was declined
+	Status   PaymentStatus `json:"status"`
+}
+
+// PaymentProvider takes card payments. Charges that need a challenge return
+// straight away, with the result sent to the order later.
+type PaymentProvider interface {
+	// Charge takes the payment, or starts a challenge if the bank wants the customer to authorise it
+	Charge(ctx context.Context, req PaymentRequest) (*Payment, error)
+	// Cancel abandons a challenge that's not been finished
+	Cancel(ctx context.Context, chargeID string) error
+	Refund(ctx context.Context, req RefundRequest) error
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package payments
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/webhook"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+)
+
+// CallbackEvent is sent by the provider when a challenge is finished
+type CallbackEvent struct {
+	ChargeID  string                     `json:"chargeId"`
+	OrderID   string                     `json:"orderId"` // The order's workflow ID
+	Reason    string                     `json:"reason,omitempty"`
+	Reference string                     `json:"reference"`
+	Status    foodordering.PaymentStatus `json:"status"`
+}
+
+// CallbackHandler passes the provider's challenge results on to the orders
+func CallbackHandler(c client.Client, secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := webhook.VerifyRequest(r, secret, webhook.DefaultTolerance)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		var event CallbackEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			http.Error(w, "invalid callback", http.StatusBadRequest)
+			return
+		}
+
+		if err := c.SignalWorkflow(r.Context(), event.OrderID, "", foodordering.Signals.PAYMENT_CALLBACK, foodordering.PaymentCallback{
+			ChargeID: event.ChargeID,
+			Reason:   event.Reason,
+			Status:   event.Status,
+		}); err != nil {
+			var notFound *serviceerror.NotFound
+			if errors.As(err, &notFound) {
+				// Nothing's waiting for the payment any more
+				log.Println("Order not running for payment callback", "orderId", event.OrderID, "chargeId", event.ChargeID)
+				w.WriteHeader(http.StatusGone)
+				return
+			}
+
+			log.Println("Error sending payment callback", "orderId", event.OrderID, "error", err)
+			http.Error(w, "error sending callback", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package payments
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/mocks"
+)
+
+const testSecret = "secret"
+
+// Runs the provider and the worker's callback handler, as the worker does
+func testProvider(t *testing.T, c *mocks.Client) (*FakeProvider, *httptest.Server) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	p := NewFakeProvider(srv.URL, srv.URL+"/callback", testSecret)
+	p.AutoChallengeDelay = time.Millisecond
+	p.Routes(mux)
+	mux.Handle("POST /callback", CallbackHandler(c, testSecret))
+
+	return p, srv
+}
+
+func TestChargeWithoutChallenge(t *testing.T) {
+	p, _ := testProvider(t, &mocks.Client{})
+
+	payment, err := p.Charge(context.Background(), foodordering.PaymentRequest{AmountInPence: 1000, Payer: "test@test.com"})
+	assert.NoError(t, err)
+	assert.Nil(t, payment.Challenge)
+	assert.Equal(t, 1000, payment.AmountInPence)
+
+	assert.NoError(t, p.Refund(context.Background(), foodordering.RefundRequest{AmountInPence: 600, TransactionID: payment.TransactionID}))
+	assert.Error(t, p.Refund(context.Background(), foodordering.RefundRequest{AmountInPence: 600, TransactionID: payment.TransactionID}))
+}
+
+func TestChargeIsIdempotent(t *testing.T) {
+	p, _ := testProvider(t, &mocks.Client{})
+
+	req := foodordering.PaymentRequest{AmountInPence: 1000, OrderID: "order-1", Payer: "test+3ds@test.com", Reference: "order-1/charge/0"}
+	payment, err := p.Charge(context.Background(), req)
+	assert.NoError(t, err)
+
+	// Retries get the same challenge
+	retried, err := p.Charge(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, payment, retried)
+
+	// Other charges for the order are new
+	req.Reference = "order-1/charge/1"
+	other, err := p.Charge(context.Background(), req)
+	assert.NoError(t, err)
+	assert.NotEqual(t, payment.TransactionID, other.TransactionID)
+
+	// Retrying a charge that wasn't authorised doesn't start again
+	assert.NoError(t, p.Cancel(context.Background(), other.TransactionID))
+	_, err = p.Charge(context.Background(), req)
+	assert.ErrorIs(t, err, foodordering.ErrPaymentDeclined)
+}
+
+func TestRefundUnknownCharge(t *testing.T) {
+	p, _ := testProvider(t, &mocks.Client{})
+
+	err := p.Refund(context.Background(), foodordering.RefundRequest{AmountInPence: 600, TransactionID: "ch_unknown"})
+	assert.ErrorIs(t, err, foodordering.ErrUnknownCharge)
+}
+
+func TestChargeDeclined(t *testing.T) {
+	p, _ := testProvider(t, &mocks.Client{})
+
+	_, err := p.Charge(context.Background(), foodordering.PaymentRequest{AmountInPence: 1000, Payer: "test+decline@test.com"})
+	assert.ErrorIs(t, err, foodordering.ErrPaymentDeclined)
+}
+
+func TestChallenge(t *testing.T) {
+	tests := []struct {
+		name   string
+		result string
+		status foodordering.PaymentStatus
+	}{
+		{"approved", "approve", foodordering.PaymentStatusSucceeded},
+		{"declined", "decline", foodordering.PaymentStatusDeclined},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &mocks.Client{}
+			p, _ := testProvider(t, c)
+
+			payment, err := p.Charge(context.Background(), foodordering.PaymentRequest{
+				AmountInPence: 1000,
+				OrderID:       "order-1",
+				Payer:         "test+3ds@test.com",
+				Reference:     "order-1/charge/0",
+			})
+			assert.NoError(t, err)
+			assert.NotNil(t, payment.Challenge)
+
+			c.On("SignalWorkflow", mock.Anything, "order-1", "", foodordering.Signals.PAYMENT_CALLBACK, mock.MatchedBy(func(cb foodordering.PaymentCallback) bool {
+				return cb.ChargeID == payment.TransactionID && cb.Status == test.status
+			})).Return(nil).Once()
+
+			// The customer goes to the challenge page and makes their choice
+			resp, err := http.Get(payment.Challenge.URL)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			resp.Body.Close()
+
+			resp, err = http.PostForm(payment.Challenge.URL, url.Values{"result": {test.result}})
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			resp.Body.Close()
+
+			c.AssertExpectations(t)
+
+			// Challenges can only be finished once
+			assert.Error(t, p.Complete(context.Background(), payment.TransactionID, true))
+		})
+	}
+}
+
+func TestAutomaticChallenge(t *testing.T) {
+	c := &mocks.Client{}
+	p, _ := testProvider(t, c)
+
+	done := make(chan struct{})
+	c.On("SignalWorkflow", mock.Anything, "order-1", "", foodordering.Signals.PAYMENT_CALLBACK, mock.MatchedBy(func(cb foodordering.PaymentCallback) bool {
+		return cb.Status == foodordering.PaymentStatusSucceeded
+	})).Return(nil).Run(func(mock.Arguments) {
+		close(done)
+	}).Once()
+
+	_, err := p.Charge(context.Background(), foodordering.PaymentRequest{
+		AmountInPence: 1000,
+		OrderID:       "order-1",
+		Payer:         "test+3ds-approve@test.com",
+		Reference:     "order-1/charge/0",
+	})
+	assert.NoError(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("challenge was not completed")
+	}
+}
+
+func TestCancelledChallenge(t *testing.T) {
+	p, _ := testProvider(t, &mocks.Client{})
+
+	payment, err := p.Charge(context.Background(), foodordering.PaymentRequest{AmountInPence: 1000, Payer: "test+3ds@test.com"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.Cancel(context.Background(), payment.TransactionID))
+	assert.Error(t, p.Complete(context.Background(), payment.TransactionID, true))
+}
+
+func TestCallbackHandler(t *testing.T) {
+	c := &mocks.Client{}
+	_, srv := testProvider(t, c)
+
+	t.Run("unsigned", func(t *testing.T) {
+		resp, err := http.Post(srv.URL+"/callback", "application/json", strings.NewReader(`{"orderId":"order-1"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		resp.Body.Close()
+	})
+
+	t.Run("order finished", func(t *testing.T) {
+		c.On("SignalWorkflow", mock.Anything, "order-2", "", foodordering.Signals.PAYMENT_CALLBACK, mock.Anything).
+			Return(serviceerror.NewNotFound("workflow not found")).Once()
+
+		p := NewFakeProvider(srv.URL, srv.URL+"/callback", testSecret)
+		err := p.sendCallback(context.Background(), CallbackEvent{ChargeID: "ch_1", OrderID: "order-2"})
+		assert.ErrorContains(t, err, "410")
+	})
+}
@@ -0,0 +1,314 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package payments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/webhook"
+)
+
+// Added to the payer, eg test+3ds@test.com, to choose what the fake provider does
+const (
+	TagChallenge        = "3ds"         // Customer authorises the payment on the challenge page
+	TagChallengeApprove = "3ds-approve" // Challenge is approved without the customer
+	TagChallengeDecline = "3ds-decline" // Challenge is declined without the customer
+	TagDecline          = "decline"     // Card is declined straight away
+)
+
+type chargeStatus string
+
+const (
+	chargeStatusPending   chargeStatus = "PENDING"
+	chargeStatusSucceeded chargeStatus = "SUCCEEDED"
+	chargeStatusDeclined  chargeStatus = "DECLINED"
+	chargeStatusCancelled chargeStatus = "CANCELLED"
+)
+
+type charge struct {
+	amountInPence   int
+	id              string
+	orderID         string
+	payer           string
+	reference       string
+	refundedInPence int
+	status          chargeStatus
+}
+
+// The payment as it was returned when the charge was made
+func (p *FakeProvider) payment(c *charge) *foodordering.Payment {
+	payment := &foodordering.Payment{
+		AmountInPence: c.amountInPence,
+		Payer:         c.payer,
+		TransactionID: c.id,
+	}
+	if c.status == chargeStatusPending {
+		payment.Challenge = &foodordering.PaymentChallenge{
+			ChargeID: c.id,
+			Payer:    c.payer,
+			URL:      p.BaseURL + "/challenge/" + c.id,
+		}
+	}
+	return payment
+}
+
+// FakeProvider pretends to be a card provider, with 3-D Secure challenges that
+// finish with a signed callback to the worker
+type FakeProvider struct {
+	AutoChallengeDelay time.Duration // How long challenges finished without the customer take
+	BaseURL            string        // Where the challenge pages are served
+	CallbackURL        string        // Where challenge results are sent
+	Client             *http.Client
+	Secret             string // Signs the callbacks
+
+	mu         sync.Mutex
+	charges    map[string]*charge
+	references map[string]string // Charge IDs by the request's reference
+}
+
+var _ foodordering.PaymentProvider = &FakeProvider{}
+
+// The payer's tag, eg "3ds" for test+3ds@test.com
+func payerTag(payer string) string {
+	local, _, _ := strings.Cut(payer, "@")
+	_, tag, _ := strings.Cut(local, "+")
+	return strings.ToLower(tag)
+}
+
+func (p *FakeProvider) Charge(ctx context.Context, req foodordering.PaymentRequest) (*foodordering.Payment, error) {
+	tag := payerTag(req.Payer)
+	if tag == TagDecline {
+		return nil, fmt.Errorf("%w: card declined", foodordering.ErrPaymentDeclined)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Retries get the charge that was made the first time
+	if id, ok := p.references[req.Reference]; ok && req.Reference != "" {
+		c := p.charges[id]
+		if c.status == chargeStatusDeclined || c.status == chargeStatusCancelled {
+			return nil, fmt.Errorf("%w: charge %s was not authorised", foodordering.ErrPaymentDeclined, id)
+		}
+		return p.payment(c), nil
+	}
+
+	c := &charge{
+		amountInPence: req.AmountInPence,
+		id:            "ch_" + uuid.NewString(),
+		orderID:       req.OrderID,
+		payer:         req.Payer,
+		reference:     req.Reference,
+		status:        chargeStatusSucceeded,
+	}
+
+	switch tag {
+	case TagChallenge, TagChallengeApprove, TagChallengeDecline:
+		c.status = chargeStatusPending
+	}
+
+	p.charges[c.id] = c
+	if req.Reference != "" {
+		p.references[req.Reference] = c.id
+	}
+	payment := p.payment(c)
+
+	if tag == TagChallengeApprove || tag == TagChallengeDecline {
+		go func() {
+			time.Sleep(p.AutoChallengeDelay)
+			if err := p.Complete(context.Background(), payment.TransactionID, tag == TagChallengeApprove); err != nil {
+				log.Println("Error completing challenge", "chargeId", payment.TransactionID, "error", err)
+			}
+		}()
+	}
+
+	return payment, nil
+}
+
+func (p *FakeProvider) Cancel(ctx context.Context, chargeID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if c, ok := p.charges[chargeID]; ok && c.status == chargeStatusPending {
+		c.status = chargeStatusCancelled
+	}
+
+	return nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, req foodordering.RefundRequest) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c, ok := p.charges[req.TransactionID]
+	if !ok {
+		// Charges are forgotten when the worker restarts, so ops need to refund it by hand
+		return fmt.Errorf("%w: %s", foodordering.ErrUnknownCharge, req.TransactionID)
+	}
+	if c.status != chargeStatusSucceeded {
+		return fmt.Errorf("charge %s has not been taken", req.TransactionID)
+	}
+	if c.refundedInPence+req.AmountInPence > c.amountInPence {
+		return fmt.Errorf("refund is more than was charged")
+	}
+	c.refundedInPence += req.AmountInPence
+
+	return nil
+}
+
+// Complete finishes a challenge, sending the result to the callback
+func (p *FakeProvider) Complete(ctx context.Context, chargeID string, approved bool) error {
+	p.mu.Lock()
+	c, ok := p.charges[chargeID]
+	if !ok || c.status != chargeStatusPending {
+		p.mu.Unlock()
+		return fmt.Errorf("charge %s is not waiting to be authorised", chargeID)
+	}
+
+	event := CallbackEvent{
+		ChargeID:  chargeID,
+		OrderID:   c.orderID,
+		Reference: c.reference,
+		Status:    foodordering.PaymentStatusSucceeded,
+	}
+	c.status = chargeStatusSucceeded
+	if !approved {
+		c.status = chargeStatusDeclined
+		event.Reason = "Customer failed authentication"
+		event.Status = foodordering.PaymentStatusDeclined
+	}
+	p.mu.Unlock()
+
+	if err := p.sendCallback(ctx, event); err != nil {
+		// Let the customer try again
+		p.mu.Lock()
+		c.status = chargeStatusPending
+		p.mu.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+func (p *FakeProvider) sendCallback(ctx context.Context, event CallbackEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error encoding callback: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating callback request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.IDHeader, event.ChargeID)
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(p.Secret, body, time.Now()))
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending callback: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+var challengePage = template.Must(template.New("challenge").Parse(`<!DOCTYPE html>
+<html>
+  <head><title>Authorise payment</title></head>
+  <body>
+    {{ if .Done }}
+    <p>Thank you - you can close this page.</p>
+    {{ else }}
+    <p>Authorise the payment of £{{ printf "%.2f" .Amount }} from {{ .Payer }}?</p>
+    <form method="post">
+      <button name="result" value="approve">Approve</button>
+      <button name="result" value="decline">Decline</button>
+    </form>
+    {{ end }}
+  </body>
+</html>`))
+
+// Routes adds the challenge pages the customer is sent to
+func (p *FakeProvider) Routes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /challenge/{chargeId}", p.challenge)
+	mux.HandleFunc("POST /challenge/{chargeId}", p.challenge)
+}
+
+func (p *FakeProvider) challenge(w http.ResponseWriter, r *http.Request) {
+	chargeID := r.PathValue("chargeId")
+
+	p.mu.Lock()
+	c, ok := p.charges[chargeID]
+	var data struct {
+		Amount float64
+		Done   bool
+		Payer  string
+	}
+	if ok {
+		data.Amount = float64(c.amountInPence) / 100
+		data.Done = c.status != chargeStatusPending
+		data.Payer = c.payer
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPost && !data.Done {
+		if err := p.Complete(r.Context(), chargeID, r.FormValue("result") == "approve"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		data.Done = true
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := challengePage.Execute(w, data); err != nil {
+		log.Println("Error rendering challenge page", "error", err)
+	}
+}
+
+func NewFakeProvider(baseURL, callbackURL, secret string) *FakeProvider {
+	return &FakeProvider{
+		AutoChallengeDelay: time.Second * 5,
+		BaseURL:            strings.TrimSuffix(baseURL, "/"),
+		CallbackURL:        callbackURL,
+		Client:             &http.Client{Timeout: time.Second * 10},
+		Secret:             secret,
+		charges:            map[string]*charge{},
+		references:         map[string]string{},
+	}
+}
@@ -16,6 +16,8 @@
 
 package foodordering
 
+import "fmt"
+
 // List of available products - normally would be in a database
 var allProducts = []Product{
 	{
@@ -31,13 +33,25 @@ var allProducts = []Product{
 	{
 		ProductID: 3,
 		Name:      "Battered haddock",
+		Price:     9.75,
 	},
 	{
 		ProductID: 4,
 		Name:      "Curry sauce",
+		Price:     1.45,
 	},
 	{
 		ProductID: 5,
 		Name:      "Gravy",
+		Price:     1.45,
 	},
 }
+
+func GetProduct(productID int) (*Product, error) {
+	for _, p := range allProducts {
+		if p.ProductID == productID {
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown product: %d", productID)
+}
@@ -0,0 +1,292 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package projection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+)
+
+// Runs the ledger movement in a transaction. It takes the write lock straight
+// away, so two workers can't spend the same balance.
+func transact(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) (int, error)) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	result, err := fn(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+type giftCardLedger struct {
+	db *sql.DB
+}
+
+func giftCardBalance(ctx context.Context, tx *sql.Tx, account string) (int, bool, error) {
+	var entries, balance int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*), COALESCE(SUM(amount_in_pence), 0) FROM gift_card_entries WHERE account = ?`,
+		account,
+	).Scan(&entries, &balance); err != nil {
+		return 0, false, fmt.Errorf("error getting gift card balance: %w", err)
+	}
+
+	// Wallets exist for every customer, even if they're empty
+	exists := entries > 0 || foodordering.IsWalletAccount(account)
+
+	return balance, exists, nil
+}
+
+func (l *giftCardLedger) Balance(ctx context.Context, account string) (int, error) {
+	return transact(ctx, l.db, func(tx *sql.Tx) (int, error) {
+		balance, exists, err := giftCardBalance(ctx, tx, account)
+		if err != nil {
+			return 0, err
+		}
+		if !exists {
+			return 0, foodordering.ErrUnknownGiftCard
+		}
+
+		return balance, nil
+	})
+}
+
+func (l *giftCardLedger) Credit(ctx context.Context, account, reference string, amountInPence int) (int, error) {
+	if amountInPence < 0 {
+		return 0, fmt.Errorf("cannot credit a negative amount")
+	}
+
+	return transact(ctx, l.db, func(tx *sql.Tx) (int, error) {
+		_, exists, err := giftCardBalance(ctx, tx, account)
+		if err != nil {
+			return 0, err
+		}
+		if !exists {
+			return 0, foodordering.ErrUnknownGiftCard
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO gift_card_entries (account, reference, amount_in_pence, created_at) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+			account, reference, amountInPence, time.Now().UTC(),
+		); err != nil {
+			return 0, fmt.Errorf("error crediting gift card: %w", err)
+		}
+
+		balance, _, err := giftCardBalance(ctx, tx, account)
+		return balance, err
+	})
+}
+
+func (l *giftCardLedger) Debit(ctx context.Context, account, reference string, amountInPence int) (int, error) {
+	if amountInPence < 0 {
+		return 0, fmt.Errorf("cannot debit a negative amount")
+	}
+
+	return transact(ctx, l.db, func(tx *sql.Tx) (int, error) {
+		balance, exists, err := giftCardBalance(ctx, tx, account)
+		if err != nil {
+			return 0, err
+		}
+		if !exists {
+			return 0, foodordering.ErrUnknownGiftCard
+		}
+
+		// Retries get what was taken the first time
+		var debited int
+		err = tx.QueryRowContext(ctx,
+			`SELECT -amount_in_pence FROM gift_card_entries WHERE account = ? AND reference = ?`,
+			account, reference,
+		).Scan(&debited)
+		if err == nil {
+			return debited, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("error getting gift card entry: %w", err)
+		}
+
+		debited = min(amountInPence, balance)
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO gift_card_entries (account, reference, amount_in_pence, created_at) VALUES (?, ?, ?, ?)`,
+			account, reference, -debited, time.Now().UTC(),
+		); err != nil {
+			return 0, fmt.Errorf("error debiting gift card: %w", err)
+		}
+
+		return debited, nil
+	})
+}
+
+type loyaltyLedger struct {
+	db *sql.DB
+}
+
+func loyaltyBalance(ctx context.Context, tx *sql.Tx, customerID string) (int, error) {
+	var balance int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(points), 0) FROM loyalty_entries WHERE customer_id = ?`,
+		customerID,
+	).Scan(&balance); err != nil {
+		return 0, fmt.Errorf("error getting loyalty balance: %w", err)
+	}
+	return balance, nil
+}
+
+// The points moved by the reference, if it's already been applied
+func loyaltyEntry(ctx context.Context, tx *sql.Tx, customerID, reference string) (*int, error) {
+	var points int
+	err := tx.QueryRowContext(ctx,
+		`SELECT points FROM loyalty_entries WHERE customer_id = ? AND reference = ?`,
+		customerID, reference,
+	).Scan(&points)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting loyalty entry: %w", err)
+	}
+	return &points, nil
+}
+
+func addLoyaltyEntry(ctx context.Context, tx *sql.Tx, customerID, reference string, points int) error {
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO loyalty_entries (customer_id, reference, points, created_at) VALUES (?, ?, ?, ?)`,
+		customerID, reference, points, time.Now().UTC(),
+	); err != nil {
+		return fmt.Errorf("error adding loyalty entry: %w", err)
+	}
+	return nil
+}
+
+func (l *loyaltyLedger) Balance(ctx context.Context, customerID string) (int, error) {
+	return transact(ctx, l.db, func(tx *sql.Tx) (int, error) {
+		return loyaltyBalance(ctx, tx, customerID)
+	})
+}
+
+func (l *loyaltyLedger) Credit(ctx context.Context, customerID, reference string, points int) (int, error) {
+	if points < 0 {
+		return 0, fmt.Errorf("cannot credit negative points")
+	}
+
+	return transact(ctx, l.db, func(tx *sql.Tx) (int, error) {
+		entry, err := loyaltyEntry(ctx, tx, customerID, reference)
+		if err != nil {
+			return 0, err
+		}
+		if entry == nil {
+			if err := addLoyaltyEntry(ctx, tx, customerID, reference, points); err != nil {
+				return 0, err
+			}
+		}
+
+		return loyaltyBalance(ctx, tx, customerID)
+	})
+}
+
+func (l *loyaltyLedger) Debit(ctx context.Context, customerID, reference string, points int) (int, error) {
+	if points < 0 {
+		return 0, fmt.Errorf("cannot debit negative points")
+	}
+
+	return transact(ctx, l.db, func(tx *sql.Tx) (int, error) {
+		entry, err := loyaltyEntry(ctx, tx, customerID, reference)
+		if err != nil {
+			return 0, err
+		}
+		if entry == nil {
+			balance, err := loyaltyBalance(ctx, tx, customerID)
+			if err != nil {
+				return 0, err
+			}
+			if balance < points {
+				return 0, foodordering.ErrInsufficientPoints
+			}
+			if err := addLoyaltyEntry(ctx, tx, customerID, reference, -points); err != nil {
+				return 0, err
+			}
+		}
+
+		return loyaltyBalance(ctx, tx, customerID)
+	})
+}
+
+func (l *loyaltyLedger) Clawback(ctx context.Context, customerID, reference string, points int) (int, error) {
+	if points < 0 {
+		return 0, fmt.Errorf("cannot claw back negative points")
+	}
+
+	return transact(ctx, l.db, func(tx *sql.Tx) (int, error) {
+		entry, err := loyaltyEntry(ctx, tx, customerID, reference)
+		if err != nil {
+			return 0, err
+		}
+		if entry != nil {
+			return -*entry, nil
+		}
+
+		// Anything already spent can't be taken back
+		balance, err := loyaltyBalance(ctx, tx, customerID)
+		if err != nil {
+			return 0, err
+		}
+		points = min(points, balance)
+		if err := addLoyaltyEntry(ctx, tx, customerID, reference, -points); err != nil {
+			return 0, err
+		}
+
+		return points, nil
+	})
+}
+
+// GiftCardLedger keeps the gift card and wallet balances in the database
+func (s *Store) GiftCardLedger() foodordering.GiftCardLedger {
+	return &giftCardLedger{db: s.db}
+}
+
+// IssueGiftCard puts the starting balance on the gift card, once
+func (s *Store) IssueGiftCard(ctx context.Context, code string, amountInPence int) error {
+	account := foodordering.PaymentMethod{Type: foodordering.PaymentMethodGiftCard, Code: code}.Account("")
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO gift_card_entries (account, reference, amount_in_pence, created_at) VALUES (?, 'issued', ?, ?) ON CONFLICT DO NOTHING`,
+		account, amountInPence, time.Now().UTC(),
+	); err != nil {
+		return fmt.Errorf("error issuing gift card: %w", err)
+	}
+	return nil
+}
+
+// LoyaltyLedger keeps the customers' points in the database
+func (s *Store) LoyaltyLedger() foodordering.LoyaltyLedger {
+	return &loyaltyLedger{db: s.db}
+}
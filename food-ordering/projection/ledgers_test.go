@@ -0,0 +1,135 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package projection
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGiftCardLedger(t *testing.T) {
+	ctx := context.Background()
+	s, path := openTestStore(t)
+	assert.NoError(t, s.IssueGiftCard(ctx, "GIFT-1000", 1000))
+	// Issuing is only done once
+	assert.NoError(t, s.IssueGiftCard(ctx, "GIFT-1000", 1000))
+
+	l := s.GiftCardLedger()
+	account := foodordering.PaymentMethod{Type: foodordering.PaymentMethodGiftCard, Code: " gift-1000 "}.Account("")
+
+	debited, err := l.Debit(ctx, account, "order-1", 600)
+	assert.NoError(t, err)
+	assert.Equal(t, 600, debited)
+
+	// Retries don't take the money twice, even from another worker
+	other, err := Open(ctx, path)
+	assert.NoError(t, err)
+	defer other.Close()
+
+	debited, err = other.GiftCardLedger().Debit(ctx, account, "order-1", 600)
+	assert.NoError(t, err)
+	assert.Equal(t, 600, debited)
+
+	// Only what's left is taken
+	debited, err = other.GiftCardLedger().Debit(ctx, account, "order-2", 600)
+	assert.NoError(t, err)
+	assert.Equal(t, 400, debited)
+
+	balance, err := l.Credit(ctx, account, "order-1/refund", 250)
+	assert.NoError(t, err)
+	assert.Equal(t, 250, balance)
+
+	_, err = l.Debit(ctx, "giftcard/NOPE", "order-3", 100)
+	assert.ErrorIs(t, err, foodordering.ErrUnknownGiftCard)
+
+	// Wallets exist for every customer, even if they're empty
+	balance, err = l.Balance(ctx, foodordering.PaymentMethod{Type: foodordering.PaymentMethodWallet}.Account("customer-1"))
+	assert.NoError(t, err)
+	assert.Zero(t, balance)
+}
+
+func TestGiftCardLedgerIsNotOverspent(t *testing.T) {
+	ctx := context.Background()
+	s, path := openTestStore(t)
+	assert.NoError(t, s.IssueGiftCard(ctx, "GIFT-1000", 1000))
+
+	other, err := Open(ctx, path)
+	assert.NoError(t, err)
+	defer other.Close()
+
+	account := foodordering.PaymentMethod{Type: foodordering.PaymentMethodGiftCard, Code: "GIFT-1000"}.Account("")
+	ledgers := []foodordering.GiftCardLedger{s.GiftCardLedger(), other.GiftCardLedger()}
+
+	var wg sync.WaitGroup
+	debited := make([]int, 10)
+	for i := range debited {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			amount, err := ledgers[i%2].Debit(ctx, account, fmt.Sprintf("order-%d", i), 300)
+			assert.NoError(t, err)
+			debited[i] = amount
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for _, amount := range debited {
+		total += amount
+	}
+	assert.Equal(t, 1000, total)
+}
+
+func TestLoyaltyLedger(t *testing.T) {
+	ctx := context.Background()
+	s, _ := openTestStore(t)
+	l := s.LoyaltyLedger()
+
+	balance, err := l.Credit(ctx, "customer-1", "order-1/earn", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 100, balance)
+
+	// Retries aren't applied twice
+	balance, err = l.Credit(ctx, "customer-1", "order-1/earn", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 100, balance)
+
+	balance, err = l.Debit(ctx, "customer-1", "order-2/redeem", 60)
+	assert.NoError(t, err)
+	assert.Equal(t, 40, balance)
+
+	_, err = l.Debit(ctx, "customer-1", "order-3/redeem", 60)
+	assert.ErrorIs(t, err, foodordering.ErrInsufficientPoints)
+
+	// Points already spent can't be clawed back
+	clawed, err := l.Clawback(ctx, "customer-1", "order-1/clawback", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 40, clawed)
+
+	clawed, err = l.Clawback(ctx, "customer-1", "order-1/clawback", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 40, clawed)
+
+	balance, err = l.Balance(ctx, "customer-1")
+	assert.NoError(t, err)
+	assert.Zero(t, balance)
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package projection
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Migrations are run in order and never changed once released - add a new one instead
+var migrations = []string{
+	// 1: orders, line items, status history, refunds and tips, plus the shared ledgers and risk history
+	`
+	CREATE TABLE orders (
+		order_id TEXT PRIMARY KEY,
+		restaurant_id TEXT NOT NULL,
+		customer_id TEXT NOT NULL,
+		email_hash TEXT NOT NULL,
+		collection BOOLEAN NOT NULL,
+		status TEXT NOT NULL,
+		subtotal_in_pence INTEGER NOT NULL,
+		discount_in_pence INTEGER NOT NULL,
+		total_in_pence INTEGER NOT NULL,
+		refunded_in_pence INTEGER NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		completed_at TIMESTAMP,
+		last_event_id INTEGER NOT NULL
+	);
+
+	CREATE INDEX orders_status ON orders (status);
+	CREATE INDEX orders_created_at ON orders (created_at);
+	CREATE INDEX orders_completed_at ON orders (completed_at);
+	CREATE INDEX orders_restaurant_id ON orders (restaurant_id);
+
+	CREATE TABLE order_items (
+		order_id TEXT NOT NULL REFERENCES orders (order_id) ON DELETE CASCADE,
+		line INTEGER NOT NULL,
+		product_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		quantity INTEGER NOT NULL,
+		total_in_pence INTEGER NOT NULL,
+		owner TEXT NOT NULL,
+		PRIMARY KEY (order_id, line)
+	);
+
+	CREATE TABLE order_status_history (
+		order_id TEXT NOT NULL REFERENCES orders (order_id) ON DELETE CASCADE,
+		event_id INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		time TIMESTAMP NOT NULL,
+		PRIMARY KEY (order_id, event_id)
+	);
+
+	CREATE TABLE order_refunds (
+		order_id TEXT NOT NULL REFERENCES orders (order_id) ON DELETE CASCADE,
+		refunded_total_in_pence INTEGER NOT NULL,
+		amount_in_pence INTEGER NOT NULL,
+		refunded_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (order_id, refunded_total_in_pence)
+	);
+
+	CREATE INDEX order_refunds_refunded_at ON order_refunds (refunded_at);
+
+	-- Tips are kept apart from the food revenue
+	CREATE TABLE order_tips (
+		order_id TEXT NOT NULL REFERENCES orders (order_id) ON DELETE CASCADE,
+		tip_id TEXT NOT NULL,
+		amount_in_pence INTEGER NOT NULL,
+		kitchen_in_pence INTEGER NOT NULL,
+		courier_in_pence INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		tipped_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (order_id, tip_id)
+	);
+
+	CREATE TABLE processed_events (
+		event_id TEXT PRIMARY KEY,
+		processed_at TIMESTAMP NOT NULL
+	);
+
+	-- Shared so the velocity rules count orders from every worker
+	CREATE TABLE risk_orders (
+		order_id TEXT PRIMARY KEY,
+		email_hash TEXT NOT NULL,
+		ip_address_hash TEXT NOT NULL,
+		postcode_hash TEXT NOT NULL,
+		ordered_at TIMESTAMP NOT NULL
+	);
+
+	CREATE INDEX risk_orders_ordered_at ON risk_orders (ordered_at);
+
+	-- Shared so every worker sees the same balances
+	CREATE TABLE gift_card_entries (
+		account TEXT NOT NULL,
+		reference TEXT NOT NULL,
+		amount_in_pence INTEGER NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (account, reference)
+	);
+
+	CREATE TABLE loyalty_entries (
+		customer_id TEXT NOT NULL,
+		reference TEXT NOT NULL,
+		points INTEGER NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (customer_id, reference)
+	);
+	`,
+}
+
+// migrate brings the database schema up to date
+func migrate(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return fmt.Errorf("error creating migrations table: %w", err)
+	}
+
+	var version int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return fmt.Errorf("error getting schema version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("error starting migration %d: %w", i+1, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("error running migration %d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, i+1); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("error recording migration %d: %w", i+1, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("error committing migration %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
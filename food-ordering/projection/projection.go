@@ -0,0 +1,214 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package projection keeps a SQL read model of the orders, built from the
+// changes published by the workflow. Reports should use this rather than
+// Temporal visibility, which forgets orders once they're deleted.
+//
+// It's a single SQLite file, so it's only shared by processes on the same
+// machine. Every worker and the API must open the same DATABASE_PATH - running
+// workers on different machines gives each its own read model and ledgers, which
+// isn't supported.
+package projection
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+)
+
+// Store is the read model - it's also the publisher for the workflow's outbox
+type Store struct {
+	db *sql.DB
+}
+
+var (
+	_ foodordering.EventPublisher = &Store{}
+	_ foodordering.SalesReporter  = &Store{}
+)
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Publish projects the order change into the read model. Changes that have
+// already been projected are ignored, and older changes don't overwrite newer ones.
+func (s *Store) Publish(ctx context.Context, change foodordering.OrderChange) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO processed_events (event_id, processed_at) VALUES (?, ?) ON CONFLICT DO NOTHING`,
+		change.ID, time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("error recording event: %w", err)
+	}
+	if rows, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("error recording event: %w", err)
+	} else if rows == 0 {
+		// Already projected
+		return nil
+	}
+
+	order := change.Order
+	refunded := order.RefundedInPence()
+
+	createdAt := change.Time
+	var completedAt any
+	if len(order.History) > 0 {
+		createdAt = order.History[0].Time
+
+		// However it finished - reports count it on that day
+		if last := order.History[len(order.History)-1]; last.Status.IsTerminal() {
+			completedAt = last.Time.UTC()
+		}
+	}
+
+	res, err = tx.ExecContext(ctx, `
+		INSERT INTO orders (
+			order_id, restaurant_id, customer_id, email_hash, collection, status,
+			subtotal_in_pence, discount_in_pence, total_in_pence, refunded_in_pence,
+			created_at, updated_at, completed_at, last_event_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?, ?)
+		ON CONFLICT (order_id) DO UPDATE SET
+			restaurant_id = excluded.restaurant_id,
+			status = excluded.status,
+			subtotal_in_pence = excluded.subtotal_in_pence,
+			discount_in_pence = excluded.discount_in_pence,
+			total_in_pence = excluded.total_in_pence,
+			updated_at = excluded.updated_at,
+			completed_at = excluded.completed_at,
+			last_event_id = excluded.last_event_id
+		WHERE excluded.last_event_id > orders.last_event_id`,
+		change.OrderID,
+		change.RestaurantID,
+		order.CustomerID,
+		foodordering.HashEmail(order.Email),
+		order.Collection,
+		change.Status,
+		order.Subtotal(),
+		order.Subtotal()-order.Total(),
+		order.Total(),
+		createdAt.UTC(),
+		change.Time.UTC(),
+		completedAt,
+		change.EventID,
+	)
+	if err != nil {
+		return fmt.Errorf("error saving order: %w", err)
+	}
+
+	// Only replace the line items if this is the latest change
+	if rows, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("error saving order: %w", err)
+	} else if rows > 0 {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM order_items WHERE order_id = ?`, change.OrderID); err != nil {
+			return fmt.Errorf("error clearing order items: %w", err)
+		}
+
+		for i, p := range order.Products {
+			name := fmt.Sprintf("Product %d", p.ProductID)
+			if product, err := foodordering.GetProduct(p.ProductID); err == nil {
+				name = product.Name
+			}
+
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO order_items (order_id, line, product_id, name, quantity, total_in_pence, owner)
+				VALUES (?, ?, ?, ?, ?, ?, ?)`,
+				change.OrderID, i+1, p.ProductID, name, p.Quantity, p.TotalInPence(), p.Owner,
+			); err != nil {
+				return fmt.Errorf("error saving order item: %w", err)
+			}
+		}
+	}
+
+	// Refunds only ever go up, so whichever change brings a new total records the refund
+	var projected int
+	if err := tx.QueryRowContext(ctx, `SELECT refunded_in_pence FROM orders WHERE order_id = ?`, change.OrderID).Scan(&projected); err != nil {
+		return fmt.Errorf("error getting refunds: %w", err)
+	}
+	if refunded > projected {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO order_refunds (order_id, refunded_total_in_pence, amount_in_pence, refunded_at)
+			VALUES (?, ?, ?, ?)`,
+			change.OrderID, refunded, refunded-projected, change.Time.UTC(),
+		); err != nil {
+			return fmt.Errorf("error saving refund: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE orders SET refunded_in_pence = ? WHERE order_id = ?`, refunded, change.OrderID); err != nil {
+			return fmt.Errorf("error saving refund: %w", err)
+		}
+	}
+
+	// The history is in every change, so earlier changes that went missing are filled in
+	for _, h := range order.History {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO order_status_history (order_id, event_id, status, time)
+			VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+			change.OrderID, h.EventID, h.Status, h.Time.UTC(),
+		); err != nil {
+			return fmt.Errorf("error saving status history: %w", err)
+		}
+	}
+
+	for _, t := range order.Tips {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO order_tips (order_id, tip_id, amount_in_pence, kitchen_in_pence, courier_in_pence, status, tipped_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (order_id, tip_id) DO UPDATE SET status = excluded.status`,
+			change.OrderID, t.TipID, t.AmountInPence, t.KitchenInPence, t.CourierInPence, t.Status, t.TippedAt.UTC(),
+		); err != nil {
+			return fmt.Errorf("error saving tip: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing order change: %w", err)
+	}
+
+	return nil
+}
+
+// Open connects to the SQLite database at the path, creating and migrating it
+// if needed. The file must be on a local disk - SQLite's locks don't work over
+// network filesystems.
+func Open(ctx context.Context, path string) (*Store, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate", path))
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+	// SQLite only allows one writer at a time. Transactions take the write lock
+	// when they start, so balances read in them can't change before they're spent.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
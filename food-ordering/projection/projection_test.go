@@ -0,0 +1,303 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package projection
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/stretchr/testify/assert"
+)
+
+func openTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "orders.db")
+	s, err := Open(context.Background(), path)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+
+	return s, path
+}
+
+// Builds the changes an order makes as it goes through the statuses
+func orderChanges(orderID string, start time.Time, statuses ...foodordering.OrderStatus) []foodordering.OrderChange {
+	state := foodordering.NewOrderState()
+	state.Email = "test@test.com"
+	state.Collection = true
+	state.AddItem(foodordering.OrderProduct{ProductID: 1, Quantity: 2})
+	state.AddItem(foodordering.OrderProduct{ProductID: 2, Quantity: 1})
+
+	changes := make([]foodordering.OrderChange, 0)
+	for i, status := range statuses {
+		state.SetStatus(status, start.Add(time.Duration(i)*time.Minute))
+		changes = append(changes, foodordering.NewOrderChange(orderID, "codfather", state))
+
+		// Stop later changes altering this one's state
+		state.History = append([]foodordering.StatusChange{}, state.History...)
+	}
+
+	return changes
+}
+
+func TestMigrationsAreRepeatable(t *testing.T) {
+	s, path := openTestStore(t)
+	assert.NoError(t, s.Close())
+
+	s, err := Open(context.Background(), path)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Close())
+}
+
+func TestPublishIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	s, _ := openTestStore(t)
+
+	changes := orderChanges("order-1", time.Now(),
+		foodordering.OrderStatusDefault,
+		foodordering.OrderStatusPending,
+		foodordering.OrderStatusAccepted,
+	)
+
+	// Retries publish the same change more than once
+	for _, c := range append(changes, changes...) {
+		assert.NoError(t, s.Publish(ctx, c))
+	}
+
+	order, err := s.GetOrder(ctx, "order-1")
+	assert.NoError(t, err)
+	assert.Equal(t, foodordering.OrderStatusAccepted, order.Status)
+	assert.Len(t, order.Items, 2)
+	assert.Len(t, order.History, 3)
+	assert.Equal(t, 1575, order.TotalInPence)
+}
+
+func TestPublishOutOfOrder(t *testing.T) {
+	ctx := context.Background()
+	s, _ := openTestStore(t)
+
+	changes := orderChanges("order-1", time.Now(),
+		foodordering.OrderStatusDefault,
+		foodordering.OrderStatusPending,
+		foodordering.OrderStatusAccepted,
+	)
+
+	assert.NoError(t, s.Publish(ctx, changes[2]))
+	assert.NoError(t, s.Publish(ctx, changes[1]))
+
+	order, err := s.GetOrder(ctx, "order-1")
+	assert.NoError(t, err)
+	assert.Equal(t, foodordering.OrderStatusAccepted, order.Status)
+	assert.Len(t, order.History, 3)
+}
+
+func TestGetOrderNotProjected(t *testing.T) {
+	s, _ := openTestStore(t)
+
+	order, err := s.GetOrder(context.Background(), "unknown")
+	assert.NoError(t, err)
+	assert.Nil(t, order)
+}
+
+func TestReports(t *testing.T) {
+	ctx := context.Background()
+	s, _ := openTestStore(t)
+
+	day := time.Date(2025, time.June, 6, 12, 0, 0, 0, time.UTC)
+
+	for _, c := range orderChanges("order-1", day, foodordering.OrderStatusPending, foodordering.OrderStatusCompleted) {
+		assert.NoError(t, s.Publish(ctx, c))
+	}
+	for _, c := range orderChanges("order-2", day.Add(time.Hour), foodordering.OrderStatusPending, foodordering.OrderStatusRejected) {
+		assert.NoError(t, s.Publish(ctx, c))
+	}
+	// The day after
+	for _, c := range orderChanges("order-3", day.Add(24*time.Hour), foodordering.OrderStatusPending, foodordering.OrderStatusCompleted) {
+		assert.NoError(t, s.Publish(ctx, c))
+	}
+
+	filter := OrderFilter{
+		From: day.Truncate(24 * time.Hour),
+		To:   day.Truncate(24 * time.Hour).Add(24 * time.Hour),
+	}
+
+	summary, err := s.Summary(ctx, filter)
+	assert.NoError(t, err)
+	assert.Equal(t, &SalesSummary{
+		AverageOrderInPence: 1575,
+		CompletedOrders:     1,
+		Orders:              2,
+		RejectedOrders:      1,
+		RevenueInPence:      1575,
+	}, summary)
+
+	products, err := s.ProductSales(ctx, filter)
+	assert.NoError(t, err)
+	assert.Equal(t, []ProductSales{
+		{Name: "Chips", ProductID: 1, Quantity: 2, RevenueInPence: 700},
+		{Name: "Battered cod", ProductID: 2, Quantity: 1, RevenueInPence: 875},
+	}, products)
+
+	orders, err := s.ListOrders(ctx, OrderFilter{Status: foodordering.OrderStatusCompleted})
+	assert.NoError(t, err)
+	assert.Len(t, orders, 2)
+	assert.Equal(t, "order-3", orders[0].OrderID)
+}
+
+func TestReportsCountWhenItHappened(t *testing.T) {
+	ctx := context.Background()
+	s, _ := openTestStore(t)
+
+	day := time.Date(2025, time.June, 6, 23, 30, 0, 0, time.UTC)
+	nextDay := OrderFilter{
+		From: time.Date(2025, time.June, 7, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2025, time.June, 8, 0, 0, 0, 0, time.UTC),
+	}
+
+	// Made before midnight and finished after it
+	changes := orderChanges("order-1", day, foodordering.OrderStatusPending, foodordering.OrderStatusCompleted)
+	changes[1].Order.History[1].Time = day.Add(time.Hour)
+	for _, c := range changes {
+		assert.NoError(t, s.Publish(ctx, c))
+	}
+
+	summary, err := s.Summary(ctx, OrderFilter{From: day.Truncate(24 * time.Hour), To: nextDay.From})
+	assert.NoError(t, err)
+	assert.Zero(t, summary.Orders)
+
+	summary, err = s.Summary(ctx, nextDay)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, summary.CompletedOrders)
+	assert.Equal(t, 1575, summary.RevenueInPence)
+
+	// Refunded the day after it finished
+	state := changes[1].Order
+	state.Payments = []foodordering.Payment{{AmountInPence: 1575, RefundedInPence: 350}}
+	change := foodordering.NewRefundChange("order-1", "codfather", state, day.Add(25*time.Hour))
+	assert.NoError(t, s.Publish(ctx, change))
+	assert.NoError(t, s.Publish(ctx, change))
+
+	summary, err = s.Summary(ctx, nextDay)
+	assert.NoError(t, err)
+	assert.Equal(t, 1575, summary.RevenueInPence)
+	assert.Zero(t, summary.RefundedInPence)
+
+	summary, err = s.Summary(ctx, OrderFilter{From: nextDay.To, To: nextDay.To.Add(24 * time.Hour)})
+	assert.NoError(t, err)
+	assert.Equal(t, &SalesSummary{
+		Orders:          1,
+		RefundedInPence: 350,
+		RefundedOrders:  1,
+		RevenueInPence:  -350,
+	}, summary)
+
+	order, err := s.GetOrder(ctx, "order-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 350, order.RefundedInPence)
+	assert.Equal(t, day.Add(time.Hour), *order.CompletedAt)
+}
+
+func TestDailySales(t *testing.T) {
+	ctx := context.Background()
+	s, _ := openTestStore(t)
+
+	day := time.Date(2025, time.June, 6, 12, 0, 0, 0, time.UTC)
+
+	for _, c := range orderChanges("order-1", day, foodordering.OrderStatusPending, foodordering.OrderStatusCompleted) {
+		assert.NoError(t, s.Publish(ctx, c))
+	}
+	for _, c := range orderChanges("order-2", day.Add(time.Hour), foodordering.OrderStatusPending, foodordering.OrderStatusRejected) {
+		assert.NoError(t, s.Publish(ctx, c))
+	}
+
+	report, err := s.DailySales(ctx, foodordering.SalesReportRequest{
+		Date:         "2025-06-06",
+		From:         day.Truncate(24 * time.Hour),
+		RestaurantID: "codfather",
+		To:           day.Truncate(24 * time.Hour).Add(24 * time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &foodordering.DailySalesReport{
+		AverageOrderInPence: 1575,
+		CompletedOrders:     1,
+		Date:                "2025-06-06",
+		RejectedOrders:      1,
+		RestaurantID:        "codfather",
+		RevenueInPence:      1575,
+		StatusDurations: []foodordering.StatusDuration{
+			{AverageSeconds: 60, Orders: 2, Status: foodordering.OrderStatusPending},
+		},
+		TopProducts: []foodordering.ReportProduct{
+			{Name: "Chips", ProductID: 1, Quantity: 2, RevenueInPence: 700},
+			{Name: "Battered cod", ProductID: 2, Quantity: 1, RevenueInPence: 875},
+		},
+	}, report)
+
+	csv, err := report.CSV()
+	assert.NoError(t, err)
+	assert.Contains(t, string(csv), "revenue,15.75\n")
+	assert.Contains(t, string(csv), "Chips,2,7.00\n")
+	assert.Contains(t, string(csv), "PENDING,2,1.0\n")
+}
+
+func TestTipsReportedSeparately(t *testing.T) {
+	ctx := context.Background()
+	s, _ := openTestStore(t)
+
+	day := time.Date(2025, time.June, 6, 12, 0, 0, 0, time.UTC)
+	rules := foodordering.TipRules{CourierPercent: 80}
+
+	changes := orderChanges("order-1", day, foodordering.OrderStatusPending, foodordering.OrderStatusCompleted)
+	for _, c := range changes {
+		assert.NoError(t, s.Publish(ctx, c))
+	}
+
+	// Tipped after completion, so there's no status change
+	state := changes[len(changes)-1].Order
+	state.Collection = false
+	for _, amount := range []int{250, 99} {
+		tip := state.NewTip(foodordering.TipRequest{AmountInPence: amount}, rules, day.Add(time.Hour))
+		tip.Status = foodordering.TipStatusPaid
+		state.Tips = append(state.Tips, tip)
+
+		// Publishing twice doesn't count it twice
+		change := foodordering.NewTipChange("order-1", "codfather", state, tip)
+		assert.NoError(t, s.Publish(ctx, change))
+		assert.NoError(t, s.Publish(ctx, change))
+	}
+
+	summary, err := s.Summary(ctx, OrderFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1575, summary.RevenueInPence)
+	assert.Equal(t, 349, summary.TipsInPence)
+	assert.Equal(t, 50+20, summary.KitchenTipsInPence)
+	assert.Equal(t, 200+79, summary.CourierTipsInPence)
+
+	// Refunded tips aren't counted
+	state.Tips[0].Status = foodordering.TipStatusRefunded
+	state.SetStatus(foodordering.OrderStatusCompleted, day.Add(2*time.Hour))
+	assert.NoError(t, s.Publish(ctx, foodordering.NewOrderChange("order-1", "codfather", state)))
+
+	summary, err = s.Summary(ctx, OrderFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 99, summary.TipsInPence)
+}
@@ -0,0 +1,414 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package projection
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+)
+
+type OrderItem struct {
+	Line         int    `json:"line"`
+	Name         string `json:"name"`
+	Owner        string `json:"owner"`
+	ProductID    int    `json:"productId"`
+	Quantity     int    `json:"quantity"`
+	TotalInPence int    `json:"totalInPence"`
+}
+
+type Order struct {
+	Collection      bool                        `json:"collection"`
+	CompletedAt     *time.Time                  `json:"completedAt"` // However it finished - nil whilst it's in progress
+	CreatedAt       time.Time                   `json:"createdAt"`
+	CustomerID      string                      `json:"customerId"`
+	DiscountInPence int                         `json:"discountInPence"`
+	History         []foodordering.StatusChange `json:"history,omitempty"`
+	Items           []OrderItem                 `json:"items,omitempty"`
+	OrderID         string                      `json:"orderId"`
+	RefundedInPence int                         `json:"refundedInPence"`
+	RestaurantID    string                      `json:"restaurantId"`
+	Status          foodordering.OrderStatus    `json:"status"`
+	SubtotalInPence int                         `json:"subtotalInPence"`
+	TotalInPence    int                         `json:"totalInPence"`
+	UpdatedAt       time.Time                   `json:"updatedAt"`
+}
+
+// OrderFilter narrows down the orders - empty fields are ignored
+type OrderFilter struct {
+	From         time.Time // Finished or refunded at or after
+	Limit        int
+	RestaurantID string
+	Status       foodordering.OrderStatus
+	To           time.Time // Finished or refunded before
+}
+
+// args are named, so the period can be used more than once in a query
+func (f OrderFilter) args() []any {
+	return []any{
+		sql.Named("from", f.From.UTC()),
+		sql.Named("limit", f.Limit),
+		sql.Named("restaurant_id", f.RestaurantID),
+		sql.Named("status", string(f.Status)),
+		sql.Named("to", f.To.UTC()),
+	}
+}
+
+// during is true if the time's in the period
+func (f OrderFilter) during(column string) string {
+	clauses := []string{column + " IS NOT NULL"}
+
+	if !f.From.IsZero() {
+		clauses = append(clauses, column+" >= @from")
+	}
+	if !f.To.IsZero() {
+		clauses = append(clauses, column+" < @to")
+	}
+
+	return "(" + strings.Join(clauses, " AND ") + ")"
+}
+
+// scope is the orders the filter's for, whenever they happened
+func (f OrderFilter) scope() string {
+	clauses := []string{"1 = 1"}
+
+	if f.RestaurantID != "" {
+		clauses = append(clauses, "restaurant_id = @restaurant_id")
+	}
+	if f.Status != "" {
+		clauses = append(clauses, "status = @status")
+	}
+
+	return strings.Join(clauses, " AND ")
+}
+
+// where is the orders that finished or were refunded in the period, so the
+// reports count them on the day it happened rather than when they were made
+func (f OrderFilter) where() string {
+	if f.From.IsZero() && f.To.IsZero() {
+		return f.scope()
+	}
+
+	return fmt.Sprintf(`%s AND (%s OR EXISTS (
+		SELECT 1 FROM order_refunds r WHERE r.order_id = orders.order_id AND %s
+	))`, f.scope(), f.during("completed_at"), f.during("r.refunded_at"))
+}
+
+// SalesSummary is how the restaurant did over the period
+type SalesSummary struct {
+	AverageOrderInPence int `json:"averageOrderInPence"`
+	CancelledOrders     int `json:"cancelledOrders"`
+	CompletedOrders     int `json:"completedOrders"`
+	CourierTipsInPence  int `json:"courierTipsInPence"`
+	DiscountInPence     int `json:"discountInPence"`
+	KitchenTipsInPence  int `json:"kitchenTipsInPence"`
+	Orders              int `json:"orders"`
+	RefundedInPence     int `json:"refundedInPence"`
+	RefundedOrders      int `json:"refundedOrders"`
+	RejectedOrders      int `json:"rejectedOrders"`
+	RevenueInPence      int `json:"revenueInPence"` // Completed orders, less refunds
+	TipsInPence         int `json:"tipsInPence"`    // Not included in the revenue
+}
+
+type ProductSales struct {
+	Name           string `json:"name"`
+	ProductID      int    `json:"productId"`
+	Quantity       int    `json:"quantity"`
+	RevenueInPence int    `json:"revenueInPence"`
+}
+
+// How many products are in the daily report
+const topProducts = 10
+
+const orderColumns = `order_id, restaurant_id, customer_id, collection, status, subtotal_in_pence,
+	discount_in_pence, total_in_pence, refunded_in_pence, created_at, updated_at, completed_at`
+
+func scanOrder(row interface{ Scan(...any) error }) (*Order, error) {
+	var o Order
+	var completedAt sql.NullTime
+	if err := row.Scan(
+		&o.OrderID, &o.RestaurantID, &o.CustomerID, &o.Collection, &o.Status, &o.SubtotalInPence,
+		&o.DiscountInPence, &o.TotalInPence, &o.RefundedInPence, &o.CreatedAt, &o.UpdatedAt, &completedAt,
+	); err != nil {
+		return nil, err
+	}
+	if completedAt.Valid {
+		o.CompletedAt = &completedAt.Time
+	}
+	return &o, nil
+}
+
+// ListOrders returns the orders, newest first, without their items or history
+func (s *Store) ListOrders(ctx context.Context, filter OrderFilter) ([]Order, error) {
+	query := fmt.Sprintf(`SELECT %s FROM orders WHERE %s ORDER BY created_at DESC`, orderColumns, filter.where())
+	if filter.Limit > 0 {
+		query += " LIMIT @limit"
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, filter.args()...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := make([]Order, 0)
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error reading order: %w", err)
+		}
+		orders = append(orders, *o)
+	}
+
+	return orders, rows.Err()
+}
+
+// GetOrder returns the order with its items and history, or nil if it's not been projected
+func (s *Store) GetOrder(ctx context.Context, orderID string) (*Order, error) {
+	o, err := scanOrder(s.db.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT %s FROM orders WHERE order_id = ?`, orderColumns), orderID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting order: %w", err)
+	}
+
+	items, err := s.db.QueryContext(ctx, `
+		SELECT line, name, owner, product_id, quantity, total_in_pence
+		FROM order_items WHERE order_id = ? ORDER BY line`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting order items: %w", err)
+	}
+	defer items.Close()
+
+	o.Items = make([]OrderItem, 0)
+	for items.Next() {
+		var i OrderItem
+		if err := items.Scan(&i.Line, &i.Name, &i.Owner, &i.ProductID, &i.Quantity, &i.TotalInPence); err != nil {
+			return nil, fmt.Errorf("error reading order item: %w", err)
+		}
+		o.Items = append(o.Items, i)
+	}
+	if err := items.Err(); err != nil {
+		return nil, fmt.Errorf("error reading order items: %w", err)
+	}
+
+	history, err := s.db.QueryContext(ctx, `
+		SELECT event_id, status, time
+		FROM order_status_history WHERE order_id = ? ORDER BY event_id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting order history: %w", err)
+	}
+	defer history.Close()
+
+	o.History = make([]foodordering.StatusChange, 0)
+	for history.Next() {
+		var h foodordering.StatusChange
+		if err := history.Scan(&h.EventID, &h.Status, &h.Time); err != nil {
+			return nil, fmt.Errorf("error reading order history: %w", err)
+		}
+		o.History = append(o.History, h)
+	}
+
+	return o, history.Err()
+}
+
+// Summary reports on the orders that finished in the period and the refunds made in it
+func (s *Store) Summary(ctx context.Context, filter OrderFilter) (*SalesSummary, error) {
+	finished := filter.during("completed_at")
+
+	var summary SalesSummary
+	var completedInPence int
+	if err := s.db.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(status = 'COMPLETED' AND %[2]s), 0),
+			COALESCE(SUM(status = 'CANCELLED' AND %[2]s), 0),
+			COALESCE(SUM(status = 'REJECTED' AND %[2]s), 0),
+			COALESCE(SUM(CASE WHEN status = 'COMPLETED' AND %[2]s THEN total_in_pence END), 0),
+			COALESCE(SUM(CASE WHEN status = 'COMPLETED' AND %[2]s THEN discount_in_pence END), 0)
+		FROM orders WHERE %[1]s`, filter.where(), finished), filter.args()...,
+	).Scan(
+		&summary.Orders,
+		&summary.CompletedOrders,
+		&summary.CancelledOrders,
+		&summary.RejectedOrders,
+		&completedInPence,
+		&summary.DiscountInPence,
+	); err != nil {
+		return nil, fmt.Errorf("error summarising sales: %w", err)
+	}
+
+	// Refunds come off the revenue on the day they're made, not the day of the order
+	var completedRefundsInPence int
+	if err := s.db.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT
+			COALESCE(SUM(r.amount_in_pence), 0),
+			COUNT(DISTINCT r.order_id),
+			COALESCE(SUM(CASE WHEN o.status = 'COMPLETED' THEN r.amount_in_pence END), 0)
+		FROM order_refunds r
+		JOIN orders o ON o.order_id = r.order_id
+		WHERE %s AND r.order_id IN (SELECT order_id FROM orders WHERE %s)`, filter.during("r.refunded_at"), filter.scope()), filter.args()...,
+	).Scan(
+		&summary.RefundedInPence,
+		&summary.RefundedOrders,
+		&completedRefundsInPence,
+	); err != nil {
+		return nil, fmt.Errorf("error summarising refunds: %w", err)
+	}
+	summary.RevenueInPence = completedInPence - completedRefundsInPence
+
+	// Tips aren't food revenue, so they're totalled separately
+	if err := s.db.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT
+			COALESCE(SUM(amount_in_pence), 0),
+			COALESCE(SUM(kitchen_in_pence), 0),
+			COALESCE(SUM(courier_in_pence), 0)
+		FROM order_tips
+		WHERE status = 'PAID' AND %s AND order_id IN (SELECT order_id FROM orders WHERE %s)`, filter.during("tipped_at"), filter.scope()), filter.args()...,
+	).Scan(
+		&summary.TipsInPence,
+		&summary.KitchenTipsInPence,
+		&summary.CourierTipsInPence,
+	); err != nil {
+		return nil, fmt.Errorf("error summarising tips: %w", err)
+	}
+
+	if summary.CompletedOrders > 0 {
+		summary.AverageOrderInPence = completedInPence / summary.CompletedOrders
+	}
+
+	return &summary, nil
+}
+
+// ProductSales is what was sold on completed orders, best sellers first
+func (s *Store) ProductSales(ctx context.Context, filter OrderFilter) ([]ProductSales, error) {
+	filter.Status = foodordering.OrderStatusCompleted
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT i.product_id, i.name, SUM(i.quantity), SUM(i.total_in_pence)
+		FROM order_items i
+		WHERE i.order_id IN (SELECT order_id FROM orders WHERE %s AND %s)
+		GROUP BY i.product_id, i.name
+		ORDER BY SUM(i.quantity) DESC, i.product_id`, filter.scope(), filter.during("completed_at")), filter.args()...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting product sales: %w", err)
+	}
+	defer rows.Close()
+
+	sales := make([]ProductSales, 0)
+	for rows.Next() {
+		var p ProductSales
+		if err := rows.Scan(&p.ProductID, &p.Name, &p.Quantity, &p.RevenueInPence); err != nil {
+			return nil, fmt.Errorf("error reading product sales: %w", err)
+		}
+		sales = append(sales, p)
+	}
+
+	return sales, rows.Err()
+}
+
+// StatusDurations is the average time orders that finished in the period spent in
+// each status before moving on
+func (s *Store) StatusDurations(ctx context.Context, filter OrderFilter) ([]foodordering.StatusDuration, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT status, COUNT(*), ROUND(AVG(seconds), 1)
+		FROM (
+			SELECT
+				status,
+				event_id,
+				(julianday(LEAD(time) OVER (PARTITION BY order_id ORDER BY event_id)) - julianday(time)) * 86400 AS seconds
+			FROM order_status_history
+			WHERE order_id IN (SELECT order_id FROM orders WHERE %s AND %s)
+		)
+		WHERE seconds IS NOT NULL
+		GROUP BY status
+		ORDER BY MIN(event_id), status`, filter.scope(), filter.during("completed_at")), filter.args()...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting status durations: %w", err)
+	}
+	defer rows.Close()
+
+	durations := make([]foodordering.StatusDuration, 0)
+	for rows.Next() {
+		var d foodordering.StatusDuration
+		if err := rows.Scan(&d.Status, &d.Orders, &d.AverageSeconds); err != nil {
+			return nil, fmt.Errorf("error reading status durations: %w", err)
+		}
+		durations = append(durations, d)
+	}
+
+	return durations, rows.Err()
+}
+
+// DailySales builds the restaurant's report for the day
+func (s *Store) DailySales(ctx context.Context, req foodordering.SalesReportRequest) (*foodordering.DailySalesReport, error) {
+	filter := OrderFilter{
+		From:         req.From,
+		RestaurantID: req.RestaurantID,
+		To:           req.To,
+	}
+
+	summary, err := s.Summary(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := s.ProductSales(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	durations, err := s.StatusDurations(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &foodordering.DailySalesReport{
+		AverageOrderInPence: summary.AverageOrderInPence,
+		CancelledOrders:     summary.CancelledOrders,
+		CompletedOrders:     summary.CompletedOrders,
+		CourierTipsInPence:  summary.CourierTipsInPence,
+		Date:                req.Date,
+		KitchenTipsInPence:  summary.KitchenTipsInPence,
+		RefundedInPence:     summary.RefundedInPence,
+		RefundedOrders:      summary.RefundedOrders,
+		RejectedOrders:      summary.RejectedOrders,
+		RestaurantID:        req.RestaurantID,
+		RevenueInPence:      summary.RevenueInPence,
+		StatusDurations:     durations,
+		TipsInPence:         summary.TipsInPence,
+		TopProducts:         make([]foodordering.ReportProduct, 0),
+	}
+
+	for i, p := range products {
+		if i == topProducts {
+			break
+		}
+		report.TopProducts = append(report.TopProducts, foodordering.ReportProduct(p))
+	}
+
+	return report, nil
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package projection
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+)
+
+var _ foodordering.RiskHistory = &Store{}
+
+// Only the hashes are kept - the velocity rules just need to match them
+func hashRiskValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// Record adds the order to the risk history shared by every worker, returning
+// the signals from the orders before it
+func (s *Store) Record(ctx context.Context, req foodordering.RiskCheckRequest) (foodordering.RiskSignals, error) {
+	var signals foodordering.RiskSignals
+
+	email := foodordering.HashEmail(req.Email)
+	ipAddress := hashRiskValue(req.IPAddress)
+	postcode := hashRiskValue(strings.ToUpper(strings.ReplaceAll(req.Postcode, " ", "")))
+	orderedAt := req.Time.UTC()
+	since := orderedAt.Add(-req.Rules.VelocityWindow)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return signals, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// Retries keep the time of the first attempt
+	if _, err := tx.ExecContext(ctx, `INSERT INTO risk_orders (
+		order_id, email_hash, ip_address_hash, postcode_hash, ordered_at
+	) VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+		req.OrderID, email, ipAddress, postcode, orderedAt,
+	); err != nil {
+		return signals, fmt.Errorf("error recording risk order: %w", err)
+	}
+
+	// Retries mustn't count the order against itself
+	if err := tx.QueryRowContext(ctx, `SELECT
+		COALESCE(SUM(email_hash = ?), 0),
+		COALESCE(SUM(email_hash = ? AND ordered_at >= ?), 0),
+		COALESCE(SUM(ip_address_hash != '' AND ip_address_hash = ? AND ordered_at >= ?), 0),
+		COALESCE(SUM(postcode_hash != '' AND postcode_hash = ? AND ordered_at >= ?), 0)
+	FROM risk_orders
+	WHERE order_id != ? AND ordered_at < ?`,
+		email,
+		email, since,
+		ipAddress, since,
+		postcode, since,
+		req.OrderID, orderedAt,
+	).Scan(
+		&signals.PreviousOrders,
+		&signals.EmailOrders,
+		&signals.IPAddressOrders,
+		&signals.PostcodeOrders,
+	); err != nil {
+		return signals, fmt.Errorf("error getting risk signals: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return signals, fmt.Errorf("error committing risk order: %w", err)
+	}
+
+	return signals, nil
+}
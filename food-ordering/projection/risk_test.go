@@ -0,0 +1,70 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package projection
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRiskHistoryIsShared(t *testing.T) {
+	ctx := context.Background()
+	s, path := openTestStore(t)
+	start := time.Date(2025, time.June, 6, 12, 0, 0, 0, time.UTC)
+	rules := foodordering.RiskRules{VelocityWindow: time.Hour}
+
+	for i := range 3 {
+		_, err := s.Record(ctx, foodordering.RiskCheckRequest{
+			Email:     "Test@test.com",
+			IPAddress: "10.0.0.1",
+			OrderID:   fmt.Sprintf("order-%d", i),
+			Postcode:  "dn31 1aa",
+			Rules:     rules,
+			Time:      start.Add(time.Duration(i) * time.Minute),
+		})
+		assert.NoError(t, err)
+	}
+
+	// Another worker sees the same history
+	other, err := Open(ctx, path)
+	assert.NoError(t, err)
+	defer other.Close()
+
+	req := foodordering.RiskCheckRequest{
+		Email:     "test@test.com",
+		IPAddress: "10.0.0.2",
+		OrderID:   "order-3",
+		Postcode:  "DN31 1AA",
+		Rules:     rules,
+		Time:      start.Add(61 * time.Minute),
+	}
+
+	signals, err := other.Record(ctx, req)
+	assert.NoError(t, err)
+	// The first order is outside the window
+	assert.Equal(t, foodordering.RiskSignals{EmailOrders: 2, PostcodeOrders: 2, PreviousOrders: 3}, signals)
+
+	// Retries don't count the order twice
+	retried, err := s.Record(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, signals, retried)
+}
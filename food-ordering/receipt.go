@@ -0,0 +1,78 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import "fmt"
+
+type ReceiptLine struct {
+	Name         string `json:"name"`
+	Quantity     int    `json:"quantity"`
+	TotalInPence int    `json:"totalInPence"`
+}
+
+// Receipt is what the customer paid, with the tips shown apart from the food
+type Receipt struct {
+	CourierTipsInPence int           `json:"courierTipsInPence"`
+	Discounts          []Discount    `json:"discounts"`
+	FoodInPence        int           `json:"foodInPence"` // After discounts
+	KitchenTipsInPence int           `json:"kitchenTipsInPence"`
+	Lines              []ReceiptLine `json:"lines"`
+	OrderID            string        `json:"orderId"`
+	Payments           []Payment     `json:"payments"` // Tenders used for the food
+	RefundedInPence    int           `json:"refundedInPence"`
+	Restaurant         string        `json:"restaurant"`
+	SubtotalInPence    int           `json:"subtotalInPence"`
+	TipsInPence        int           `json:"tipsInPence"`
+	TotalInPence       int           `json:"totalInPence"` // Food and tips, less refunds
+}
+
+func NewReceipt(orderID string, state OrderState, restaurant Restaurant) Receipt {
+	tips, kitchen, courier := state.TipsInPence()
+
+	r := Receipt{
+		CourierTipsInPence: courier,
+		Discounts:          state.Discounts,
+		FoodInPence:        state.Total(),
+		KitchenTipsInPence: kitchen,
+		Lines:              make([]ReceiptLine, 0, len(state.Products)),
+		OrderID:            orderID,
+		Payments:           state.Payments,
+		Restaurant:         restaurant.Name,
+		SubtotalInPence:    state.Subtotal(),
+		TipsInPence:        tips,
+	}
+
+	for _, p := range state.Products {
+		name := fmt.Sprintf("Product %d", p.ProductID)
+		if product, err := GetProduct(p.ProductID); err == nil {
+			name = product.Name
+		}
+
+		r.Lines = append(r.Lines, ReceiptLine{
+			Name:         name,
+			Quantity:     p.Quantity,
+			TotalInPence: p.TotalInPence(),
+		})
+	}
+
+	for _, p := range state.Payments {
+		r.RefundedInPence += p.RefundedInPence
+	}
+	r.TotalInPence = r.FoodInPence - r.RefundedInPence + r.TipsInPence
+
+	return r
+}
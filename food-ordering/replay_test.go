@@ -0,0 +1,159 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mrsimonemms/temporal-demos/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Record new histories with "go run ./history <workflowId> <name>"
+const historiesDir = "testdata"
+
+func newWorkflowReplayer(dataConverter converter.DataConverter) worker.WorkflowReplayer {
+	replayer, err := worker.NewWorkflowReplayerWithOptions(worker.WorkflowReplayerOptions{
+		DataConverter: dataConverter,
+	})
+	if err != nil {
+		panic(err)
+	}
+	replayer.RegisterWorkflow(OrderWorkflow)
+	replayer.RegisterWorkflow(CustomerWorkflow)
+	replayer.RegisterWorkflow(DailySalesReportWorkflow)
+	return replayer
+}
+
+// firstDivergence replays the history a workflow task at a time, so the error
+// is for the first task whose commands no longer match
+func firstDivergence(newReplayer func() worker.WorkflowReplayer, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	history, err := client.HistoryFromJSON(bytes.NewReader(data), client.HistoryJSONOptions{})
+	if err != nil {
+		return err
+	}
+
+	events := history.GetEvents()
+	completed := false
+	for i, event := range events {
+		if event.GetEventType() == enums.EVENT_TYPE_WORKFLOW_TASK_COMPLETED {
+			completed = true
+		}
+		// Each task's commands end just before the next task is scheduled
+		if !completed || (i+1 < len(events) && events[i+1].GetEventType() != enums.EVENT_TYPE_WORKFLOW_TASK_SCHEDULED) {
+			continue
+		}
+
+		partial := &historypb.History{Events: events[:i+1]}
+		if err := newReplayer().ReplayWorkflowHistory(replayLogger, partial); err != nil {
+			return fmt.Errorf("history diverges by event %d: %w", event.GetEventId(), err)
+		}
+	}
+	return nil
+}
+
+// The replay errors say which event didn't match, so the workflow's logs are just noise
+var replayLogger = log.NewStructuredLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+// Recorded histories must still replay against the current workflow code, or
+// running orders will break when the worker's deployed
+func TestReplayHistories(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(historiesDir, "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files, "no histories in %s", historiesDir)
+
+	// Histories exported with encryption on need the keys to replay
+	dataConverter, err := codec.DataConverterFromEnv()
+	require.NoError(t, err)
+	newOrderReplayer := func() worker.WorkflowReplayer {
+		return newWorkflowReplayer(dataConverter)
+	}
+
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".json"), func(t *testing.T) {
+			err := newOrderReplayer().ReplayWorkflowHistoryFromJSONFile(replayLogger, file)
+			if err != nil {
+				// Narrow it down to the event that doesn't match
+				if divergence := firstDivergence(newOrderReplayer, file); divergence != nil {
+					err = divergence
+				}
+			}
+			assert.NoError(t, err, "%s no longer replays - gate the change with workflow.GetVersion", file)
+		})
+	}
+}
+
+func TestReplayDetectsNonDeterminism(t *testing.T) {
+	// Texts the customer before checking the risk
+	changed := func(ctx workflow.Context, state OrderState) error {
+		var a *activities
+		var restaurantConfig Restaurant
+
+		state.SetStatus(OrderStatusDefault, workflow.Now(ctx))
+		if err := workflow.ExecuteLocalActivity(
+			workflow.WithLocalActivityOptions(ctx, workflow.LocalActivityOptions{
+				StartToCloseTimeout: time.Second * 10,
+			}),
+			a.GetRestaurant,
+		).Get(ctx, &restaurantConfig); err != nil {
+			return err
+		}
+		if err := workflow.UpsertTypedSearchAttributes(ctx, state.SearchAttributes(restaurantConfig.ID)...); err != nil {
+			return err
+		}
+
+		ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			StartToCloseTimeout: time.Minute,
+		})
+		if err := workflow.ExecuteActivity(ctx, a.SendTextMessage, state).Get(ctx, nil); err != nil {
+			return err
+		}
+		return workflow.ExecuteActivity(ctx, a.CheckRisk, RiskCheckRequest{}).Get(ctx, nil)
+	}
+
+	newChangedReplayer := func() worker.WorkflowReplayer {
+		replayer := worker.NewWorkflowReplayer()
+		replayer.RegisterWorkflowWithOptions(changed, workflow.RegisterOptions{Name: "OrderWorkflow"})
+		return replayer
+	}
+
+	err := firstDivergence(newChangedReplayer, filepath.Join(historiesDir, "order-paid.json"))
+	require.Error(t, err)
+	// The error names the event that didn't match
+	assert.Contains(t, err.Error(), "nondeterministic workflow")
+	assert.Contains(t, err.Error(), "history event is ActivityTaskScheduled: (ActivityId:7, ActivityType:(Name:CheckRisk)")
+}
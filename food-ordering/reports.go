@@ -0,0 +1,253 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+type DailySalesReportRequest struct {
+	Date  string `json:"date"`  // Optional - YYYY-MM-DD, defaults to yesterday
+	Email string `json:"email"` // Optional - overrides the restaurant's report email
+}
+
+// SalesReportRequest is the period to report on, in the restaurant's timezone
+type SalesReportRequest struct {
+	Date         string    `json:"date"`
+	From         time.Time `json:"from"`
+	RestaurantID string    `json:"restaurantId"`
+	To           time.Time `json:"to"`
+}
+
+type ReportProduct struct {
+	Name           string `json:"name"`
+	ProductID      int    `json:"productId"`
+	Quantity       int    `json:"quantity"`
+	RevenueInPence int    `json:"revenueInPence"`
+}
+
+// StatusDuration is how long orders spent in the status before moving on
+type StatusDuration struct {
+	AverageSeconds float64     `json:"averageSeconds"`
+	Orders         int         `json:"orders"`
+	Status         OrderStatus `json:"status"`
+}
+
+type DailySalesReport struct {
+	AverageOrderInPence int              `json:"averageOrderInPence"`
+	CancelledOrders     int              `json:"cancelledOrders"`
+	CompletedOrders     int              `json:"completedOrders"`
+	CourierTipsInPence  int              `json:"courierTipsInPence"`
+	Date                string           `json:"date"`
+	KitchenTipsInPence  int              `json:"kitchenTipsInPence"`
+	RefundedInPence     int              `json:"refundedInPence"`
+	RefundedOrders      int              `json:"refundedOrders"`
+	RejectedOrders      int              `json:"rejectedOrders"`
+	RestaurantID        string           `json:"restaurantId"`
+	RevenueInPence      int              `json:"revenueInPence"` // Completed orders, less refunds
+	StatusDurations     []StatusDuration `json:"statusDurations"`
+	TipsInPence         int              `json:"tipsInPence"` // Not included in the revenue
+	TopProducts         []ReportProduct  `json:"topProducts"`
+}
+
+// SalesReporter builds the report from the orders - this is normally the read model
+type SalesReporter interface {
+	DailySales(ctx context.Context, req SalesReportRequest) (*DailySalesReport, error)
+}
+
+// WithSalesReporter enables the daily sales report
+func WithSalesReporter(reporter SalesReporter) ActivityOption {
+	return func(a *activities) {
+		a.reports = reporter
+	}
+}
+
+// WithReportDir is where the reports are written. They're kept for the
+// restaurant, so this should be somewhere that outlives the worker.
+func WithReportDir(dir string) ActivityOption {
+	return func(a *activities) {
+		a.reportDir = dir
+	}
+}
+
+// SalesReportMailer sends the report to the restaurant
+type SalesReportMailer interface {
+	Send(ctx context.Context, req EmailSalesReportRequest) error
+}
+
+// WithSalesReportMailer enables emailing the daily sales report
+func WithSalesReportMailer(mailer SalesReportMailer) ActivityOption {
+	return func(a *activities) {
+		a.mailer = mailer
+	}
+}
+
+type SalesReportFiles struct {
+	CSV  string `json:"csv"`
+	JSON string `json:"json"`
+}
+
+type EmailSalesReportRequest struct {
+	Report DailySalesReport `json:"report"`
+	To     string           `json:"to" pii:"true"`
+}
+
+func (r DailySalesReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// CSV renders the report as sections of metric/value rows, so it opens in a spreadsheet
+func (r DailySalesReport) CSV() ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+
+	pence := func(v int) string {
+		return fmt.Sprintf("%.2f", float64(v)/100)
+	}
+
+	rows := [][]string{
+		{"metric", "value"},
+		{"restaurant", r.RestaurantID},
+		{"date", r.Date},
+		{"completed orders", strconv.Itoa(r.CompletedOrders)},
+		{"rejected orders", strconv.Itoa(r.RejectedOrders)},
+		{"cancelled orders", strconv.Itoa(r.CancelledOrders)},
+		{"refunded orders", strconv.Itoa(r.RefundedOrders)},
+		{"revenue", pence(r.RevenueInPence)},
+		{"refunded", pence(r.RefundedInPence)},
+		{"average order value", pence(r.AverageOrderInPence)},
+		{"tips", pence(r.TipsInPence)},
+		{"kitchen tips", pence(r.KitchenTipsInPence)},
+		{"courier tips", pence(r.CourierTipsInPence)},
+		{},
+		{"product", "quantity", "revenue"},
+	}
+	for _, p := range r.TopProducts {
+		rows = append(rows, []string{p.Name, strconv.Itoa(p.Quantity), pence(p.RevenueInPence)})
+	}
+
+	rows = append(rows, []string{}, []string{"status", "orders", "average minutes"})
+	for _, d := range r.StatusDurations {
+		rows = append(rows, []string{string(d.Status), strconv.Itoa(d.Orders), fmt.Sprintf("%.1f", d.AverageSeconds/60)})
+	}
+
+	if err := w.WriteAll(rows); err != nil {
+		return nil, fmt.Errorf("error writing csv: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// Message is the email, with the report attached as CSV and JSON
+func (r EmailSalesReportRequest) Message(from string) ([]byte, error) {
+	csvData, err := r.Report.CSV()
+	if err != nil {
+		return nil, err
+	}
+	jsonData, err := r.Report.JSON()
+	if err != nil {
+		return nil, fmt.Errorf("error encoding report: %w", err)
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	text, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return nil, fmt.Errorf("error writing email: %w", err)
+	}
+	fmt.Fprintf(text, "Sales for %s on %s are attached.\r\n", r.Report.RestaurantID, r.Report.Date)
+
+	name := fmt.Sprintf("sales-%s-%s", r.Report.RestaurantID, r.Report.Date)
+	for _, a := range []struct {
+		contentType string
+		data        []byte
+		ext         string
+	}{
+		{contentType: "text/csv", data: csvData, ext: "csv"},
+		{contentType: "application/json", data: jsonData, ext: "json"},
+	} {
+		part, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", name+"."+a.ext)},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Type":              {a.contentType},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error writing email: %w", err)
+		}
+
+		// Lines can't be longer than 76 characters
+		encoded := base64.StdEncoding.EncodeToString(a.data)
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", encoded)
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("error writing email: %w", err)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", r.To)
+	fmt.Fprintf(&msg, "Subject: Daily sales for %s\r\n", r.Report.Date)
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", w.Boundary())
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+// SMTPMailer sends the report through a mail server, such as Mailpit when
+// running locally
+type SMTPMailer struct {
+	Address string // host:port
+	Auth    smtp.Auth
+	From    string
+}
+
+func (m *SMTPMailer) Send(_ context.Context, req EmailSalesReportRequest) error {
+	msg, err := req.Message(m.From)
+	if err != nil {
+		return err
+	}
+
+	if err := smtp.SendMail(m.Address, m.Auth, m.From, []string{req.To}, msg); err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+
+	return nil
+}
+
+func NewSMTPMailer(address, from string) *SMTPMailer {
+	return &SMTPMailer{
+		Address: address,
+		From:    from,
+	}
+}
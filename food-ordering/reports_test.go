@@ -0,0 +1,81 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailSalesReportMessage(t *testing.T) {
+	req := EmailSalesReportRequest{
+		Report: DailySalesReport{
+			CompletedOrders: 2,
+			Date:            "2026-01-02",
+			RestaurantID:    "default",
+			RevenueInPence:  1575,
+		},
+		To: "owner@example.com",
+	}
+
+	data, err := req.Message("reports@example.com")
+	require.NoError(t, err)
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	assert.Equal(t, "reports@example.com", msg.Header.Get("From"))
+	assert.Equal(t, "owner@example.com", msg.Header.Get("To"))
+	assert.Equal(t, "Daily sales for 2026-01-02", msg.Header.Get("Subject"))
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/mixed", mediaType)
+
+	attachments := map[string]string{}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if part.FileName() == "" {
+			continue
+		}
+
+		data, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+		require.NoError(t, err)
+		attachments[part.FileName()] = string(data)
+	}
+
+	csvData, err := req.Report.CSV()
+	require.NoError(t, err)
+	jsonData, err := req.Report.JSON()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"sales-default-2026-01-02.csv":  string(csvData),
+		"sales-default-2026-01-02.json": string(jsonData),
+	}, attachments)
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"fmt"
+	"time"
+
+	// Embed the timezone database so opening hours work in minimal containers
+	_ "time/tzdata"
+)
+
+type OpeningHours struct {
+	Day   time.Weekday `json:"day"`
+	Open  string       `json:"open"`  // 24 hour clock, eg 11:30
+	Close string       `json:"close"` // 24 hour clock, eg 21:00
+}
+
+type Restaurant struct {
+	ID                      string         `json:"id"`
+	Name                    string         `json:"name"`
+	Timezone                string         `json:"timezone"`
+	OpeningHours            []OpeningHours `json:"openingHours"`
+	ReleaseLeadTime         time.Duration  `json:"releaseLeadTime"`         // How long before a scheduled order is due that it's sent to the kitchen
+	GroupPaymentTimeout     time.Duration  `json:"groupPaymentTimeout"`     // How long group participants have to pay their share
+	PaymentChallengeTimeout time.Duration  `json:"paymentChallengeTimeout"` // How long customers have to authorise a card payment with their bank
+	FeedbackWindow          time.Duration  `json:"feedbackWindow"`          // How long after completion the customer can rate or complain
+	ComplaintAutoRefund     int            `json:"complaintAutoRefund"`     // Complaints refunding up to this many pence don't need the restaurant's approval
+	ReportEmail             string         `json:"reportEmail"`             // Optional - where the daily sales report is sent
+	Risk                    RiskRules      `json:"risk"`                    // Fraud checks run before payment is taken
+	Tips                    TipRules       `json:"tips"`
+}
+
+// IsOpen checks if the restaurant is open at the given time
+func (r Restaurant) IsOpen(t time.Time) (bool, error) {
+	loc, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		return false, fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	t = t.In(loc)
+	year, month, day := t.Date()
+
+	for _, h := range r.OpeningHours {
+		if h.Day != t.Weekday() {
+			continue
+		}
+
+		open, err := time.ParseInLocation("15:04", h.Open, loc)
+		if err != nil {
+			return false, fmt.Errorf("invalid opening time: %w", err)
+		}
+		closing, err := time.ParseInLocation("15:04", h.Close, loc)
+		if err != nil {
+			return false, fmt.Errorf("invalid closing time: %w", err)
+		}
+
+		open = time.Date(year, month, day, open.Hour(), open.Minute(), 0, 0, loc)
+		closing = time.Date(year, month, day, closing.Hour(), closing.Minute(), 0, 0, loc)
+
+		if !t.Before(open) && t.Before(closing) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ValidateFulfilmentTime checks that a requested fulfilment time can be met
+func (r Restaurant) ValidateFulfilmentTime(fulfilmentTime, now time.Time) error {
+	if !fulfilmentTime.After(now) {
+		return fmt.Errorf("fulfilment time must be in the future")
+	}
+
+	open, err := r.IsOpen(fulfilmentTime)
+	if err != nil {
+		return err
+	}
+	if !open {
+		return fmt.Errorf("restaurant is closed at %s", fulfilmentTime.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// ReleaseTime is when a scheduled order should be sent to the kitchen
+func (r Restaurant) ReleaseTime(fulfilmentTime time.Time) time.Time {
+	return fulfilmentTime.Add(-r.ReleaseLeadTime)
+}
+
+// ReportDay is the start and end of the date, in the restaurant's timezone. If
+// no date is given, it's the day before now.
+func (r Restaurant) ReportDay(date string, now time.Time) (from, to time.Time, err error) {
+	loc, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		return from, to, fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	if date == "" {
+		from = now.In(loc).AddDate(0, 0, -1)
+		from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	} else if from, err = time.ParseInLocation(time.DateOnly, date, loc); err != nil {
+		return from, to, fmt.Errorf("invalid report date: %w", err)
+	}
+
+	// Days aren't always 24 hours long
+	return from, from.AddDate(0, 0, 1), nil
+}
+
+// DefaultRestaurant is the only restaurant in this demo
+func DefaultRestaurant() Restaurant {
+	return restaurant
+}
+
+// Restaurant configuration - normally would be in a database
+var restaurant = Restaurant{
+	ID:       "codfather",
+	Name:     "The Codfather",
+	Timezone: "Europe/London",
+	OpeningHours: []OpeningHours{
+		{Day: time.Tuesday, Open: "11:30", Close: "14:00"},
+		{Day: time.Tuesday, Open: "16:30", Close: "21:00"},
+		{Day: time.Wednesday, Open: "11:30", Close: "14:00"},
+		{Day: time.Wednesday, Open: "16:30", Close: "21:00"},
+		{Day: time.Thursday, Open: "11:30", Close: "14:00"},
+		{Day: time.Thursday, Open: "16:30", Close: "21:00"},
+		{Day: time.Friday, Open: "11:30", Close: "21:30"},
+		{Day: time.Saturday, Open: "11:30", Close: "21:30"},
+	},
+	ReleaseLeadTime:         time.Minute * 30,
+	GroupPaymentTimeout:     time.Minute * 15,
+	PaymentChallengeTimeout: time.Minute * 10,
+	FeedbackWindow:          time.Hour * 48,
+	ComplaintAutoRefund:     500,
+	Risk: RiskRules{
+		VelocityWindow:         time.Hour,
+		ReviewVelocity:         3,
+		DenyVelocity:           10,
+		ReviewBasketInPence:    15000,
+		FirstOrderLimitInPence: 5000,
+		ReviewTimeout:          time.Minute * 15,
+	},
+	Tips: TipRules{
+		CourierPercent: 80,
+		MaxInPence:     5000,
+		Window:         time.Hour * 24,
+	},
+}
@@ -0,0 +1,255 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type RiskDecision string
+
+const (
+	RiskDecisionAllow  RiskDecision = "ALLOW"  // Take payment as normal
+	RiskDecisionReview RiskDecision = "REVIEW" // Hold the order until an operator has checked it
+	RiskDecisionDeny   RiskDecision = "DENY"   // Decline the order without taking payment
+)
+
+// Severity orders the decisions, so the strictest rule wins
+func (d RiskDecision) severity() int {
+	switch d {
+	case RiskDecisionReview:
+		return 1
+	case RiskDecisionDeny:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// RiskRules configures the fraud checks. Zero values switch the rule off.
+type RiskRules struct {
+	VelocityWindow         time.Duration `json:"velocityWindow"`         // How far back orders are counted for the velocity rules
+	ReviewVelocity         int           `json:"reviewVelocity"`         // Earlier orders from the same email, postcode or IP in the window that need reviewing
+	DenyVelocity           int           `json:"denyVelocity"`           // Earlier orders from the same email, postcode or IP in the window that are denied
+	ReviewBasketInPence    int           `json:"reviewBasketInPence"`    // Baskets over this need reviewing
+	FirstOrderLimitInPence int           `json:"firstOrderLimitInPence"` // First orders over this need reviewing
+	ReviewTimeout          time.Duration `json:"reviewTimeout"`          // How long an operator has to review an order before it's declined
+}
+
+type RiskCheckRequest struct {
+	Email        string    `json:"email" pii:"true"`
+	IPAddress    string    `json:"ipAddress" pii:"true"`
+	OrderID      string    `json:"orderId"`
+	Postcode     string    `json:"postcode" pii:"true"`
+	Rules        RiskRules `json:"rules"`
+	Time         time.Time `json:"time"`
+	TotalInPence int       `json:"totalInPence"`
+}
+
+// RiskSignals is what's known about who's placing the order
+type RiskSignals struct {
+	EmailOrders     int `json:"emailOrders"`     // Orders from the email in the velocity window
+	IPAddressOrders int `json:"ipAddressOrders"` // Orders from the IP address in the velocity window
+	PostcodeOrders  int `json:"postcodeOrders"`  // Orders to the postcode in the velocity window
+	PreviousOrders  int `json:"previousOrders"`  // Orders ever made from the email
+}
+
+type RiskReview struct {
+	Approved   bool      `json:"approved"`
+	Reason     string    `json:"reason"`
+	ReviewedAt time.Time `json:"reviewedAt"`
+	Reviewer   string    `json:"reviewer" pii:"true"`
+}
+
+type RiskAssessment struct {
+	Decision RiskDecision `json:"decision"`
+	Reasons  []string     `json:"reasons"`
+	Review   *RiskReview  `json:"review"` // Set once an operator has reviewed the order
+}
+
+// Allowed is whether payment can be taken
+func (r RiskAssessment) Allowed() bool {
+	if r.Decision == RiskDecisionReview {
+		return r.Review != nil && r.Review.Approved
+	}
+	return r.Decision == RiskDecisionAllow
+}
+
+func (r RiskReview) Validate() error {
+	if strings.TrimSpace(r.Reviewer) == "" {
+		return fmt.Errorf("reviewer is required")
+	}
+	return nil
+}
+
+// RiskRule checks one thing about the order, giving the reason for anything other than allow
+type RiskRule func(req RiskCheckRequest, signals RiskSignals) (RiskDecision, string)
+
+// Card testers place lots of small orders in quick succession
+func velocityRule(req RiskCheckRequest, signals RiskSignals) (RiskDecision, string) {
+	counts := []struct {
+		name   string
+		orders int
+	}{
+		{"email", signals.EmailOrders},
+		{"IP address", signals.IPAddressOrders},
+		{"postcode", signals.PostcodeOrders},
+	}
+
+	decision := RiskDecisionAllow
+	reasons := make([]string, 0)
+	for _, c := range counts {
+		switch {
+		case req.Rules.DenyVelocity > 0 && c.orders >= req.Rules.DenyVelocity:
+			decision = RiskDecisionDeny
+		case req.Rules.ReviewVelocity > 0 && c.orders >= req.Rules.ReviewVelocity:
+			if decision == RiskDecisionAllow {
+				decision = RiskDecisionReview
+			}
+		default:
+			continue
+		}
+		reasons = append(reasons, fmt.Sprintf("%d orders from the %s in %s", c.orders, c.name, req.Rules.VelocityWindow))
+	}
+
+	return decision, strings.Join(reasons, ", ")
+}
+
+func basketRule(req RiskCheckRequest, signals RiskSignals) (RiskDecision, string) {
+	if req.Rules.ReviewBasketInPence > 0 && req.TotalInPence > req.Rules.ReviewBasketInPence {
+		return RiskDecisionReview, fmt.Sprintf("basket of %dp is unusually large", req.TotalInPence)
+	}
+	return RiskDecisionAllow, ""
+}
+
+func firstOrderRule(req RiskCheckRequest, signals RiskSignals) (RiskDecision, string) {
+	if req.Rules.FirstOrderLimitInPence > 0 && signals.PreviousOrders == 0 && req.TotalInPence > req.Rules.FirstOrderLimitInPence {
+		return RiskDecisionReview, fmt.Sprintf("first order of %dp is over the %dp limit", req.TotalInPence, req.Rules.FirstOrderLimitInPence)
+	}
+	return RiskDecisionAllow, ""
+}
+
+var riskRules = []RiskRule{velocityRule, basketRule, firstOrderRule}
+
+// AssessRisk runs the order through every rule - the strictest decision wins
+func AssessRisk(req RiskCheckRequest, signals RiskSignals) RiskAssessment {
+	assessment := RiskAssessment{
+		Decision: RiskDecisionAllow,
+		Reasons:  make([]string, 0),
+	}
+
+	for _, rule := range riskRules {
+		decision, reason := rule(req, signals)
+		if decision == RiskDecisionAllow {
+			continue
+		}
+
+		assessment.Reasons = append(assessment.Reasons, reason)
+		if decision.severity() > assessment.Decision.severity() {
+			assessment.Decision = decision
+		}
+	}
+
+	return assessment
+}
+
+// RiskHistory remembers the orders that have been checked, so the velocity
+// rules can count them. Recording is idempotent on the order ID.
+type RiskHistory interface {
+	// Record adds the order and returns the signals from the orders before it
+	Record(ctx context.Context, req RiskCheckRequest) (RiskSignals, error)
+}
+
+// WithRiskHistory shares the risk history between workers - it's only kept in
+// memory by each worker if not set
+func WithRiskHistory(history RiskHistory) ActivityOption {
+	return func(a *activities) {
+		a.risk = history
+	}
+}
+
+type riskOrder struct {
+	email     string
+	ipAddress string
+	orderID   string
+	postcode  string
+	time      time.Time
+}
+
+// In-memory history - each worker only sees the orders it has checked itself
+type memoryRiskHistory struct {
+	mu     sync.Mutex
+	orders []riskOrder
+}
+
+func (h *memoryRiskHistory) Record(_ context.Context, req RiskCheckRequest) (RiskSignals, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	postcode := strings.ToUpper(strings.ReplaceAll(req.Postcode, " ", ""))
+	since := req.Time.Add(-req.Rules.VelocityWindow)
+
+	var signals RiskSignals
+	seen := false
+	for _, o := range h.orders {
+		if o.orderID == req.OrderID {
+			// Retries mustn't count the order against itself
+			seen = true
+			continue
+		}
+		if !o.time.Before(req.Time) {
+			continue
+		}
+
+		if o.email == email {
+			signals.PreviousOrders++
+		}
+		if o.time.Before(since) {
+			continue
+		}
+		if o.email == email {
+			signals.EmailOrders++
+		}
+		if req.IPAddress != "" && o.ipAddress == req.IPAddress {
+			signals.IPAddressOrders++
+		}
+		if postcode != "" && o.postcode == postcode {
+			signals.PostcodeOrders++
+		}
+	}
+
+	if !seen {
+		h.orders = append(h.orders, riskOrder{
+			email:     email,
+			ipAddress: req.IPAddress,
+			orderID:   req.OrderID,
+			postcode:  postcode,
+			time:      req.Time,
+		})
+	}
+
+	return signals, nil
+}
+
+func NewMemoryRiskHistory() RiskHistory {
+	return &memoryRiskHistory{}
+}
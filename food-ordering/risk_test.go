@@ -0,0 +1,99 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssessRisk(t *testing.T) {
+	rules := restaurant.Risk
+
+	tests := []struct {
+		name     string
+		total    int
+		signals  RiskSignals
+		decision RiskDecision
+	}{
+		{"regular customer", 2000, RiskSignals{PreviousOrders: 5}, RiskDecisionAllow},
+		{"small first order", 2000, RiskSignals{}, RiskDecisionAllow},
+		{"large first order", 6000, RiskSignals{}, RiskDecisionReview},
+		{"large basket", 20000, RiskSignals{PreviousOrders: 5}, RiskDecisionReview},
+		{"busy postcode", 2000, RiskSignals{PreviousOrders: 5, PostcodeOrders: 3}, RiskDecisionReview},
+		{"card testing", 100, RiskSignals{PreviousOrders: 12, EmailOrders: 10}, RiskDecisionDeny},
+		{"card testing with a large basket", 20000, RiskSignals{PreviousOrders: 12, IPAddressOrders: 12}, RiskDecisionDeny},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assessment := AssessRisk(RiskCheckRequest{Rules: rules, TotalInPence: test.total}, test.signals)
+
+			assert.Equal(t, test.decision, assessment.Decision)
+			assert.Equal(t, test.decision == RiskDecisionAllow, len(assessment.Reasons) == 0)
+		})
+	}
+}
+
+func TestRiskAssessmentAllowed(t *testing.T) {
+	assert.True(t, RiskAssessment{Decision: RiskDecisionAllow}.Allowed())
+	assert.False(t, RiskAssessment{Decision: RiskDecisionDeny}.Allowed())
+	assert.False(t, RiskAssessment{Decision: RiskDecisionReview}.Allowed())
+	assert.False(t, RiskAssessment{Decision: RiskDecisionReview, Review: &RiskReview{}}.Allowed())
+	assert.True(t, RiskAssessment{Decision: RiskDecisionReview, Review: &RiskReview{Approved: true}}.Allowed())
+}
+
+func TestMemoryRiskHistory(t *testing.T) {
+	h := NewMemoryRiskHistory()
+	start := time.Date(2025, time.June, 6, 12, 0, 0, 0, time.UTC)
+	rules := RiskRules{VelocityWindow: time.Hour}
+
+	for i := range 3 {
+		_, err := h.Record(context.Background(), RiskCheckRequest{
+			Email:     "Test@test.com",
+			IPAddress: "10.0.0.1",
+			OrderID:   fmt.Sprintf("order-%d", i),
+			Postcode:  "dn31 1aa",
+			Rules:     rules,
+			Time:      start.Add(time.Duration(i) * time.Minute),
+		})
+		assert.NoError(t, err)
+	}
+
+	req := RiskCheckRequest{
+		Email:     "test@test.com",
+		IPAddress: "10.0.0.2",
+		OrderID:   "order-3",
+		Postcode:  "DN31 1AA",
+		Rules:     rules,
+		Time:      start.Add(61 * time.Minute),
+	}
+
+	signals, err := h.Record(context.Background(), req)
+	assert.NoError(t, err)
+	// The first order is outside the window
+	assert.Equal(t, RiskSignals{EmailOrders: 2, PostcodeOrders: 2, PreviousOrders: 3}, signals)
+
+	// Retries don't count the order twice
+	retried, err := h.Record(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, signals, retried)
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/mrsimonemms/temporal-demos/codec"
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/mrsimonemms/temporal-demos/tracing"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+)
+
+const scheduleID = "daily_sales_report"
+
+// This script upserts the daily sales report schedule into Temporal. As with the
+// schedule-payments demo, deletion is out of scope - you MUST manually delete it
+// if you are using a long-running Temporal service (eg, Temporal Cloud).
+func main() {
+	// Order details are encrypted before they're stored in the workflow history
+	dataConverter, err := codec.DataConverterFromEnv()
+	if err != nil {
+		log.Fatalln("Unable to create data converter", err)
+	}
+
+	// Traces are only exported if OTEL_TRACES_EXPORTER is set
+	shutdownTracing, err := tracing.Setup(context.Background(), "food-ordering-schedule")
+	if err != nil {
+		log.Fatalln("Unable to configure tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	tracingInterceptor, err := tracing.NewInterceptor()
+	if err != nil {
+		log.Fatalln("Unable to create tracing interceptor", err)
+	}
+
+	// The client is a heavyweight object that should be created once per process.
+	c, err := client.Dial(client.Options{
+		DataConverter: dataConverter,
+		HostPort:      os.Getenv("TEMPORAL_ADDRESS"),
+		Interceptors:  []interceptor.ClientInterceptor{tracingInterceptor},
+		Namespace:     os.Getenv("TEMPORAL_NAMESPACE"),
+	})
+	if err != nil {
+		log.Fatalln("Unable to create client", err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+
+	log.Printf("Looking for existing schedule: %s", scheduleID)
+	schedules, err := c.ScheduleClient().List(ctx, client.ScheduleListOptions{})
+	if err != nil {
+		log.Fatalln("Unable to list schedule", err)
+	}
+
+	for schedules.HasNext() {
+		s, err := schedules.Next()
+		if err != nil {
+			log.Fatalln("Unable to get schedule", err)
+		}
+
+		// Find and destroy the schedule
+		if s.ID == scheduleID {
+			log.Printf("Schedule already exists - deleting it")
+			handler := c.ScheduleClient().GetHandle(ctx, scheduleID)
+
+			if err := handler.Delete(ctx); err != nil {
+				log.Fatalln("Error deleting schedule", err)
+			}
+		}
+	}
+
+	_, err = c.ScheduleClient().Create(ctx, client.ScheduleOptions{
+		ID: scheduleID,
+		Spec: client.ScheduleSpec{
+			// Run at 00:30 in the restaurant's timezone - the workflow reports on
+			// the previous day, so this leaves time for late orders to finish
+			Calendars: []client.ScheduleCalendarSpec{
+				{
+					Hour: []client.ScheduleRange{
+						{
+							Start: 0,
+						},
+					},
+					Minute: []client.ScheduleRange{
+						{
+							Start: 30,
+						},
+					},
+				},
+			},
+			TimeZoneName: foodordering.DefaultRestaurant().Timezone,
+		},
+		Action: &client.ScheduleWorkflowAction{
+			// Report on yesterday, emailed to the restaurant's report address
+			Args:      []any{foodordering.DailySalesReportRequest{}},
+			Workflow:  foodordering.DailySalesReportWorkflow,
+			TaskQueue: foodordering.OrderFoodTaskQueue,
+		},
+	})
+	if err != nil {
+		log.Fatalln("Error creating schedule", err)
+	}
+
+	log.Println("Schedule configured - goodbye")
+}
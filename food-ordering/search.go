@@ -0,0 +1,61 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// Search attributes set on each order so they can be listed without querying
+// every workflow. These must be registered on the namespace before use.
+var (
+	OrderStatusSearchAttribute   = temporal.NewSearchAttributeKeyKeyword("OrderStatus")
+	RestaurantIDSearchAttribute  = temporal.NewSearchAttributeKeyKeyword("RestaurantID")
+	CustomerEmailSearchAttribute = temporal.NewSearchAttributeKeyKeyword("CustomerEmail") // Hashed, so it's not visible in the UI
+	TotalSearchAttribute         = temporal.NewSearchAttributeKeyInt64("Total")           // In pence
+	CollectionSearchAttribute    = temporal.NewSearchAttributeKeyBool("Collection")
+)
+
+// SearchAttributeKeys is every search attribute the order uses, for registering them
+var SearchAttributeKeys = []temporal.SearchAttributeKey{
+	OrderStatusSearchAttribute,
+	RestaurantIDSearchAttribute,
+	CustomerEmailSearchAttribute,
+	TotalSearchAttribute,
+	CollectionSearchAttribute,
+}
+
+// HashEmail is how the customer's email is stored in the search attributes
+func HashEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
+}
+
+// SearchAttributes is the order as it's indexed for listing
+func (o *OrderState) SearchAttributes(restaurantID string) []temporal.SearchAttributeUpdate {
+	return []temporal.SearchAttributeUpdate{
+		OrderStatusSearchAttribute.ValueSet(string(o.Status)),
+		RestaurantIDSearchAttribute.ValueSet(restaurantID),
+		CustomerEmailSearchAttribute.ValueSet(HashEmail(o.Email)),
+		TotalSearchAttribute.ValueSet(int64(o.Total())),
+		CollectionSearchAttribute.ValueSet(o.Collection),
+	}
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Registers the order search attributes on the namespace. Run this once
+// against a new server before starting the worker.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/mrsimonemms/temporal-demos/codec"
+	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/mrsimonemms/temporal-demos/tracing"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/operatorservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+)
+
+func main() {
+	namespace := os.Getenv("TEMPORAL_NAMESPACE")
+	if namespace == "" {
+		namespace = client.DefaultNamespace
+	}
+
+	// Order details are encrypted before they're stored in the workflow history
+	dataConverter, err := codec.DataConverterFromEnv()
+	if err != nil {
+		log.Fatalln("Unable to create data converter", err)
+	}
+
+	// Traces are only exported if OTEL_TRACES_EXPORTER is set
+	shutdownTracing, err := tracing.Setup(context.Background(), "food-ordering-searchattributes")
+	if err != nil {
+		log.Fatalln("Unable to configure tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	tracingInterceptor, err := tracing.NewInterceptor()
+	if err != nil {
+		log.Fatalln("Unable to create tracing interceptor", err)
+	}
+
+	c, err := client.Dial(client.Options{
+		DataConverter: dataConverter,
+		HostPort:      os.Getenv("TEMPORAL_ADDRESS"),
+		Interceptors:  []interceptor.ClientInterceptor{tracingInterceptor},
+		Namespace:     namespace,
+	})
+	if err != nil {
+		log.Fatalln("Unable to create client", err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+
+	existing, err := c.OperatorService().ListSearchAttributes(ctx, &operatorservice.ListSearchAttributesRequest{
+		Namespace: namespace,
+	})
+	if err != nil {
+		log.Fatalln("Unable to list search attributes", err)
+	}
+
+	// Adding an attribute that already exists is an error, so only add the missing ones
+	missing := map[string]enums.IndexedValueType{}
+	for _, key := range foodordering.SearchAttributeKeys {
+		name := key.GetName()
+
+		if valueType, ok := existing.GetCustomAttributes()[name]; ok {
+			if valueType != key.GetValueType() {
+				log.Fatalln("Search attribute registered with the wrong type", name, valueType)
+			}
+			log.Println("Search attribute already registered", name)
+			continue
+		}
+		missing[name] = key.GetValueType()
+	}
+
+	if len(missing) == 0 {
+		return
+	}
+
+	if _, err := c.OperatorService().AddSearchAttributes(ctx, &operatorservice.AddSearchAttributesRequest{
+		Namespace:        namespace,
+		SearchAttributes: missing,
+	}); err != nil {
+		log.Fatalln("Unable to register search attributes", err)
+	}
+
+	for name, valueType := range missing {
+		log.Println("Registered search attribute", name, valueType)
+	}
+}
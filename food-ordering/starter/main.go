@@ -18,108 +18,94 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"os"
 	"time"
 
+	"github.com/mrsimonemms/temporal-demos/codec"
 	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/orderclient"
+	"github.com/mrsimonemms/temporal-demos/tracing"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
 )
 
 func main() {
+	// Order details are encrypted before they're stored in the workflow history
+	dataConverter, err := codec.DataConverterFromEnv()
+	if err != nil {
+		log.Fatalln("Unable to create data converter", err)
+	}
+
+	// Traces are only exported if OTEL_TRACES_EXPORTER is set
+	shutdownTracing, err := tracing.Setup(context.Background(), "food-ordering-starter")
+	if err != nil {
+		log.Fatalln("Unable to configure tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	tracingInterceptor, err := tracing.NewInterceptor()
+	if err != nil {
+		log.Fatalln("Unable to create tracing interceptor", err)
+	}
+
 	// The client is a heavyweight object that should be created once per process.
 	c, err := client.Dial(client.Options{
-		HostPort: os.Getenv("TEMPORAL_ADDRESS"),
+		DataConverter: dataConverter,
+		HostPort:      os.Getenv("TEMPORAL_ADDRESS"),
+		Interceptors:  []interceptor.ClientInterceptor{tracingInterceptor},
+		Namespace:     os.Getenv("TEMPORAL_NAMESPACE"),
 	})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
 	defer c.Close()
 
-	workflowID := "ORDER-" + fmt.Sprintf("%d", time.Now().Unix())
-
-	workflowOptions := client.StartWorkflowOptions{
-		ID:        workflowID,
-		TaskQueue: foodordering.OrderFoodTaskQueue,
-	}
+	orders := orderclient.New(c)
 
 	ctx := context.Background()
 
-	state := foodordering.NewOrderState()
-	we, err := c.ExecuteWorkflow(
-		ctx,
-		workflowOptions,
-		foodordering.OrderWorkflow,
-		state,
-	)
+	orderID, err := orders.Create(ctx, foodordering.NewOrderState())
 	if err != nil {
 		log.Fatalln("Unable to execute workflow", err)
 	}
 
-	log.Println("Started workflow", "WorkflowID", we.GetID(), "RunID", we.GetRunID())
+	log.Println("Started workflow", "WorkflowID", orderID)
 
 	time.Sleep(time.Second * 5)
 
-	printState(ctx, c, we)
-
-	handler, err := c.UpdateWorkflow(ctx, client.UpdateWorkflowOptions{
-		WorkflowID:   we.GetID(),
-		UpdateName:   foodordering.Updates.ADD_ITEM,
-		WaitForStage: client.WorkflowUpdateStageCompleted,
-		Args: []any{
-			foodordering.OrderProduct{
-				ProductID: 1,
-				Quantity:  2,
-			},
-		},
-	})
-	if err != nil {
-		log.Fatal("Erro issuing ADD_ITEM request")
-	}
-	if err := handler.Get(ctx, nil); err != nil {
+	printState(ctx, orders, orderID)
+
+	if err := orders.AddItem(ctx, orderID, foodordering.OrderProduct{
+		ProductID: 1,
+		Quantity:  2,
+	}); err != nil {
 		log.Fatalln("Failed to add to basket", err)
 	}
 
 	time.Sleep(time.Second * 5)
 
-	if err := c.SignalWorkflow(
-		ctx,
-		we.GetID(),
-		"",
-		foodordering.Signals.CHECKOUT,
-		nil,
-	); err != nil {
+	if err := orders.Checkout(ctx, orderID); err != nil {
 		log.Fatalln("Failed to checkout", err)
 	}
 
-	// Wait for end of workflow
-	log.Println("Waiting for end of workflow")
-	if err := we.Get(ctx, nil); err != nil {
+	// Watch until the order's completed, rejected or cancelled - the state has
+	// the customer's details, so only the status is logged
+	log.Println("Watching order", "WorkflowID", orderID)
+	if err := orders.Watch(ctx, orderID, func(state foodordering.OrderState) error {
+		log.Println("Order status", "WorkflowID", orderID, "status", state.Status)
+		return nil
+	}); err != nil {
 		log.Fatalln("Failed", err)
 	}
 
-	printState(ctx, c, we)
-
-	log.Println("Order submitted")
-}
-
-func getState(ctx context.Context, c client.Client, we client.WorkflowRun) (*foodordering.OrderState, error) {
-	resp, err := c.QueryWorkflow(ctx, we.GetID(), "", foodordering.Queries.GET_STATUS)
-	if err != nil {
-		log.Fatalln("Failed to query workflow", err)
-	}
-	var result *foodordering.OrderState
-	if err := resp.Get(&result); err != nil {
-		return nil, fmt.Errorf("unable to decode state query: %w", err)
-	}
-	return result, nil
+	log.Println("Order finished", "WorkflowID", orderID)
 }
 
-func printState(ctx context.Context, c client.Client, we client.WorkflowRun) {
-	if state, err := getState(ctx, c, we); err != nil {
+func printState(ctx context.Context, orders *orderclient.Client, orderID string) {
+	if state, err := orders.GetState(ctx, orderID); err != nil {
 		log.Fatalln("Failed to get state", err)
 	} else {
-		log.Printf("State: %+v\n", *state)
+		log.Println("Order status", "WorkflowID", orderID, "status", state.Status)
 	}
 }
@@ -0,0 +1,270 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Characters per line on an 80mm thermal printer using the standard font
+const TicketWidth = 42
+
+// ESC/POS commands
+var (
+	escposInit      = []byte{0x1b, 0x40}             // ESC @ - reset the printer
+	escposAlignLeft = []byte{0x1b, 0x61, 0x00}       // ESC a 0
+	escposAlignMid  = []byte{0x1b, 0x61, 0x01}       // ESC a 1
+	escposBoldOn    = []byte{0x1b, 0x45, 0x01}       // ESC E 1
+	escposBoldOff   = []byte{0x1b, 0x45, 0x00}       // ESC E 0
+	escposLarge     = []byte{0x1d, 0x21, 0x11}       // GS ! - double width and height
+	escposNormal    = []byte{0x1d, 0x21, 0x00}       // GS ! - normal size
+	escposFeedCut   = []byte{0x1d, 0x56, 0x42, 0x04} // GS V B - feed 4 lines and cut
+)
+
+type TicketItem struct {
+	Modifiers []string `json:"modifiers"`
+	Name      string   `json:"name"`
+	Notes     string   `json:"notes"`
+	Owner     string   `json:"owner"`
+	Quantity  int      `json:"quantity"`
+}
+
+// Ticket is what the kitchen needs to make the order
+type Ticket struct {
+	Collection      bool         `json:"collection"`
+	DeliveryAddress *Address     `json:"deliveryAddress" pii:"true"`
+	DueTime         *time.Time   `json:"dueTime"` // Not set if the order is for now
+	Items           []TicketItem `json:"items"`
+	Notes           string       `json:"notes"`
+	OrderID         string       `json:"orderId"`
+	PrintedAt       time.Time    `json:"printedAt"`
+	Restaurant      string       `json:"restaurant"`
+	Timezone        string       `json:"timezone"`
+}
+
+// A line on the ticket, before it's rendered
+type ticketLine struct {
+	bold   bool
+	centre bool
+	indent int
+	large  bool
+	text   string
+}
+
+func (t Ticket) formatTime(v time.Time) string {
+	if loc, err := time.LoadLocation(t.Timezone); err == nil {
+		v = v.In(loc)
+	}
+	return v.Format("Mon 02 Jan 15:04")
+}
+
+func (t Ticket) lines() []ticketLine {
+	rule := ticketLine{text: strings.Repeat("-", TicketWidth)}
+
+	lines := []ticketLine{
+		{text: t.Restaurant, centre: true, bold: true},
+		{text: t.OrderID, centre: true},
+		{text: t.formatTime(t.PrintedAt), centre: true},
+		rule,
+	}
+
+	method := "DELIVERY"
+	if t.Collection {
+		method = "COLLECTION"
+	}
+	lines = append(lines, ticketLine{text: method, centre: true, large: true})
+
+	due := "ASAP"
+	if t.DueTime != nil {
+		due = t.formatTime(*t.DueTime)
+	}
+	lines = append(lines, ticketLine{text: "DUE " + due, centre: true, bold: true}, rule)
+
+	for _, item := range t.Items {
+		lines = append(lines, ticketLine{text: fmt.Sprintf("%d x %s", item.Quantity, item.Name), bold: true})
+		for _, m := range item.Modifiers {
+			lines = append(lines, ticketLine{text: "+ " + m, indent: 3})
+		}
+		if item.Notes != "" {
+			lines = append(lines, ticketLine{text: "! " + item.Notes, indent: 3})
+		}
+		if item.Owner != "" {
+			lines = append(lines, ticketLine{text: "for " + item.Owner, indent: 3})
+		}
+	}
+
+	if t.Notes != "" {
+		lines = append(lines, rule, ticketLine{text: "NOTES", bold: true}, ticketLine{text: t.Notes})
+	}
+
+	if !t.Collection && t.DeliveryAddress != nil {
+		lines = append(lines, rule, ticketLine{text: "DELIVER TO", bold: true})
+		for _, l := range []string{
+			t.DeliveryAddress.AddressLine1,
+			t.DeliveryAddress.AddressLine2,
+			t.DeliveryAddress.AddressLine3,
+			t.DeliveryAddress.Town,
+			t.DeliveryAddress.County,
+			t.DeliveryAddress.PostCode,
+		} {
+			if l != "" {
+				lines = append(lines, ticketLine{text: l})
+			}
+		}
+	}
+
+	return lines
+}
+
+// Text renders the ticket as plain text, eg for logs and kitchen screens
+func (t Ticket) Text() string {
+	var b strings.Builder
+
+	for _, line := range t.lines() {
+		for _, l := range wrapText(line.text, TicketWidth-line.indent) {
+			if line.centre {
+				l = strings.Repeat(" ", (TicketWidth-utf8.RuneCountInString(l))/2) + l
+			}
+			b.WriteString(strings.Repeat(" ", line.indent) + l + "\n")
+		}
+	}
+
+	return b.String()
+}
+
+// ESCPOS renders the ticket for a thermal printer
+func (t Ticket) ESCPOS() []byte {
+	var b bytes.Buffer
+
+	b.Write(escposInit)
+	for _, line := range t.lines() {
+		width := TicketWidth
+		if line.large {
+			width /= 2
+			b.Write(escposLarge)
+		}
+		if line.centre {
+			b.Write(escposAlignMid)
+		}
+		if line.bold {
+			b.Write(escposBoldOn)
+		}
+
+		for _, l := range wrapText(line.text, width-line.indent) {
+			b.Write(encodeCP437(strings.Repeat(" ", line.indent) + l))
+			b.WriteByte('\n')
+		}
+
+		if line.bold {
+			b.Write(escposBoldOff)
+		}
+		if line.centre {
+			b.Write(escposAlignLeft)
+		}
+		if line.large {
+			b.Write(escposNormal)
+		}
+	}
+	b.Write(escposFeedCut)
+
+	return b.Bytes()
+}
+
+// Printers use code page 437 by default - anything else is replaced
+func encodeCP437(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r < 0x80:
+			out = append(out, byte(r))
+		case r == '£':
+			out = append(out, 0x9c)
+		case r == 'é':
+			out = append(out, 0x82)
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+// Splits the text into lines no longer than the width, breaking on spaces where possible
+func wrapText(text string, width int) []string {
+	lines := make([]string, 0)
+
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for len([]rune(word)) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				lines = append(lines, string([]rune(word)[:width]))
+				word = string([]rune(word)[width:])
+			}
+
+			switch {
+			case line == "":
+				line = word
+			case len([]rune(line))+1+len([]rune(word)) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+func NewTicket(orderID string, state OrderState, restaurant Restaurant, now time.Time) Ticket {
+	ticket := Ticket{
+		Collection:      state.Collection,
+		DeliveryAddress: state.DeliveryAddress,
+		DueTime:         state.FulfilmentTime,
+		Items:           make([]TicketItem, 0, len(state.Products)),
+		Notes:           state.Notes,
+		OrderID:         orderID,
+		PrintedAt:       now,
+		Restaurant:      restaurant.Name,
+		Timezone:        restaurant.Timezone,
+	}
+
+	for _, p := range state.Products {
+		name := fmt.Sprintf("Product %d", p.ProductID)
+		if product, err := GetProduct(p.ProductID); err == nil {
+			name = product.Name
+		}
+
+		ticket.Items = append(ticket.Items, TicketItem{
+			Modifiers: p.Modifiers,
+			Name:      name,
+			Notes:     p.Notes,
+			Owner:     p.Owner,
+			Quantity:  p.Quantity,
+		})
+	}
+
+	return ticket
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func testTicket(t *testing.T) Ticket {
+	t.Helper()
+
+	due := time.Date(2025, time.June, 6, 17, 30, 0, 0, time.UTC)
+
+	state := NewOrderState()
+	state.DeliveryAddress = &Address{AddressLine1: "1 Fish Street", Town: "Grimsby", PostCode: "DN31 1AA"}
+	state.FulfilmentTime = &due
+	state.Notes = "Ring the bell twice"
+	state.AddItem(OrderProduct{ProductID: 1, Quantity: 2, Modifiers: []string{"no salt", "extra vinegar"}})
+	state.AddItem(OrderProduct{ProductID: 2, Quantity: 1, Notes: "well done", Owner: "Alice"})
+
+	return NewTicket("ORDER-1", state, restaurant, due.Add(-time.Hour))
+}
+
+func TestTicketText(t *testing.T) {
+	text := testTicket(t).Text()
+
+	for _, expected := range []string{
+		"The Codfather",
+		"ORDER-1",
+		"DELIVERY",
+		"DUE Fri 06 Jun 18:30", // In the restaurant's timezone
+		"2 x Chips",
+		"   + no salt\n   + extra vinegar\n",
+		"   ! well done\n   for Alice\n",
+		"NOTES\nRing the bell twice\n",
+		"DELIVER TO\n1 Fish Street\nGrimsby\nDN31 1AA\n",
+	} {
+		assert.Contains(t, text, expected)
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		assert.LessOrEqual(t, len(line), TicketWidth)
+	}
+}
+
+func TestTicketCollectionASAP(t *testing.T) {
+	ticket := testTicket(t)
+	ticket.Collection = true
+	ticket.DueTime = nil
+
+	text := ticket.Text()
+	assert.Contains(t, text, "COLLECTION")
+	assert.Contains(t, text, "DUE ASAP")
+	assert.NotContains(t, text, "DELIVER TO")
+}
+
+func TestTicketESCPOS(t *testing.T) {
+	ticket := testTicket(t)
+	ticket.Notes = "Paid £5 extra"
+
+	out := ticket.ESCPOS()
+
+	assert.True(t, bytes.HasPrefix(out, escposInit))
+	assert.True(t, bytes.HasSuffix(out, escposFeedCut))
+	assert.Contains(t, string(out), string(escposLarge)+string(escposAlignMid)+"DELIVERY\n")
+	assert.Contains(t, string(out), "Paid \x9c5 extra\n")
+}
+
+func TestWrapText(t *testing.T) {
+	assert.Equal(t, []string{"the quick", "brown fox"}, wrapText("the quick brown fox", 10))
+	assert.Equal(t, []string{"abcde", "fghij", "k"}, wrapText("abcdefghijk", 5))
+	assert.Equal(t, []string{""}, wrapText("", 5))
+}
+
+func TestPrintTicket(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestActivityEnvironment()
+
+	a := &activities{printerAddress: ln.Addr().String()}
+	env.RegisterActivity(a)
+
+	ticket := testTicket(t)
+	_, err = env.ExecuteActivity(a.PrintTicket, ticket)
+	assert.NoError(t, err)
+
+	select {
+	case data := <-received:
+		assert.Equal(t, ticket.ESCPOS(), data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("printer didn't receive the ticket")
+	}
+}
+
+func TestPrintTicketPrinterOffline(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := ln.Addr().String()
+	assert.NoError(t, ln.Close())
+
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestActivityEnvironment()
+
+	a := &activities{printerAddress: addr}
+	env.RegisterActivity(a)
+
+	_, err = env.ExecuteActivity(a.PrintTicket, testTicket(t))
+	assert.ErrorContains(t, err, "error connecting to printer")
+}
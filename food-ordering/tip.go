@@ -0,0 +1,119 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"fmt"
+	"time"
+)
+
+type TipStatus string
+
+const (
+	TipStatusPending  TipStatus = "PENDING"  // Given at checkout, charged once the order's paid for
+	TipStatusPaid     TipStatus = "PAID"     // Charged to the customer's card
+	TipStatusFailed   TipStatus = "FAILED"   // Card declined - the order carries on without it
+	TipStatusRefunded TipStatus = "REFUNDED" // Order was cancelled or rejected
+)
+
+// TipRules decide how tips are shared between the kitchen and the courier
+type TipRules struct {
+	CourierPercent int           `json:"courierPercent"` // Share of delivery tips for the courier - collection tips all go to the kitchen
+	MaxInPence     int           `json:"maxInPence"`
+	Window         time.Duration `json:"window"` // How long after completion the customer can tip
+}
+
+type TipRequest struct {
+	AmountInPence int `json:"amountInPence"`
+}
+
+// Tip is charged separately from the food, so it's never discounted or refunded by a complaint
+type Tip struct {
+	AmountInPence  int       `json:"amountInPence"`
+	CourierInPence int       `json:"courierInPence"`
+	KitchenInPence int       `json:"kitchenInPence"`
+	Payment        *Payment  `json:"payment"` // Set once it's been charged
+	Status         TipStatus `json:"status"`
+	TipID          string    `json:"tipId"`
+	TippedAt       time.Time `json:"tippedAt"`
+}
+
+// Split shares the tip between the kitchen and courier, with the kitchen getting any odd pennies
+func (r TipRules) Split(amountInPence int, collection bool) (kitchen, courier int) {
+	if collection {
+		return amountInPence, 0
+	}
+
+	courier = amountInPence * r.CourierPercent / 100
+	return amountInPence - courier, courier
+}
+
+func (r TipRequest) Validate(rules TipRules) error {
+	if r.AmountInPence <= 0 {
+		return fmt.Errorf("tip must be more than zero")
+	}
+	if rules.MaxInPence > 0 && r.AmountInPence > rules.MaxInPence {
+		return fmt.Errorf("tip cannot be more than %d pence", rules.MaxInPence)
+	}
+	return nil
+}
+
+// NewTip records the tip, split according to the rules
+func (o *OrderState) NewTip(req TipRequest, rules TipRules, now time.Time) Tip {
+	kitchen, courier := rules.Split(req.AmountInPence, o.Collection)
+
+	return Tip{
+		AmountInPence:  req.AmountInPence,
+		CourierInPence: courier,
+		KitchenInPence: kitchen,
+		Status:         TipStatusPending,
+		TipID:          fmt.Sprintf("TIP-%d", len(o.Tips)+1),
+		TippedAt:       now,
+	}
+}
+
+func (o *OrderState) GetTip(tipID string) *Tip {
+	for i := range o.Tips {
+		if o.Tips[i].TipID == tipID {
+			return &o.Tips[i]
+		}
+	}
+	return nil
+}
+
+// NewTipChange is published when the customer tips after the order's completed,
+// as there's no status change to carry it
+func NewTipChange(orderID, restaurantID string, state OrderState, tip Tip) OrderChange {
+	change := NewOrderChange(orderID, restaurantID, state)
+	change.ID = fmt.Sprintf("%s/%s", orderID, tip.TipID)
+	change.Time = tip.TippedAt
+	change.Type = OrderTippedEvent
+	return change
+}
+
+// TipsInPence is how much the customer's been charged in tips that haven't been refunded
+func (o *OrderState) TipsInPence() (total, kitchen, courier int) {
+	for _, t := range o.Tips {
+		if t.Status != TipStatusPaid {
+			continue
+		}
+		total += t.AmountInPence
+		kitchen += t.KitchenInPence
+		courier += t.CourierInPence
+	}
+	return total, kitchen, courier
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTipRulesSplit(t *testing.T) {
+	rules := TipRules{CourierPercent: 80}
+
+	kitchen, courier := rules.Split(333, false)
+	assert.Equal(t, 67, kitchen)
+	assert.Equal(t, 266, courier)
+
+	// Nobody delivers a collection
+	kitchen, courier = rules.Split(333, true)
+	assert.Equal(t, 333, kitchen)
+	assert.Zero(t, courier)
+}
+
+func TestTipRequestValidate(t *testing.T) {
+	rules := TipRules{MaxInPence: 5000}
+
+	assert.NoError(t, TipRequest{AmountInPence: 500}.Validate(rules))
+	assert.Error(t, TipRequest{AmountInPence: 0}.Validate(rules))
+	assert.Error(t, TipRequest{AmountInPence: 5001}.Validate(rules))
+	assert.NoError(t, TipRequest{AmountInPence: 5001}.Validate(TipRules{}))
+}
+
+func TestReceiptTipsNotDiscounted(t *testing.T) {
+	now := time.Date(2025, time.June, 6, 12, 0, 0, 0, time.UTC)
+
+	state := NewOrderState()
+	state.AddItem(OrderProduct{ProductID: 1, Quantity: 2})
+	state.Discounts = []Discount{{AmountInPence: 200, Description: "Loyalty points"}}
+	state.Payments = []Payment{{AmountInPence: 500, Method: PaymentMethodCard, RefundedInPence: 100}}
+
+	for _, amount := range []int{300, 150} {
+		tip := state.NewTip(TipRequest{AmountInPence: amount}, TipRules{CourierPercent: 80}, now)
+		tip.Status = TipStatusPaid
+		state.Tips = append(state.Tips, tip)
+	}
+	state.Tips[1].Status = TipStatusFailed
+
+	receipt := NewReceipt("order-1", state, restaurant)
+
+	assert.Equal(t, 700, receipt.SubtotalInPence)
+	assert.Equal(t, 500, receipt.FoodInPence)
+	assert.Equal(t, 300, receipt.TipsInPence)
+	assert.Equal(t, 60, receipt.KitchenTipsInPence)
+	assert.Equal(t, 240, receipt.CourierTipsInPence)
+	assert.Equal(t, 500-100+300, receipt.TotalInPence)
+	assert.Equal(t, []ReceiptLine{{Name: "Chips", Quantity: 2, TotalInPence: 700}}, receipt.Lines)
+}
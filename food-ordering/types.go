@@ -17,26 +17,49 @@
 package foodordering
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/mrsimonemms/temporal-demos/food-ordering/webhook"
 )
 
 type OrderStatus string
 
 const (
 	OrderStatusDefault   OrderStatus = "DEFAULT"   // Order not paid yet
+	OrderStatusReview    OrderStatus = "REVIEW"    // Order held for an operator to check it's not fraudulent
+	OrderStatusDeclined  OrderStatus = "DECLINED"  // Order failed the fraud checks - no payment taken
+	OrderStatusScheduled OrderStatus = "SCHEDULED" // Order paid and waiting to be released to the restaurant
 	OrderStatusPending   OrderStatus = "PENDING"   // Order paid and waiting for restaurant to accept
 	OrderStatusAccepted  OrderStatus = "ACCEPTED"  // Restaurant accepted order, but not started work yet
 	OrderStatusPreparing OrderStatus = "PREPARING" // Restaurant is cooking your food
 	OrderStatusReady     OrderStatus = "READY"     // Food is ready for collection/out for delivery
 	OrderStatusCompleted OrderStatus = "COMPLETED" // Food given to a hungry person
 	OrderStatusRejected  OrderStatus = "REJECTED"  // Kitchen has rejected the order
+	OrderStatusCancelled OrderStatus = "CANCELLED" // Customer cancelled the order before it was released
+
+	OrderStatusNeedsAttention OrderStatus = "NEEDS_ATTENTION" // A payment or refund failed and is waiting for ops
 )
 
+// IsTerminal is true if the order won't change status again
+func (s OrderStatus) IsTerminal() bool {
+	return s == OrderStatusCompleted || s == OrderStatusRejected || s == OrderStatusCancelled || s == OrderStatusDeclined
+}
+
 func ParseOrderStatus(status string) (OrderStatus, error) {
 	switch strings.ToUpper(status) {
 	case "DEFAULT":
 		return OrderStatusDefault, nil
+	case "REVIEW":
+		return OrderStatusReview, nil
+	case "DECLINED":
+		return OrderStatusDeclined, nil
+	case "SCHEDULED":
+		return OrderStatusScheduled, nil
 	case "PENDING":
 		return OrderStatusPending, nil
 	case "ACCEPTED":
@@ -49,10 +72,36 @@ func ParseOrderStatus(status string) (OrderStatus, error) {
 		return OrderStatusRejected, nil
 	case "COMPLETED":
 		return OrderStatusCompleted, nil
+	case "CANCELLED":
+		return OrderStatusCancelled, nil
+	case "NEEDS_ATTENTION":
+		return OrderStatusNeedsAttention, nil
 	}
 
 	var o OrderStatus
-	return o, fmt.Errorf("invalid status: %q", o)
+	return o, fmt.Errorf("invalid status: %q", status)
+}
+
+// Statuses the restaurant can move the order to from each status. Everything
+// else is set by the workflow.
+var restaurantTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending:   {OrderStatusAccepted, OrderStatusRejected},
+	OrderStatusAccepted:  {OrderStatusPreparing, OrderStatusRejected},
+	OrderStatusPreparing: {OrderStatusReady, OrderStatusRejected},
+	OrderStatusReady:     {OrderStatusCompleted},
+}
+
+// ValidateRestaurantTransition checks the restaurant can move the order from
+// one status to the next
+func ValidateRestaurantTransition(from, to OrderStatus) error {
+	next, ok := restaurantTransitions[from]
+	if !ok {
+		return fmt.Errorf("order is not with the restaurant")
+	}
+	if !slices.Contains(next, to) {
+		return fmt.Errorf("order cannot go from %s to %s", from, to)
+	}
+	return nil
 }
 
 type Address struct {
@@ -65,17 +114,73 @@ type Address struct {
 }
 
 type OrderState struct {
-	Collection      bool           `json:"collection"`
-	DeliveryAddress *Address       `json:"deliveryAddress"`
-	Email           string         `json:"email"`
-	Products        []OrderProduct `json:"products"`
-	Status          OrderStatus    `json:"status"`
+	Collection        bool               `json:"collection"`
+	Complaints        []Complaint        `json:"complaints"`
+	CustomerID        string             `json:"customerId"` // Optional - links the order to the customer's history
+	DeliveryAddress   *Address           `json:"deliveryAddress" pii:"true"`
+	Discounts         []Discount         `json:"discounts"`
+	Email             string             `json:"email" pii:"true"`
+	ExpressCheckout   bool               `json:"expressCheckout"` // Optional - pays for the products straight away, without waiting for checkout
+	FulfilmentTime    *time.Time         `json:"fulfilmentTime"`  // Optional - if set, order is for later
+	Group             *GroupOrder        `json:"group"`           // Optional - if set, this is a group order
+	History           []StatusChange     `json:"history"`
+	Interventions     []Intervention     `json:"interventions"`        // Failed money operations and what ops did about them
+	IPAddress         string             `json:"ipAddress" pii:"true"` // Where the order was placed from, for the fraud checks
+	Loyalty           LoyaltyState       `json:"loyalty"`
+	Notes             string             `json:"notes"`          // Free text for the kitchen
+	PaymentMethods    []PaymentMethod    `json:"paymentMethods"` // Optional - pays by card if not set
+	Payments          []Payment          `json:"payments"`
+	PaymentChallenges []PaymentChallenge `json:"paymentChallenges"` // Payments waiting for the customer to authorise them
+	Products          []OrderProduct     `json:"products"`
+	Rating            *Rating            `json:"rating"`
+	RedeemPoints      int                `json:"redeemPoints"` // Optional - loyalty points to spend on the order
+	Risk              *RiskAssessment    `json:"risk"`         // Set once the fraud checks have run
+	Status            OrderStatus        `json:"status"`
+	Tips              []Tip              `json:"tips"` // Kept apart from the food so promotions never discount them
+}
+
+// SetStatus changes the status, recording it in the history
+func (o *OrderState) SetStatus(status OrderStatus, now time.Time) {
+	o.Status = status
+	o.History = append(o.History, StatusChange{
+		EventID: len(o.History) + 1,
+		Status:  status,
+		Time:    now,
+	})
+}
+
+// Clone is a deep copy of the order. It's plain data, so it can always be
+// encoded.
+func (o OrderState) Clone() OrderState {
+	data, err := json.Marshal(o)
+	if err != nil {
+		panic(fmt.Sprintf("error copying order: %s", err))
+	}
+
+	var clone OrderState
+	if err := json.Unmarshal(data, &clone); err != nil {
+		panic(fmt.Sprintf("error copying order: %s", err))
+	}
+	return clone
+}
+
+// Payer is who pays for the order, unless it's split between a group
+func (o *OrderState) Payer() string {
+	if o.Group != nil {
+		return o.Group.Organiser
+	}
+	return o.Email
+}
+
+// LastEventID is the ID of the most recent status change
+func (o *OrderState) LastEventID() int {
+	return len(o.History)
 }
 
 func (o *OrderState) AddItem(item OrderProduct) {
 	// Check if we're updating products
 	for i := range o.Products {
-		if o.Products[i].ProductID != item.ProductID {
+		if !o.Products[i].SameLine(item) {
 			continue
 		}
 
@@ -89,7 +194,7 @@ func (o *OrderState) AddItem(item OrderProduct) {
 
 func (o *OrderState) RemoveItem(item OrderProduct) {
 	for i := range o.Products {
-		if o.Products[i].ProductID != item.ProductID {
+		if !o.Products[i].SameLine(item) {
 			continue
 		}
 
@@ -101,9 +206,233 @@ func (o *OrderState) RemoveItem(item OrderProduct) {
 	}
 }
 
+// RemoveItemsFor removes everything a participant has added to the basket
+func (o *OrderState) RemoveItemsFor(owner string) {
+	products := make([]OrderProduct, 0)
+	for _, p := range o.Products {
+		if !strings.EqualFold(p.Owner, owner) {
+			products = append(products, p)
+		}
+	}
+	o.Products = products
+}
+
+// ValidateBasket checks the basket can be paid for
+func (o *OrderState) ValidateBasket() error {
+	if len(o.Products) == 0 {
+		return fmt.Errorf("basket is empty")
+	}
+
+	for _, p := range o.Products {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Subtotal is the value of the basket before discounts
+func (o *OrderState) Subtotal() int {
+	total := 0
+	for _, p := range o.Products {
+		total += p.TotalInPence()
+	}
+	return total
+}
+
+// Total is the amount to pay for the basket
+func (o *OrderState) Total() int {
+	total := o.Subtotal()
+	for _, d := range o.Discounts {
+		total -= d.AmountInPence
+	}
+	return max(total, 0)
+}
+
+// RefundedInPence is how much of the food payments has been given back
+func (o *OrderState) RefundedInPence() int {
+	total := 0
+	for _, p := range o.Payments {
+		total += p.RefundedInPence
+	}
+	return total
+}
+
+// TotalFor is the value of the items in the basket added by the owner
+func (o *OrderState) TotalFor(owner string) int {
+	total := 0
+	for _, p := range o.Products {
+		if strings.EqualFold(p.Owner, owner) {
+			total += p.TotalInPence()
+		}
+	}
+	return total
+}
+
+type StatusChange struct {
+	EventID int         `json:"eventId"` // Sequential, starting at 1
+	Status  OrderStatus `json:"status"`
+	Time    time.Time   `json:"time"`
+}
+
+// OrderEvent is the state of the order as at the event
+type OrderEvent struct {
+	EventID int        `json:"eventId"`
+	State   OrderState `json:"state"`
+}
+
+// OrderChange is published to the outbox and partners each time the order changes status
+type OrderChange struct {
+	EventID      int         `json:"eventId"`
+	ID           string      `json:"id"` // Unique across all orders
+	Order        OrderState  `json:"order"`
+	OrderID      string      `json:"orderId"`
+	RestaurantID string      `json:"restaurantId"`
+	Status       OrderStatus `json:"status"`
+	Time         time.Time   `json:"time"`
+	Type         string      `json:"type"` // Webhook event type
+}
+
+// WebhookOrder is the order as it's sent to partners - they don't need the
+// customer's details or the whole order
+type WebhookOrder struct {
+	Collection      bool        `json:"collection"`
+	CreatedAt       time.Time   `json:"createdAt"`
+	EventID         int         `json:"eventId"`
+	FulfilmentTime  *time.Time  `json:"fulfilmentTime"`
+	OrderID         string      `json:"orderId"`
+	RestaurantID    string      `json:"restaurantId"`
+	Status          OrderStatus `json:"status"`
+	SubtotalInPence int         `json:"subtotalInPence"`
+	TipsInPence     int         `json:"tipsInPence"`
+	TotalInPence    int         `json:"totalInPence"`
+	UpdatedAt       time.Time   `json:"updatedAt"`
+}
+
+// WebhookEvent is the change as it's sent to partners
+func (c OrderChange) WebhookEvent() webhook.Event {
+	tips, _, _ := c.Order.TipsInPence()
+
+	order := WebhookOrder{
+		Collection:      c.Order.Collection,
+		EventID:         c.EventID,
+		FulfilmentTime:  c.Order.FulfilmentTime,
+		OrderID:         c.OrderID,
+		RestaurantID:    c.RestaurantID,
+		Status:          c.Status,
+		SubtotalInPence: c.Order.Subtotal(),
+		TipsInPence:     tips,
+		TotalInPence:    c.Order.Total(),
+		UpdatedAt:       c.Time,
+	}
+	if len(c.Order.History) > 0 {
+		order.CreatedAt = c.Order.History[0].Time
+	}
+
+	return webhook.Event{
+		CreatedAt: c.Time,
+		Data:      order,
+		ID:        c.ID,
+		Type:      c.Type,
+	}
+}
+
+type WebhookRequest struct {
+	Event        webhook.Event `json:"event"`
+	RestaurantID string        `json:"restaurantId"`
+}
+
+// NewOrderChange is the order's latest status change
+func NewOrderChange(orderID, restaurantID string, state OrderState) OrderChange {
+	change := state.History[len(state.History)-1]
+
+	return OrderChange{
+		EventID:      change.EventID,
+		ID:           fmt.Sprintf("%s/%d", orderID, change.EventID),
+		Order:        state.Clone(), // Sent later, so mustn't see the order change
+		OrderID:      orderID,
+		RestaurantID: restaurantID,
+		Status:       change.Status,
+		Time:         change.Time,
+		Type:         OrderStatusChangedEvent,
+	}
+}
+
+// NewRefundChange is published when money's given back, as there may not be a
+// status change to carry it
+func NewRefundChange(orderID, restaurantID string, state OrderState, refundedAt time.Time) OrderChange {
+	change := NewOrderChange(orderID, restaurantID, state)
+	change.ID = fmt.Sprintf("%s/refund/%d", orderID, state.RefundedInPence())
+	change.Time = refundedAt
+	change.Type = OrderRefundedEvent
+	return change
+}
+
+type Discount struct {
+	AmountInPence int    `json:"amountInPence"`
+	Description   string `json:"description"`
+}
+
 type OrderProduct struct {
-	ProductID int `json:"productId"`
-	Quantity  int `json:"quantity"`
+	Modifiers []string `json:"modifiers"`        // Changes to the product, eg "no salt"
+	Notes     string   `json:"notes"`            // Free text for the kitchen
+	Owner     string   `json:"owner" pii:"true"` // Participant who added the item in a group order
+	ProductID int      `json:"productId"`
+	Quantity  int      `json:"quantity"`
+}
+
+// SameLine is true if both are for the same line in the basket
+func (p OrderProduct) SameLine(item OrderProduct) bool {
+	return p.ProductID == item.ProductID &&
+		p.Owner == item.Owner &&
+		p.Notes == item.Notes &&
+		slices.Equal(p.Modifiers, item.Modifiers)
+}
+
+func (p OrderProduct) TotalInPence() int {
+	product, err := GetProduct(p.ProductID)
+	if err != nil {
+		return 0
+	}
+	return product.PriceInPence() * p.Quantity
+}
+
+func (p OrderProduct) Validate() error {
+	if _, err := GetProduct(p.ProductID); err != nil {
+		return err
+	}
+	if p.Quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+	return nil
+}
+
+type Payment struct {
+	Account         string            `json:"account,omitempty"` // Gift card or wallet the payment was taken from
+	AmountInPence   int               `json:"amountInPence"`
+	Challenge       *PaymentChallenge `json:"challenge,omitempty"` // Set if the payment needs authorising before it's taken
+	Method          PaymentMethodType `json:"method"`
+	Payer           string            `json:"payer" pii:"true"`
+	RefundedInPence int               `json:"refundedInPence"`
+	TransactionID   string            `json:"transactionId"`
+}
+
+// Refundable is how much of the payment hasn't been refunded
+func (p Payment) Refundable() int {
+	return p.AmountInPence - p.RefundedInPence
+}
+
+type RefundRequest struct {
+	AmountInPence int    `json:"amountInPence"`
+	TransactionID string `json:"transactionId"`
+}
+
+type PaymentRequest struct {
+	AmountInPence int    `json:"amountInPence"`
+	OrderID       string `json:"orderId"` // Order the provider sends the challenge result to
+	Payer         string `json:"payer" pii:"true"`
+	Reference     string `json:"reference"` // Unique to the charge, so retries don't take the payment twice
 }
 
 type Product struct {
@@ -112,9 +441,19 @@ type Product struct {
 	Price     float64 `json:"price"`
 }
 
+func (p Product) PriceInPence() int {
+	return int(math.Round(p.Price * 100))
+}
+
 func NewOrderState() OrderState {
 	return OrderState{
-		Products: make([]OrderProduct, 0),
-		Status:   OrderStatusDefault,
+		Complaints:        make([]Complaint, 0),
+		Discounts:         make([]Discount, 0),
+		History:           make([]StatusChange, 0),
+		Interventions:     make([]Intervention, 0),
+		Payments:          make([]Payment, 0),
+		PaymentChallenges: make([]PaymentChallenge, 0),
+		Products:          make([]OrderProduct, 0),
+		Status:            OrderStatusDefault,
 	}
 }
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderChangeKeepsOrderAsItWas(t *testing.T) {
+	state := newTestOrder()
+	state.SetStatus(OrderStatusPending, time.Now())
+	state.Tips = append(state.Tips, Tip{AmountInPence: 100, Status: TipStatusPending, TipID: "T1"})
+
+	change := NewOrderChange("order-1", "codfather", state)
+
+	// Changed in place whilst the change waits in the outbox
+	state.Products[0].Quantity = 10
+	state.GetTip("T1").Status = TipStatusPaid
+
+	assert.Equal(t, 2, change.Order.Products[0].Quantity)
+	assert.Equal(t, TipStatusPending, change.Order.Tips[0].Status)
+}
+
+func TestWebhookEvent(t *testing.T) {
+	state := newTestOrder()
+	state.IPAddress = "192.0.2.1"
+	state.SetStatus(OrderStatusPending, testStartTime)
+	state.SetStatus(OrderStatusAccepted, testStartTime.Add(time.Minute))
+
+	event := NewOrderChange("order-1", "codfather", state).WebhookEvent()
+	assert.Equal(t, "order-1/2", event.ID)
+	assert.Equal(t, WebhookOrder{
+		Collection:      true,
+		CreatedAt:       testStartTime,
+		EventID:         2,
+		OrderID:         "order-1",
+		RestaurantID:    "codfather",
+		Status:          OrderStatusAccepted,
+		SubtotalInPence: testOrderTotal,
+		TotalInPence:    testOrderTotal,
+		UpdatedAt:       testStartTime.Add(time.Minute),
+	}, event.Data)
+
+	// Partners aren't sent the customer's details
+	body, err := json.Marshal(event)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(body), state.Email)
+	assert.NotContains(t, string(body), state.IPAddress)
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Changing a workflow's code can break the workflows already running it, as
+// they're replayed against the new code when the worker restarts. There are
+// two ways to keep them safe.
+//
+// Worker versioning runs each build of the worker as a separate version of
+// the deployment. Pinned workflows finish on the build they started on, so
+// their code can change freely - the old build keeps running until they're
+// done. Auto-upgrade workflows move to the new build as soon as it's
+// current, so they never hold an old build up.
+//
+// Any change to an auto-upgrade workflow, or to a workflow running on an
+// unversioned worker, must be gated with workflow.GetVersion. Running
+// workflows get workflow.DefaultVersion and carry on down the old path, new
+// ones record the latest version:
+//
+//	if workflow.GetVersion(ctx, "email-receipt", workflow.DefaultVersion, 1) == 1 {
+//		// New code
+//	}
+//
+// Once nothing's running the old path, raise the minimum supported version
+// and delete it. The replay tests check recorded histories still run - add a
+// history of the old path before changing it.
+//
+// An old build can't be shut down while it has pinned workflows. Once a new
+// build is current, the old one is draining until they've all finished - check
+// with "temporal worker deployment describe-version" and only stop its workers
+// once it's drained.
+
+const DefaultDeploymentName = "food-ordering"
+
+// Behaviour for each workflow type when the worker is versioned
+var DefaultVersioningBehaviours = map[string]workflow.VersioningBehavior{
+	// Reports finish in minutes, so old builds drain quickly
+	"DailySalesReportWorkflow": workflow.VersioningBehaviorPinned,
+	// Customers run forever, and orders stay open for days - scheduled orders,
+	// then the feedback and tip windows - so they move to the new code rather
+	// than holding old builds up. Changes to them must use GetVersion.
+	"CustomerWorkflow": workflow.VersioningBehaviorAutoUpgrade,
+	"OrderWorkflow":    workflow.VersioningBehaviorAutoUpgrade,
+}
+
+// WorkerVersioning configures the worker as a version of a worker deployment.
+// It's disabled unless a build ID is set.
+type WorkerVersioning struct {
+	BuildID          string
+	DefaultBehaviour workflow.VersioningBehavior // For workflow types without a behaviour
+	DeploymentName   string
+	Behaviours       map[string]workflow.VersioningBehavior
+}
+
+// WorkerVersioningFromEnv reads the versioning config. WORKER_BEHAVIOURS sets
+// the behaviour by workflow type, eg "OrderWorkflow=auto-upgrade".
+func WorkerVersioningFromEnv() (*WorkerVersioning, error) {
+	v := &WorkerVersioning{
+		BuildID:          os.Getenv("WORKER_BUILD_ID"),
+		DefaultBehaviour: workflow.VersioningBehaviorAutoUpgrade,
+		DeploymentName:   os.Getenv("WORKER_DEPLOYMENT_NAME"),
+		Behaviours:       make(map[string]workflow.VersioningBehavior),
+	}
+	if v.DeploymentName == "" {
+		v.DeploymentName = DefaultDeploymentName
+	}
+	for workflowType, behaviour := range DefaultVersioningBehaviours {
+		v.Behaviours[workflowType] = behaviour
+	}
+
+	if value := os.Getenv("WORKER_DEFAULT_BEHAVIOUR"); value != "" {
+		behaviour, err := ParseVersioningBehaviour(value)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing WORKER_DEFAULT_BEHAVIOUR: %w", err)
+		}
+		v.DefaultBehaviour = behaviour
+	}
+
+	if value := os.Getenv("WORKER_BEHAVIOURS"); value != "" {
+		for _, pair := range strings.Split(value, ",") {
+			workflowType, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok || workflowType == "" {
+				return nil, fmt.Errorf("error parsing WORKER_BEHAVIOURS: expected workflowType=behaviour, got %q", pair)
+			}
+
+			behaviour, err := ParseVersioningBehaviour(value)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing WORKER_BEHAVIOURS: %w", err)
+			}
+			v.Behaviours[workflowType] = behaviour
+		}
+	}
+
+	return v, nil
+}
+
+func ParseVersioningBehaviour(value string) (workflow.VersioningBehavior, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "pinned":
+		return workflow.VersioningBehaviorPinned, nil
+	case "auto-upgrade", "autoupgrade", "auto_upgrade":
+		return workflow.VersioningBehaviorAutoUpgrade, nil
+	default:
+		return workflow.VersioningBehaviorUnspecified, fmt.Errorf("unknown versioning behaviour: %s", value)
+	}
+}
+
+func (v *WorkerVersioning) Enabled() bool {
+	return v.BuildID != ""
+}
+
+func (v *WorkerVersioning) DeploymentOptions() worker.DeploymentOptions {
+	if !v.Enabled() {
+		return worker.DeploymentOptions{}
+	}
+
+	return worker.DeploymentOptions{
+		DefaultVersioningBehavior: v.DefaultBehaviour,
+		UseVersioning:             true,
+		Version: worker.WorkerDeploymentVersion{
+			BuildId:        v.BuildID,
+			DeploymentName: v.DeploymentName,
+		},
+	}
+}
+
+// RegisterOptions sets the workflow type's behaviour - it has to be registered
+// under the same name
+func (v *WorkerVersioning) RegisterOptions(workflowType string) workflow.RegisterOptions {
+	opts := workflow.RegisterOptions{Name: workflowType}
+	if v.Enabled() {
+		opts.VersioningBehavior = v.Behaviours[workflowType]
+	}
+	return opts
+}
@@ -0,0 +1,275 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/api/taskqueue/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+func TestWorkerVersioningFromEnv(t *testing.T) {
+	t.Run("disabled without a build ID", func(t *testing.T) {
+		v, err := WorkerVersioningFromEnv()
+		require.NoError(t, err)
+
+		assert.False(t, v.Enabled())
+		assert.Equal(t, worker.DeploymentOptions{}, v.DeploymentOptions())
+		assert.Equal(t, workflow.RegisterOptions{Name: "OrderWorkflow"}, v.RegisterOptions("OrderWorkflow"))
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		t.Setenv("WORKER_BUILD_ID", "build-2")
+		t.Setenv("WORKER_BEHAVIOURS", "CustomerWorkflow=pinned, DailySalesReportWorkflow=auto-upgrade")
+
+		v, err := WorkerVersioningFromEnv()
+		require.NoError(t, err)
+
+		assert.Equal(t, worker.DeploymentOptions{
+			DefaultVersioningBehavior: workflow.VersioningBehaviorAutoUpgrade,
+			UseVersioning:             true,
+			Version: worker.WorkerDeploymentVersion{
+				BuildId:        "build-2",
+				DeploymentName: DefaultDeploymentName,
+			},
+		}, v.DeploymentOptions())
+		assert.Equal(t, workflow.VersioningBehaviorAutoUpgrade, v.RegisterOptions("OrderWorkflow").VersioningBehavior)
+		assert.Equal(t, workflow.VersioningBehaviorPinned, v.RegisterOptions("CustomerWorkflow").VersioningBehavior)
+		assert.Equal(t, workflow.VersioningBehaviorAutoUpgrade, v.RegisterOptions("DailySalesReportWorkflow").VersioningBehavior)
+		// Falls back to the default
+		assert.Equal(t, workflow.VersioningBehaviorUnspecified, v.RegisterOptions("NewWorkflow").VersioningBehavior)
+	})
+
+	t.Run("invalid behaviour", func(t *testing.T) {
+		t.Setenv("WORKER_BEHAVIOURS", "OrderWorkflow=sometimes")
+
+		_, err := WorkerVersioningFromEnv()
+		assert.Error(t, err)
+	})
+}
+
+// The GetVersion pattern, shown with a change to how the customer's notified.
+// Customers used to only get a text...
+func notifyCustomerV1(ctx workflow.Context) error {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: time.Minute})
+	return workflow.ExecuteActivity(ctx, "SendTextMessage").Get(ctx, nil)
+}
+
+// ...then an email was added without gating it, which breaks running workflows...
+func notifyCustomerUnpatched(ctx workflow.Context) error {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: time.Minute})
+	if err := workflow.ExecuteActivity(ctx, "SendEmail").Get(ctx, nil); err != nil {
+		return err
+	}
+	return workflow.ExecuteActivity(ctx, "SendTextMessage").Get(ctx, nil)
+}
+
+// ...so the email is gated - running workflows keep the old path
+func notifyCustomerPatched(ctx workflow.Context) error {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: time.Minute})
+	if workflow.GetVersion(ctx, "email-customer", workflow.DefaultVersion, 1) == 1 {
+		if err := workflow.ExecuteActivity(ctx, "SendEmail").Get(ctx, nil); err != nil {
+			return err
+		}
+	}
+	return workflow.ExecuteActivity(ctx, "SendTextMessage").Get(ctx, nil)
+}
+
+// historyBuilder writes the events the server would record for a workflow
+// that only runs activities, one workflow task per activity
+type historyBuilder struct {
+	events             []*historypb.HistoryEvent
+	lastTaskCompletion int64
+}
+
+func newHistoryBuilder(workflowType string) *historyBuilder {
+	h := &historyBuilder{}
+	h.add(&historypb.HistoryEvent{
+		EventType: enums.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED,
+		Attributes: &historypb.HistoryEvent_WorkflowExecutionStartedEventAttributes{
+			WorkflowExecutionStartedEventAttributes: &historypb.WorkflowExecutionStartedEventAttributes{
+				Attempt:      1,
+				TaskQueue:    &taskqueue.TaskQueue{Name: OrderFoodTaskQueue},
+				WorkflowType: &common.WorkflowType{Name: workflowType},
+			},
+		},
+	})
+	h.task()
+	return h
+}
+
+func (h *historyBuilder) add(event *historypb.HistoryEvent) int64 {
+	event.EventId = int64(len(h.events) + 1)
+	h.events = append(h.events, event)
+	return event.EventId
+}
+
+func (h *historyBuilder) task() {
+	scheduled := h.add(&historypb.HistoryEvent{
+		EventType: enums.EVENT_TYPE_WORKFLOW_TASK_SCHEDULED,
+		Attributes: &historypb.HistoryEvent_WorkflowTaskScheduledEventAttributes{
+			WorkflowTaskScheduledEventAttributes: &historypb.WorkflowTaskScheduledEventAttributes{Attempt: 1},
+		},
+	})
+	started := h.add(&historypb.HistoryEvent{
+		EventType: enums.EVENT_TYPE_WORKFLOW_TASK_STARTED,
+		Attributes: &historypb.HistoryEvent_WorkflowTaskStartedEventAttributes{
+			WorkflowTaskStartedEventAttributes: &historypb.WorkflowTaskStartedEventAttributes{ScheduledEventId: scheduled},
+		},
+	})
+	h.lastTaskCompletion = h.add(&historypb.HistoryEvent{
+		EventType: enums.EVENT_TYPE_WORKFLOW_TASK_COMPLETED,
+		Attributes: &historypb.HistoryEvent_WorkflowTaskCompletedEventAttributes{
+			WorkflowTaskCompletedEventAttributes: &historypb.WorkflowTaskCompletedEventAttributes{
+				ScheduledEventId: scheduled,
+				StartedEventId:   started,
+			},
+		},
+	})
+}
+
+func (h *historyBuilder) activity(activityType string) {
+	scheduled := h.add(&historypb.HistoryEvent{
+		EventType: enums.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED,
+		Attributes: &historypb.HistoryEvent_ActivityTaskScheduledEventAttributes{
+			ActivityTaskScheduledEventAttributes: &historypb.ActivityTaskScheduledEventAttributes{
+				// The SDK uses the event ID
+				ActivityId:                   fmt.Sprint(len(h.events) + 1),
+				ActivityType:                 &common.ActivityType{Name: activityType},
+				WorkflowTaskCompletedEventId: h.lastTaskCompletion,
+			},
+		},
+	})
+	started := h.add(&historypb.HistoryEvent{
+		EventType: enums.EVENT_TYPE_ACTIVITY_TASK_STARTED,
+		Attributes: &historypb.HistoryEvent_ActivityTaskStartedEventAttributes{
+			ActivityTaskStartedEventAttributes: &historypb.ActivityTaskStartedEventAttributes{ScheduledEventId: scheduled},
+		},
+	})
+	h.add(&historypb.HistoryEvent{
+		EventType: enums.EVENT_TYPE_ACTIVITY_TASK_COMPLETED,
+		Attributes: &historypb.HistoryEvent_ActivityTaskCompletedEventAttributes{
+			ActivityTaskCompletedEventAttributes: &historypb.ActivityTaskCompletedEventAttributes{
+				ScheduledEventId: scheduled,
+				StartedEventId:   started,
+			},
+		},
+	})
+	h.task()
+}
+
+// version is what GetVersion records the first time it's called
+func (h *historyBuilder) version(t *testing.T, changeID string, version workflow.Version) {
+	dc := converter.GetDefaultDataConverter()
+	changeIDPayload, err := dc.ToPayloads(changeID)
+	require.NoError(t, err)
+	versionPayload, err := dc.ToPayloads(version)
+	require.NoError(t, err)
+	changeVersions, err := dc.ToPayload([]string{fmt.Sprintf("%s-%d", changeID, version)})
+	require.NoError(t, err)
+	changeVersions.Metadata["type"] = []byte("KeywordList")
+
+	h.add(&historypb.HistoryEvent{
+		EventType: enums.EVENT_TYPE_MARKER_RECORDED,
+		Attributes: &historypb.HistoryEvent_MarkerRecordedEventAttributes{
+			MarkerRecordedEventAttributes: &historypb.MarkerRecordedEventAttributes{
+				Details: map[string]*common.Payloads{
+					"change-id": changeIDPayload,
+					"version":   versionPayload,
+				},
+				MarkerName:                   "Version",
+				WorkflowTaskCompletedEventId: h.lastTaskCompletion,
+			},
+		},
+	})
+	h.add(&historypb.HistoryEvent{
+		EventType: enums.EVENT_TYPE_UPSERT_WORKFLOW_SEARCH_ATTRIBUTES,
+		Attributes: &historypb.HistoryEvent_UpsertWorkflowSearchAttributesEventAttributes{
+			UpsertWorkflowSearchAttributesEventAttributes: &historypb.UpsertWorkflowSearchAttributesEventAttributes{
+				SearchAttributes: &common.SearchAttributes{
+					IndexedFields: map[string]*common.Payload{"TemporalChangeVersion": changeVersions},
+				},
+				WorkflowTaskCompletedEventId: h.lastTaskCompletion,
+			},
+		},
+	})
+}
+
+func (h *historyBuilder) completed() *historypb.History {
+	h.add(&historypb.HistoryEvent{
+		EventType: enums.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED,
+		Attributes: &historypb.HistoryEvent_WorkflowExecutionCompletedEventAttributes{
+			WorkflowExecutionCompletedEventAttributes: &historypb.WorkflowExecutionCompletedEventAttributes{
+				WorkflowTaskCompletedEventId: h.lastTaskCompletion,
+			},
+		},
+	})
+	return &historypb.History{Events: h.events}
+}
+
+func replayNotifyCustomer(notifyCustomer func(ctx workflow.Context) error, history *historypb.History) error {
+	replayer := worker.NewWorkflowReplayer()
+	replayer.RegisterWorkflowWithOptions(notifyCustomer, workflow.RegisterOptions{Name: "NotifyCustomer"})
+	return replayer.ReplayWorkflowHistory(replayLogger, history)
+}
+
+func TestGetVersionPattern(t *testing.T) {
+	// Started before the email was added
+	h := newHistoryBuilder("NotifyCustomer")
+	h.activity("SendTextMessage")
+	unpatched := h.completed()
+
+	// Started after
+	h = newHistoryBuilder("NotifyCustomer")
+	h.version(t, "email-customer", 1)
+	h.activity("SendEmail")
+	h.activity("SendTextMessage")
+	patched := h.completed()
+
+	t.Run("old code replays old workflows", func(t *testing.T) {
+		assert.NoError(t, replayNotifyCustomer(notifyCustomerV1, unpatched))
+	})
+
+	t.Run("ungated change breaks old workflows", func(t *testing.T) {
+		err := replayNotifyCustomer(notifyCustomerUnpatched, unpatched)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "nondeterministic workflow")
+	})
+
+	t.Run("gated change replays old workflows", func(t *testing.T) {
+		assert.NoError(t, replayNotifyCustomer(notifyCustomerPatched, unpatched))
+	})
+
+	t.Run("gated change replays new workflows", func(t *testing.T) {
+		assert.NoError(t, replayNotifyCustomer(notifyCustomerPatched, patched))
+	})
+
+	t.Run("old code can't replay new workflows", func(t *testing.T) {
+		// Why the old build has to keep running until its workflows finish
+		assert.Error(t, replayNotifyCustomer(notifyCustomerV1, patched))
+	})
+}
@@ -21,29 +21,56 @@ interface IProduct {
   quantity?: number;
 }
 
-interface IProduct2 {
-  collection: boolean;
-  products: { productId: number; quantity: number }[];
-  status: OrderStatus;
-}
-
 type OrderStatus =
   | 'DEFAULT' // Order not paid yet
+  | 'REVIEW' // Order held for an operator to check it's not fraudulent
+  | 'DECLINED' // Order failed the fraud checks - no payment taken
+  | 'SCHEDULED' // Order paid and waiting to be released to the restaurant
   | 'PENDING' // Order paid and waiting for restaurant to accept
   | 'ACCEPTED' // Restaurant accepted order, but not started work yet
   | 'PREPARING' // Restaurant is cooking your food
   | 'READY' // Food is ready for collection/out for delivery
   | 'REJECTED' // Kitchen has rejected the order
+  | 'CANCELLED' // Customer cancelled the order before it was released
+  | 'NEEDS_ATTENTION' // A payment or refund failed and is waiting for ops
   | 'COMPLETED'; // Food given to a hungry person
 
+interface IPaymentChallenge {
+  chargeId: string;
+  payer: string;
+  url: string; // Where the customer authorises the payment with their bank
+}
+
+interface IPaymentMethod {
+  amountInPence?: number; // Most to take from a gift card or wallet
+  code?: string; // Gift card code
+  type: 'CARD' | 'GIFT_CARD' | 'WALLET';
+}
+
+interface ITip {
+  amountInPence: number;
+  courierInPence: number;
+  kitchenInPence: number;
+  status: 'PENDING' | 'PAID' | 'FAILED' | 'REFUNDED';
+  tipId: string;
+  tippedAt: string;
+}
+
 interface IOrderState {
   collection: boolean;
+  fulfilmentTime?: string | null;
+  paymentChallenges?: IPaymentChallenge[];
+  paymentMethods?: IPaymentMethod[];
   products: IProduct[];
   status: OrderStatus;
+  tips?: ITip[];
 }
 
-interface IOrder {
+interface IOrderListItem {
+  collection: boolean;
   orderId: string;
-  state: IOrderState;
-  created: Date;
+  restaurantId: string;
+  startTime: string;
+  status: OrderStatus;
+  totalInPence: number;
 }
@@ -15,78 +15,45 @@
  */
 
 import { json, type RequestHandler } from '@sveltejs/kit';
-import Long from 'long';
-import { nanoid } from 'nanoid';
 
-function timestampToDate(ts: {
-  seconds?: Long | null;
-  nanos?: number | null;
-}): Date {
-  // Convert Long to number (safe for UNIX seconds < ~285,000 years 😅)
-  const seconds = ts.seconds?.toNumber() ?? 0;
-  const nanos = ts.nanos ?? 0;
+const ordersApiUrl = process.env.ORDERS_API_URL ?? 'http://localhost:3000';
 
-  return new Date(seconds * 1000 + nanos / 1e6);
-}
-
-import { getProduct } from '$lib/products';
-import { ensureConnection } from '$lib/server/temporal';
-
-export const GET: RequestHandler = async () => {
-  const temporal = await ensureConnection();
-
-  // This isn't a great way, but I want to avoid a DB for this demo
-  const { executions } = await temporal.workflowService.listWorkflowExecutions({
-    namespace: 'default',
-    query: 'ExecutionStatus="Running"',
-  });
-
-  const orders: IOrder[] = [];
-
-  for (const exec of executions) {
-    const orderId = exec.execution?.workflowId ?? 'unknown';
-
-    const handler = temporal.workflow.getHandle(orderId);
-
-    const state = (await handler.query('GET_STATUS')) as IProduct2;
-
-    orders.push({
-      orderId,
-      state: {
-        collection: true,
-        products: state.products.map((item) => {
-          const p = getProduct(item.productId);
-          return {
-            id: item.productId,
-            name: p?.name ?? '',
-            price: p?.price ?? 0,
-            quantity: item.quantity,
-          };
-        }),
-        status: state.status as OrderStatus,
-      },
-      created: exec.startTime ? timestampToDate(exec.startTime) : new Date(),
-    });
+export const GET: RequestHandler = async ({ fetch }) => {
+  // The API lists the orders from their search attributes, so the workflows
+  // aren't queried one by one
+  const response = await fetch(
+    `${ordersApiUrl}/orders?running=true&pageSize=100`,
+  );
+  if (!response.ok) {
+    return json(await response.json(), { status: response.status });
   }
 
+  const { orders } = (await response.json()) as {
+    orders: IOrderListItem[];
+  };
+
   return json({
     orders,
   });
 };
 
-export const POST: RequestHandler = async ({ request }) => {
-  const temporal = await ensureConnection();
-
-  const data = await request.json();
-  const workflowId = `order-${nanoid()}`;
-
-  await temporal.workflow.start('OrderWorkflow', {
-    taskQueue: 'order-food',
-    args: [data],
-    workflowId,
+export const POST: RequestHandler = async ({ fetch, request }) => {
+  // Orders are started by the API, which validates them and encrypts the
+  // workflow's payloads when a codec's configured
+  const response = await fetch(`${ordersApiUrl}/orders`, {
+    method: 'POST',
+    headers: {
+      'content-type': 'application/json',
+    },
+    body: await request.text(),
   });
+  if (!response.ok) {
+    return json(await response.json(), { status: response.status });
+  }
+
+  const { orderId } = (await response.json()) as { orderId: string };
 
   return json({
-    orderId: workflowId,
+    orderId,
   });
 };
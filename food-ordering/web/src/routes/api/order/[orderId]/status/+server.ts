@@ -14,34 +14,35 @@
  * limitations under the License.
  */
 
-import { ensureConnection } from '$lib/server/temporal';
 import { json, type RequestHandler } from '@sveltejs/kit';
 
-export const GET: RequestHandler = async ({ params }) => {
-  const temporal = await ensureConnection();
+const ordersApiUrl = process.env.ORDERS_API_URL ?? 'http://localhost:3000';
 
-  const handler = temporal.workflow.getHandle(params.orderId ?? '');
+export const GET: RequestHandler = async ({ fetch, params }) => {
+  // The API decodes the order's state when a codec's configured
+  const response = await fetch(`${ordersApiUrl}/orders/${params.orderId}`);
 
-  // Validate the workflow exists
-  await handler.describe();
-
-  return json(await handler.query('GET_STATUS'));
+  return json(await response.json(), { status: response.status });
 };
 
-export const POST: RequestHandler = async ({ params, request }) => {
-  const temporal = await ensureConnection();
-
+export const POST: RequestHandler = async ({ fetch, params, request }) => {
   const data = await request.json();
-  const handler = temporal.workflow.getHandle(params.orderId ?? '');
-
-  // Validate the workflow exists
-  await handler.describe();
-
-  await handler.executeUpdate('UPDATE_STATUS', {
-    args: [data.status],
-  });
 
-  return json({
-    hello: 'world',
-  });
+  const response = await fetch(
+    `${ordersApiUrl}/orders/${params.orderId}/status`,
+    {
+      method: 'PUT',
+      headers: {
+        'content-type': 'application/json',
+      },
+      body: JSON.stringify({
+        status: data.status,
+      }),
+    },
+  );
+  if (!response.ok) {
+    return json(await response.json(), { status: response.status });
+  }
+
+  return new Response(null, { status: 204 });
 };
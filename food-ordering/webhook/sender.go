@@ -0,0 +1,165 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultAttempts       = 5
+	defaultInitialBackoff = time.Second
+	defaultTimeout        = 10 * time.Second
+)
+
+// Sender delivers events to the subscriptions, retrying with exponential backoff
+type Sender struct {
+	Attempts       int
+	Client         *http.Client
+	InitialBackoff time.Duration
+	Store          Store
+
+	// Deliveries from an earlier go at sending the event. Subscriptions it was
+	// delivered to aren't sent it again.
+	Previous []Delivery
+
+	// Called before each attempt, eg to heartbeat an activity
+	OnAttempt func(subscription Subscription, attempt int)
+	// Called once each subscription's delivery has been recorded
+	OnDelivery func(delivery Delivery)
+}
+
+func (s *Sender) delivered(subscriptionID, eventID string) *Delivery {
+	for i := range s.Previous {
+		d := s.Previous[i]
+		if d.SubscriptionID == subscriptionID && d.EventID == eventID && d.Delivered {
+			return &d
+		}
+	}
+	return nil
+}
+
+// Send delivers the event to each of the restaurant's subscriptions that want
+// it, returning what happened to each
+func (s *Sender) Send(ctx context.Context, restaurantID string, event Event) ([]Delivery, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding webhook event: %w", err)
+	}
+
+	subs, err := s.Store.Subscriptions(restaurantID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting webhook subscriptions: %w", err)
+	}
+
+	deliveries := make([]Delivery, 0)
+	for _, sub := range subs {
+		if !sub.Wants(event.Type) {
+			continue
+		}
+		if previous := s.delivered(sub.ID, event.ID); previous != nil {
+			deliveries = append(deliveries, *previous)
+			continue
+		}
+
+		delivery := s.deliver(ctx, sub, event.ID, body)
+		if _, err := s.Store.RecordDelivery(delivery); err != nil {
+			return nil, fmt.Errorf("error recording webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+		if s.OnDelivery != nil {
+			s.OnDelivery(delivery)
+		}
+	}
+
+	return deliveries, nil
+}
+
+func (s *Sender) deliver(ctx context.Context, sub Subscription, eventID string, body []byte) Delivery {
+	delivery := Delivery{
+		EventID:        eventID,
+		SubscriptionID: sub.ID,
+	}
+
+	backoff := s.InitialBackoff
+	for delivery.Attempts < s.Attempts {
+		if delivery.Attempts > 0 {
+			select {
+			case <-ctx.Done():
+				delivery.Error = ctx.Err().Error()
+				return delivery
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		delivery.Attempts++
+		if s.OnAttempt != nil {
+			s.OnAttempt(sub, delivery.Attempts)
+		}
+
+		statusCode, err := s.post(ctx, sub, eventID, body)
+		delivery.StatusCode = statusCode
+		delivery.Time = time.Now()
+		if err == nil {
+			delivery.Delivered = true
+			delivery.Error = ""
+			return delivery
+		}
+		delivery.Error = err.Error()
+	}
+
+	return delivery
+}
+
+func (s *Sender) post(ctx context.Context, sub Subscription, eventID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IDHeader, eventID)
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, body, time.Now()))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error sending webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+func NewSender(store Store) *Sender {
+	return &Sender{
+		Attempts:       defaultAttempts,
+		Client:         &http.Client{Timeout: defaultTimeout},
+		InitialBackoff: defaultInitialBackoff,
+		Store:          store,
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Deliveries to a subscription can fail this many times in a row before it's disabled
+const MaxConsecutiveFailures = 5
+
+type Store interface {
+	// Subscriptions returns the restaurant's subscriptions, including disabled ones
+	Subscriptions(restaurantID string) ([]Subscription, error)
+	// RecordDelivery saves the delivery, disabling the subscription after too many
+	// failures. It returns the subscription as it is now.
+	RecordDelivery(delivery Delivery) (*Subscription, error)
+	// Deliveries returns the log for the subscription, oldest first
+	Deliveries(subscriptionID string) ([]Delivery, error)
+	// Enable turns a disabled subscription back on
+	Enable(subscriptionID string) error
+}
+
+type memoryStore struct {
+	mu            sync.Mutex
+	deliveries    map[string][]Delivery
+	failures      map[string]int
+	subscriptions []Subscription
+}
+
+func (s *memoryStore) find(subscriptionID string) (*Subscription, error) {
+	for i := range s.subscriptions {
+		if s.subscriptions[i].ID == subscriptionID {
+			return &s.subscriptions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown subscription: %s", subscriptionID)
+}
+
+func (s *memoryStore) Subscriptions(restaurantID string) ([]Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs := make([]Subscription, 0)
+	for _, sub := range s.subscriptions {
+		if sub.RestaurantID == restaurantID {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+func (s *memoryStore) RecordDelivery(delivery Delivery) (*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, err := s.find(delivery.SubscriptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.deliveries[sub.ID] = append(s.deliveries[sub.ID], delivery)
+
+	if delivery.Delivered {
+		s.failures[sub.ID] = 0
+	} else {
+		s.failures[sub.ID]++
+		if s.failures[sub.ID] >= MaxConsecutiveFailures {
+			sub.Disabled = true
+		}
+	}
+
+	result := *sub
+	return &result, nil
+}
+
+func (s *memoryStore) Deliveries(subscriptionID string) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.find(subscriptionID); err != nil {
+		return nil, err
+	}
+
+	return append([]Delivery{}, s.deliveries[subscriptionID]...), nil
+}
+
+func (s *memoryStore) Enable(subscriptionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, err := s.find(subscriptionID)
+	if err != nil {
+		return err
+	}
+
+	sub.Disabled = false
+	s.failures[subscriptionID] = 0
+
+	return nil
+}
+
+// NewMemoryStore is a store for demos and tests - it's lost when the process stops
+func NewMemoryStore(subscriptions ...Subscription) Store {
+	return &memoryStore{
+		deliveries:    map[string][]Delivery{},
+		failures:      map[string]int{},
+		subscriptions: subscriptions,
+	}
+}
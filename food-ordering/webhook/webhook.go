@@ -0,0 +1,160 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package webhook sends signed events to partners, such as aggregators and POS
+// systems. Receivers should use Verify or VerifyRequest to check the signature.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Unique ID of the event - deliveries may be repeated, so use this to ignore duplicates
+	IDHeader = "Webhook-ID"
+	// In the format "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">"
+	SignatureHeader = "Webhook-Signature"
+
+	// How old a signature can be before it's rejected
+	DefaultTolerance = 5 * time.Minute
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrSignatureExpired = errors.New("webhook signature expired")
+)
+
+type Event struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"`
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+}
+
+// Subscription is a partner endpoint for a restaurant's events
+type Subscription struct {
+	Disabled     bool     `json:"disabled"` // Set after too many failed deliveries
+	EventTypes   []string `json:"eventTypes"`
+	ID           string   `json:"id"`
+	RestaurantID string   `json:"restaurantId"`
+	Secret       string   `json:"-"`
+	URL          string   `json:"url"`
+}
+
+// Wants is true if the subscription should be sent the event type
+func (s Subscription) Wants(eventType string) bool {
+	if s.Disabled {
+		return false
+	}
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is a record of sending an event to a subscription
+type Delivery struct {
+	Attempts       int       `json:"attempts"`
+	Delivered      bool      `json:"delivered"`
+	Error          string    `json:"error,omitempty"`
+	EventID        string    `json:"eventId"`
+	StatusCode     int       `json:"statusCode,omitempty"` // Of the last attempt
+	SubscriptionID string    `json:"subscriptionId"`
+	Time           time.Time `json:"time"`
+}
+
+func computeSignature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign generates the signature header for the body
+func Sign(secret string, body []byte, now time.Time) string {
+	timestamp := now.Unix()
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, computeSignature(secret, timestamp, body))
+}
+
+// Verify checks the signature header was made with the secret for this body, and
+// that it's no older than the tolerance
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var timestamp int64
+	signatures := make([]string, 0)
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrInvalidSignature
+		}
+
+		switch key {
+		case "t":
+			t, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return ErrInvalidSignature
+			}
+			timestamp = t
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	if timestamp == 0 || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	if age := now.Sub(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return ErrSignatureExpired
+	}
+
+	expected := computeSignature(secret, timestamp, body)
+	for _, s := range signatures {
+		if hmac.Equal([]byte(s), []byte(expected)) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+// VerifyRequest checks the request's signature, returning the body
+func VerifyRequest(r *http.Request, secret string, tolerance time.Duration) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading webhook body: %w", err)
+	}
+
+	if err := Verify(secret, r.Header.Get(SignatureHeader), body, tolerance, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"id":"1"}`)
+	header := Sign("secret", body, now)
+
+	tests := []struct {
+		name     string
+		secret   string
+		header   string
+		body     []byte
+		now      time.Time
+		expected error
+	}{
+		{name: "valid", secret: "secret", header: header, body: body, now: now},
+		{name: "wrong secret", secret: "other", header: header, body: body, now: now, expected: ErrInvalidSignature},
+		{name: "tampered body", secret: "secret", header: header, body: []byte(`{"id":"2"}`), now: now, expected: ErrInvalidSignature},
+		{name: "expired", secret: "secret", header: header, body: body, now: now.Add(time.Hour), expected: ErrSignatureExpired},
+		{name: "missing", secret: "secret", header: "", body: body, now: now, expected: ErrInvalidSignature},
+		{name: "malformed", secret: "secret", header: "t=abc,v1=def", body: body, now: now, expected: ErrInvalidSignature},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, Verify(test.secret, test.header, test.body, DefaultTolerance, test.now))
+		})
+	}
+}
+
+func newTestSender(store Store) *Sender {
+	s := NewSender(store)
+	s.InitialBackoff = time.Millisecond
+	return s
+}
+
+func TestSend(t *testing.T) {
+	var received Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := VerifyRequest(r, "secret", DefaultTolerance)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "event-1", r.Header.Get(IDHeader))
+		assert.NoError(t, json.Unmarshal(body, &received))
+	}))
+	defer server.Close()
+
+	store := NewMemoryStore(
+		Subscription{ID: "sub-1", RestaurantID: "r1", Secret: "secret", URL: server.URL},
+		Subscription{ID: "sub-2", RestaurantID: "r1", Secret: "secret", URL: server.URL, EventTypes: []string{"other"}},
+		Subscription{ID: "sub-3", RestaurantID: "r2", Secret: "secret", URL: server.URL},
+	)
+
+	deliveries, err := newTestSender(store).Send(context.Background(), "r1", Event{
+		Data: map[string]string{"hello": "world"},
+		ID:   "event-1",
+		Type: "order.status_changed",
+	})
+	assert.NoError(t, err)
+
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, "sub-1", deliveries[0].SubscriptionID)
+	assert.True(t, deliveries[0].Delivered)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, "event-1", received.ID)
+
+	log, err := store.Deliveries("sub-1")
+	assert.NoError(t, err)
+	assert.Len(t, log, 1)
+}
+
+func TestSendRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	store := NewMemoryStore(Subscription{ID: "sub-1", RestaurantID: "r1", Secret: "secret", URL: server.URL})
+
+	deliveries, err := newTestSender(store).Send(context.Background(), "r1", Event{ID: "event-1"})
+	assert.NoError(t, err)
+	assert.True(t, deliveries[0].Delivered)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+}
+
+func TestSendSkipsPreviousDeliveries(t *testing.T) {
+	received := make(map[string]int)
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received[r.URL.Path]++
+	}))
+	defer server.Close()
+
+	store := NewMemoryStore(
+		Subscription{ID: "sub-1", RestaurantID: "r1", Secret: "secret", URL: server.URL + "/1"},
+		Subscription{ID: "sub-2", RestaurantID: "r1", Secret: "secret", URL: server.URL + "/2"},
+	)
+
+	// The first go got as far as sub-1
+	sender := newTestSender(store)
+	sender.Previous = []Delivery{{Attempts: 1, Delivered: true, EventID: "event-1", SubscriptionID: "sub-1"}}
+
+	recorded := make([]Delivery, 0)
+	sender.OnDelivery = func(delivery Delivery) {
+		recorded = append(recorded, delivery)
+	}
+
+	deliveries, err := sender.Send(context.Background(), "r1", Event{ID: "event-1"})
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 2)
+	assert.Equal(t, map[string]int{"/2": 1}, received)
+	assert.Len(t, recorded, 1)
+	assert.Equal(t, "sub-2", recorded[0].SubscriptionID)
+}
+
+func TestSendDisablesFailingSubscription(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	store := NewMemoryStore(Subscription{ID: "sub-1", RestaurantID: "r1", Secret: "secret", URL: server.URL})
+	sender := newTestSender(store)
+	sender.Attempts = 2
+
+	for i := 0; i < MaxConsecutiveFailures; i++ {
+		deliveries, err := sender.Send(context.Background(), "r1", Event{ID: "event"})
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 1)
+		assert.False(t, deliveries[0].Delivered)
+		assert.Equal(t, http.StatusInternalServerError, deliveries[0].StatusCode)
+	}
+
+	subs, err := store.Subscriptions("r1")
+	assert.NoError(t, err)
+	assert.True(t, subs[0].Disabled)
+
+	// Nothing is sent to disabled subscriptions
+	deliveries, err := sender.Send(context.Background(), "r1", Event{ID: "event"})
+	assert.NoError(t, err)
+	assert.Empty(t, deliveries)
+
+	assert.NoError(t, store.Enable("sub-1"))
+	subs, err = store.Subscriptions("r1")
+	assert.NoError(t, err)
+	assert.False(t, subs[0].Disabled)
+}
@@ -17,29 +17,183 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"path/filepath"
 
+	"github.com/google/uuid"
+	"github.com/mrsimonemms/temporal-demos/codec"
 	foodordering "github.com/mrsimonemms/temporal-demos/food-ordering"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/httpapi"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/logging"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/payments"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/projection"
+	"github.com/mrsimonemms/temporal-demos/metrics"
+	"github.com/mrsimonemms/temporal-demos/tracing"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/worker"
 )
 
 func main() {
+	// Order details are encrypted before they're stored in the workflow history
+	dataConverter, err := codec.DataConverterFromEnv()
+	if err != nil {
+		log.Fatalln("Unable to create data converter", err)
+	}
+
+	// Traces are only exported if OTEL_TRACES_EXPORTER is set
+	shutdownTracing, err := tracing.Setup(context.Background(), "food-ordering-worker")
+	if err != nil {
+		log.Fatalln("Unable to configure tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	tracingInterceptor, err := tracing.NewInterceptor()
+	if err != nil {
+		log.Fatalln("Unable to create tracing interceptor", err)
+	}
+
+	// Workflow and activity logs are JSON, with emails and addresses redacted
+	logOpts, err := logging.OptionsFromEnv()
+	if err != nil {
+		log.Fatalln("Unable to configure logger", err)
+	}
+
+	// Prometheus scrapes the worker's metrics from /metrics
+	metricsHandler := metrics.NewHandler()
+	defer metricsHandler.Close()
+	metricsAddr := os.Getenv("METRICS_ADDRESS")
+	if metricsAddr == "" {
+		metricsAddr = ":9090"
+	}
+
+	go func() {
+		log.Println("Starting metrics server", "address", metricsAddr)
+		if err := metrics.ListenAndServe(metricsAddr, metricsHandler); err != nil {
+			log.Fatalln("Unable to start metrics server", err)
+		}
+	}()
+
 	// The client and worker are heavyweight objects that should be created once per process.
 	c, err := client.Dial(client.Options{
-		HostPort: os.Getenv("TEMPORAL_ADDRESS"),
+		DataConverter:  dataConverter,
+		HostPort:       os.Getenv("TEMPORAL_ADDRESS"),
+		Interceptors:   []interceptor.ClientInterceptor{tracingInterceptor},
+		Logger:         logging.NewLogger(*logOpts),
+		MetricsHandler: metricsHandler,
+		Namespace:      os.Getenv("TEMPORAL_NAMESPACE"),
 	})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
 	defer c.Close()
 
-	w := worker.New(c, foodordering.OrderFoodTaskQueue, worker.Options{})
+	// Each build of the worker is a version of the deployment when a build ID is set
+	versioning, err := foodordering.WorkerVersioningFromEnv()
+	if err != nil {
+		log.Fatalln("Unable to configure worker versioning", err)
+	}
+	if versioning.Enabled() {
+		log.Println("Worker versioning enabled", "deployment", versioning.DeploymentName, "buildId", versioning.BuildID)
+	}
+
+	// The client's interceptors trace the worker's workflows and activities too
+	w := worker.New(c, foodordering.OrderFoodTaskQueue, worker.Options{
+		DeploymentOptions: versioning.DeploymentOptions(),
+	})
+
+	w.RegisterWorkflowWithOptions(foodordering.OrderWorkflow, versioning.RegisterOptions("OrderWorkflow"))
+	w.RegisterWorkflowWithOptions(foodordering.CustomerWorkflow, versioning.RegisterOptions("CustomerWorkflow"))
+	w.RegisterWorkflowWithOptions(foodordering.DailySalesReportWorkflow, versioning.RegisterOptions("DailySalesReportWorkflow"))
+
+	opts := make([]foodordering.ActivityOption, 0)
+	if path := os.Getenv("DATABASE_PATH"); path != "" {
+		// Project the order changes into the reporting database, which also keeps
+		// the risk history and ledgers. It's SQLite, so every worker must run on
+		// the same machine and use the same path as the API - workers elsewhere
+		// would each have their own copy.
+		store, err := projection.Open(context.Background(), path)
+		if err != nil {
+			log.Fatalln("Unable to open database", err)
+		}
+		defer store.Close()
+
+		for code, balance := range foodordering.DemoGiftCards {
+			if err := store.IssueGiftCard(context.Background(), code, balance); err != nil {
+				log.Fatalln("Unable to issue gift card", err)
+			}
+		}
+
+		opts = append(opts,
+			foodordering.WithEventPublisher(store),
+			foodordering.WithGiftCardLedger(store.GiftCardLedger()),
+			foodordering.WithLoyaltyLedger(store.LoyaltyLedger()),
+			foodordering.WithReportDir(filepath.Join(filepath.Dir(path), "reports")),
+			foodordering.WithRiskHistory(store),
+			foodordering.WithSalesReporter(store),
+		)
+	}
+	if dir := os.Getenv("REPORT_DIR"); dir != "" {
+		opts = append(opts, foodordering.WithReportDir(dir))
+	}
+
+	// The daily sales report is only emailed if there's a mail server to send it
+	if addr := os.Getenv("SMTP_ADDRESS"); addr != "" {
+		from := os.Getenv("SMTP_FROM")
+		if from == "" {
+			from = "reports@food-ordering.local"
+		}
+		opts = append(opts, foodordering.WithSalesReportMailer(foodordering.NewSMTPMailer(addr, from)))
+	}
+
+	if url := os.Getenv("EVENTS_URL"); url != "" {
+		// Push the order changes to the API's event streams
+		secret := os.Getenv("EVENTS_SECRET")
+		if secret == "" {
+			log.Fatalln("EVENTS_SECRET must be set to push order changes")
+		}
+		opts = append(opts, foodordering.WithEventPublisher(httpapi.NewChangePublisher(url, secret)))
+	}
+
+	// Fake card provider - serves the 3-D Secure challenge pages and sends the
+	// results back to the callback
+	paymentsAddr := os.Getenv("PAYMENTS_ADDRESS")
+	if paymentsAddr == "" {
+		paymentsAddr = ":3001"
+	}
+	paymentsURL := os.Getenv("PAYMENTS_URL")
+	if paymentsURL == "" {
+		// Follow the address, so only the port needs changing to run another worker
+		_, port, err := net.SplitHostPort(paymentsAddr)
+		if err != nil {
+			log.Fatalln("Invalid payments address", err)
+		}
+		paymentsURL = "http://localhost:" + port
+	}
+	paymentsSecret := os.Getenv("PAYMENTS_SECRET")
+	if paymentsSecret == "" {
+		paymentsSecret = uuid.NewString()
+	}
+
+	provider := payments.NewFakeProvider(paymentsURL, paymentsURL+"/callback", paymentsSecret)
+	opts = append(opts, foodordering.WithPaymentProvider(provider))
+
+	mux := http.NewServeMux()
+	provider.Routes(mux)
+	mux.Handle("POST /callback", payments.CallbackHandler(c, paymentsSecret))
 
-	w.RegisterWorkflow(foodordering.OrderWorkflow)
+	go func() {
+		log.Println("Starting payments server", "address", paymentsAddr)
+		if err := http.ListenAndServe(paymentsAddr, tracing.Middleware(mux)); err != nil {
+			log.Fatalln("Unable to start payments server", err)
+		}
+	}()
 
-	activities, err := foodordering.NewActivities()
+	activities, err := foodordering.NewActivities(opts...)
 	if err != nil {
 		log.Fatalln("Unable to create activities", err)
 	}
@@ -17,22 +17,422 @@
 package foodordering
 
 import (
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/mrsimonemms/temporal-demos/food-ordering/webhook"
+	"github.com/mrsimonemms/temporal-demos/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
+// How long a watcher waits for a status change before being sent the current
+// state. Each long-poll adds to the order's history, so it's not too often.
+const longPollTimeout = 5 * time.Minute
+
+// Payments and refunds are retried a few times before ops are asked to step in
+var moneyRetryPolicy = temporal.RetryPolicy{
+	InitialInterval:    time.Second,
+	BackoffCoefficient: 2,
+	MaximumAttempts:    5,
+}
+
+// interventionFunc runs a money operation, holding the order for ops if it runs
+// out of retries. The returned event is set if ops did the operation by hand.
+type interventionFunc func(ctx workflow.Context, intervention Intervention, operation func(ctx workflow.Context) error) (*InterventionEvent, error)
+
 func OrderWorkflow(ctx workflow.Context, state OrderState) error {
 	logger := workflow.GetLogger(ctx)
 
 	// Force to be default state - payment not taken yet
-	state.Status = OrderStatusDefault
+	state.History = make([]StatusChange, 0)
+	state.SetStatus(OrderStatusDefault, workflow.Now(ctx))
+	defer recordOrderFinished(ctx, &state)
 
-	var cancel workflow.CancelFunc
-	ctx, cancel = workflow.WithCancel(ctx)
+	// Payments and discounts can only be applied by this workflow
+	state.Discounts = make([]Discount, 0)
+	state.Loyalty = LoyaltyState{}
+	state.Payments = make([]Payment, 0)
+	state.PaymentChallenges = make([]PaymentChallenge, 0)
+	state.Complaints = make([]Complaint, 0)
+	state.Interventions = make([]Intervention, 0)
+	state.Rating = nil
+	state.Risk = nil
+	state.Tips = make([]Tip, 0)
 
 	var a *activities
+	var restaurantConfig Restaurant
+
+	// Index the order so it can be listed without querying every workflow
+	upsertSearchAttributes := func(ctx workflow.Context) {
+		if err := workflow.UpsertTypedSearchAttributes(ctx, state.SearchAttributes(restaurantConfig.ID)...); err != nil {
+			logger.Warn("Error updating search attributes", "error", err)
+		}
+	}
+
+	// Each status change is published to the outbox and sent to partners in
+	// order, without holding up the order
+	outbox := make([]OrderChange, 0)
+	webhookDeliveries := make([]webhook.Delivery, 0)
+	outboxCtx, cancelOutbox := workflow.NewDisconnectedContext(ctx)
+	workflow.Go(outboxCtx, func(ctx workflow.Context) {
+		ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			StartToCloseTimeout: time.Minute * 5,
+			HeartbeatTimeout:    time.Minute,
+		})
+
+		for {
+			if err := workflow.Await(ctx, func() bool {
+				return len(outbox) > 0
+			}); err != nil {
+				return
+			}
+			change := outbox[0]
+
+			if err := workflow.ExecuteActivity(
+				// Don't hold up the rest of the outbox forever if the read model is down
+				workflow.WithRetryPolicy(ctx, temporal.RetryPolicy{MaximumAttempts: 3}),
+				a.PublishOrderChange,
+				change,
+			).Get(ctx, nil); err != nil {
+				logger.Error("Error publishing order change", "error", err, "eventId", change.ID)
+			}
+
+			var deliveries []webhook.Delivery
+			if err := workflow.ExecuteActivity(
+				// The activity retries each endpoint itself
+				workflow.WithRetryPolicy(ctx, temporal.RetryPolicy{MaximumAttempts: 3}),
+				a.SendWebhook,
+				WebhookRequest{
+					Event:        change.WebhookEvent(),
+					RestaurantID: change.RestaurantID,
+				},
+			).Get(ctx, &deliveries); err != nil {
+				logger.Error("Error sending webhook", "error", err, "eventId", change.ID)
+			}
+			webhookDeliveries = append(webhookDeliveries, deliveries...)
+
+			outbox = outbox[1:]
+		}
+	})
+	defer func() {
+		// Make sure the outbox and partners hear how the order finished
+		if err := workflow.Await(outboxCtx, func() bool {
+			return len(outbox) == 0
+		}); err != nil {
+			logger.Error("Error waiting for outbox", "error", err)
+		}
+
+		// Nothing's left to publish, so stop waiting for more
+		cancelOutbox()
+	}()
+
+	setStatus := func(ctx workflow.Context, status OrderStatus) {
+		recordStatusChange(ctx, &state)
+		state.SetStatus(status, workflow.Now(ctx))
+		tracing.WorkflowEvent(ctx, "OrderStatusChanged", attribute.String("order.status", string(status)))
+		upsertSearchAttributes(ctx)
+		outbox = append(outbox, NewOrderChange(workflow.GetInfo(ctx).WorkflowExecution.ID, restaurantConfig.ID, state))
+	}
+
+	// Refunds made after the status change still need to reach the outbox
+	publishRefund := func(ctx workflow.Context, refundedBefore int) {
+		if state.RefundedInPence() == refundedBefore {
+			return
+		}
+		outbox = append(outbox, NewRefundChange(workflow.GetInfo(ctx).WorkflowExecution.ID, restaurantConfig.ID, state, workflow.Now(ctx)))
+	}
+
+	// Failed payments and refunds move the order to NEEDS_ATTENTION until ops step in
+	intervene := func(ctx workflow.Context, intervention Intervention, operation func(ctx workflow.Context) error) (*InterventionEvent, error) {
+		ctx = workflow.WithRetryPolicy(ctx, moneyRetryPolicy)
+
+		index := -1
+		for {
+			var action *InterventionEvent
+
+			err := operation(ctx)
+			switch {
+			case temporal.IsCanceledError(err):
+				return nil, err
+			case err == nil || errors.Is(err, ErrPaymentDeclined):
+				// Finished, one way or the other - the customer's declined payment isn't for ops
+				if index < 0 {
+					return nil, err
+				}
+			default:
+				logger.Error("Money operation failed", "operation", intervention.Operation, "amountInPence", intervention.AmountInPence, "error", err)
+
+				if index < 0 {
+					intervention.ID = strconv.Itoa(len(state.Interventions) + 1)
+					intervention.Events = make([]InterventionEvent, 0)
+					intervention.PreviousStatus = state.Status
+					intervention.RaisedAt = workflow.Now(ctx)
+					for _, i := range state.Interventions {
+						if i.IsOpen() {
+							// Already waiting on ops for something else
+							intervention.PreviousStatus = i.PreviousStatus
+							break
+						}
+					}
+
+					state.Interventions = append(state.Interventions, intervention)
+					index = len(state.Interventions) - 1
+				}
+
+				state.Interventions[index].Events = append(state.Interventions[index].Events, InterventionEvent{
+					Action: InterventionFailed,
+					Error:  err.Error(),
+					Time:   workflow.Now(ctx),
+				})
+				if state.Status != OrderStatusNeedsAttention {
+					setStatus(ctx, OrderStatusNeedsAttention)
+				}
+
+				logger.Info("Waiting for ops", "interventionId", state.Interventions[index].ID)
+				if err := workflow.Await(ctx, func() bool {
+					return !state.Interventions[index].AwaitingAction()
+				}); err != nil {
+					return nil, err
+				}
+
+				events := state.Interventions[index].Events
+				action = &events[len(events)-1]
+				if action.Action == InterventionRetry {
+					continue
+				}
+				err = nil
+			}
+
+			now := workflow.Now(ctx)
+			state.Interventions[index].ResolvedAt = &now
+			if state.OpenInterventions() == 0 {
+				setStatus(ctx, state.Interventions[index].PreviousStatus)
+			}
+
+			switch {
+			case action == nil:
+				// Finished when retried
+				return nil, err
+			case action.Action == InterventionCancel:
+				return nil, ErrInterventionCancelled
+			default:
+				return action, nil
+			}
+		}
+	}
+
+	// Complaints may be partly refunded if one of the refunds fails
+	approve := func(ctx workflow.Context, complaintID string) error {
+		defer publishRefund(ctx, state.RefundedInPence())
+		return approveComplaint(ctx, &state, complaintID, intervene)
+	}
+
+	// The card provider sends the results of payment challenges as they're finished
+	paymentCallbacks := map[string]PaymentCallback{}
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		ch := workflow.GetSignalChannel(ctx, Signals.PAYMENT_CALLBACK)
+		for {
+			var callback PaymentCallback
+			if more := ch.Receive(ctx, &callback); !more {
+				return
+			}
+			logger.Info("Payment callback received", "chargeId", callback.ChargeID, "status", callback.Status)
+			paymentCallbacks[callback.ChargeID] = callback
+		}
+	})
+
+	// Takes the payment, waiting for the customer to authorise it if their bank asks
+	charges := 0
+	takePayment := func(ctx workflow.Context, req PaymentRequest) (_ *Payment, err error) {
+		defer func() {
+			recordPayment(ctx, PaymentMethodCard, req.AmountInPence, err)
+		}()
+		req.OrderID = workflow.GetInfo(ctx).WorkflowExecution.ID
+		req.Reference = fmt.Sprintf("%s/charge/%d", req.OrderID, charges)
+		charges++
+
+		var payment Payment
+		if err := workflow.ExecuteActivity(ctx, a.TakePayment, req).Get(ctx, &payment); err != nil {
+			var appErr *temporal.ApplicationError
+			if errors.As(err, &appErr) && appErr.Type() == PaymentDeclinedErrorType {
+				return nil, fmt.Errorf("%w: %w", ErrPaymentDeclined, err)
+			}
+			return nil, err
+		}
+
+		challenge := payment.Challenge
+		if challenge == nil {
+			return &payment, nil
+		}
+
+		state.PaymentChallenges = append(state.PaymentChallenges, *challenge)
+		defer func() {
+			state.PaymentChallenges = slices.DeleteFunc(state.PaymentChallenges, func(c PaymentChallenge) bool {
+				return c.ChargeID == challenge.ChargeID
+			})
+		}()
+
+		logger.Info("Waiting for payment to be authorised", "chargeId", challenge.ChargeID, "timeout", restaurantConfig.PaymentChallengeTimeout)
+		finished, err := workflow.AwaitWithTimeout(ctx, restaurantConfig.PaymentChallengeTimeout, func() bool {
+			_, ok := paymentCallbacks[challenge.ChargeID]
+			return ok
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if !finished {
+			// Stop the customer authorising a payment for an order that's given up on it
+			if err := workflow.ExecuteActivity(ctx, a.CancelPayment, challenge.ChargeID).Get(ctx, nil); err != nil {
+				logger.Error("Error cancelling payment", "error", err, "chargeId", challenge.ChargeID)
+			}
+			return nil, fmt.Errorf("%w: not authorised in time", ErrPaymentDeclined)
+		}
+
+		if callback := paymentCallbacks[challenge.ChargeID]; callback.Status != PaymentStatusSucceeded {
+			return nil, fmt.Errorf("%w: %s", ErrPaymentDeclined, callback.Reason)
+		}
+
+		payment.Challenge = nil
+		return &payment, nil
+	}
+
+	// Tips are charged separately from the food, after it's been paid for
+	captureTip := func(ctx workflow.Context, tipID string) error {
+		payment, err := takePayment(workflow.WithRetryPolicy(ctx, moneyRetryPolicy), PaymentRequest{
+			AmountInPence: state.GetTip(tipID).AmountInPence,
+			Payer:         state.Payer(),
+		})
+
+		// More tips may have been added whilst waiting
+		tip := state.GetTip(tipID)
+		if err != nil {
+			tip.Status = TipStatusFailed
+			return err
+		}
+
+		payment.Method = PaymentMethodCard
+		tip.Payment = payment
+		tip.Status = TipStatusPaid
+
+		return nil
+	}
+
+	// Pays for the order with the customer's tenders - gift cards and wallets first,
+	// then the card for whatever's left
+	takeTenders := func(ctx workflow.Context, total int, payer string) error {
+		remaining := total
+		card := false
+		for i, method := range state.Tenders() {
+			if !method.IsStoredValue() {
+				card = true
+				continue
+			}
+			if remaining == 0 {
+				break
+			}
+
+			amount := remaining
+			if method.AmountInPence > 0 {
+				amount = min(amount, method.AmountInPence)
+			}
+
+			req := GiftCardRequest{
+				Account:       method.Account(state.CustomerID),
+				AmountInPence: amount,
+				Reference:     fmt.Sprintf("%s/tender/%d", workflow.GetInfo(ctx).WorkflowExecution.ID, i),
+			}
+			var debited int
+			if err := workflow.ExecuteActivity(ctx, a.DebitGiftCard, req).Get(ctx, &debited); err != nil {
+				recordPayment(ctx, method.Type, amount, err)
+				var appErr *temporal.ApplicationError
+				if errors.As(err, &appErr) && appErr.Type() == UnknownGiftCardErrorType {
+					return fmt.Errorf("%w: %w", ErrPaymentDeclined, err)
+				}
+				return err
+			}
+			if debited == 0 {
+				continue
+			}
+			recordPayment(ctx, method.Type, debited, nil)
+
+			state.Payments = append(state.Payments, Payment{
+				Account:       req.Account,
+				AmountInPence: debited,
+				Method:        method.Type,
+				Payer:         payer,
+				TransactionID: req.Reference,
+			})
+			remaining -= debited
+		}
+
+		if remaining == 0 {
+			return nil
+		}
+		if !card {
+			return fmt.Errorf("%w: not enough left on the gift card", ErrPaymentDeclined)
+		}
+
+		var payment *Payment
+		manual, err := intervene(ctx, Intervention{
+			AmountInPence: remaining,
+			Operation:     MoneyOperationPayment,
+		}, func(ctx workflow.Context) (err error) {
+			payment, err = takePayment(ctx, PaymentRequest{
+				AmountInPence: remaining,
+				Payer:         payer,
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if manual != nil {
+			// Taken over the phone by ops
+			payment = &Payment{
+				AmountInPence: remaining,
+				Payer:         payer,
+				TransactionID: manual.Reference,
+			}
+		}
+		payment.Method = PaymentMethodCard
+		state.Payments = append(state.Payments, *payment)
+
+		return nil
+	}
+
+	if err := workflow.SetQueryHandler(ctx, Queries.GET_WEBHOOK_DELIVERIES, func() ([]webhook.Delivery, error) {
+		return webhookDeliveries, nil
+	}); err != nil {
+		logger.Error("SetQueryHandler failed.", "Error", err, "query", Queries.GET_WEBHOOK_DELIVERIES)
+		return err
+	}
+
+	// Group orders get a code the organiser shares so others can join the basket
+	if state.Group != nil {
+		if state.Group.Organiser == "" {
+			logger.Error("Group order has no organiser")
+			return fmt.Errorf("group order has no organiser")
+		}
+
+		if err := workflow.SideEffect(ctx, func(ctx workflow.Context) any {
+			return generateJoinCode()
+		}).Get(&state.Group.JoinCode); err != nil {
+			logger.Error("Error generating join code", "error", err)
+			return fmt.Errorf("error generating join code: %w", err)
+		}
+
+		state.Group.Locked = false
+		state.Group.Participants = []Participant{{Name: state.Group.Organiser}}
+	}
 
 	// Query to return status of basket
 	if err := workflow.SetQueryHandler(ctx, Queries.GET_STATUS, func(_ []byte) (OrderState, error) {
@@ -43,81 +443,1351 @@ func OrderWorkflow(ctx workflow.Context, state OrderState) error {
 		return err
 	}
 
-	updateInProgress := false
-	// Update the order status - this will come from the restaurant
+	// Status changes still refunding or notifying - the order can't finish until they're done
+	updatesInProgress := 0
+	checkedOut := false
+
+	// Set when the order's rejected or cancelled - the refund's left to the main
+	// loop, so the update isn't held up if ops have to step in
+	refundPending := false
+
+	// Checks the basket can be changed by the given owner
+	validateBasketChange := func(owner string) error {
+		if checkedOut || state.Status != OrderStatusDefault {
+			return fmt.Errorf("basket is locked")
+		}
+
+		if state.Group != nil && state.Group.GetParticipant(owner) == nil {
+			return fmt.Errorf("%q has not joined the group order", owner)
+		}
+
+		return nil
+	}
+
+	// Group orders store the participant's name as they joined
+	itemOwner := func(owner string) string {
+		if state.Group == nil {
+			return ""
+		}
+		return state.Group.GetParticipant(owner).Name
+	}
+
+	// Add an item to the basket - this will come from the customer
 	if err := workflow.SetUpdateHandlerWithOptions(
 		ctx,
-		Updates.UPDATE_STATUS,
-		func(ctx workflow.Context, input string) error {
-			updateInProgress = true
-			status, _ := ParseOrderStatus(input)
+		Updates.ADD_ITEM,
+		func(ctx workflow.Context, item OrderProduct) error {
+			item.Owner = itemOwner(item.Owner)
 
-			logger.Info("Updating order status", "status", status)
-			state.Status = status
+			logger.Info("Adding item to basket", "productId", item.ProductID, "quantity", item.Quantity, "owner", item.Owner)
+			state.AddItem(item)
+
+			return nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: rejectUpdateWith(func(ctx workflow.Context, item OrderProduct) error {
+				if err := validateBasketChange(item.Owner); err != nil {
+					logger.Debug("Basket cannot be changed", "error", err)
+					return err
+				}
+
+				return item.Validate()
+			}),
+		},
+	); err != nil {
+		logger.Error("SetUpdateHandlerWithOptions failed.", "Error", err, "update", Updates.ADD_ITEM)
+		return err
+	}
+
+	// Remove an item from the basket - this will come from the customer
+	if err := workflow.SetUpdateHandlerWithOptions(
+		ctx,
+		Updates.REMOVE_ITEM,
+		func(ctx workflow.Context, item OrderProduct) error {
+			item.Owner = itemOwner(item.Owner)
+
+			logger.Info("Removing item from basket", "productId", item.ProductID, "quantity", item.Quantity, "owner", item.Owner)
+			state.RemoveItem(item)
+
+			return nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: rejectUpdateWith(func(ctx workflow.Context, item OrderProduct) error {
+				if err := validateBasketChange(item.Owner); err != nil {
+					logger.Debug("Basket cannot be changed", "error", err)
+					return err
+				}
+
+				return item.Validate()
+			}),
+		},
+	); err != nil {
+		logger.Error("SetUpdateHandlerWithOptions failed.", "Error", err, "update", Updates.REMOVE_ITEM)
+		return err
+	}
+
+	// Join a group order - this will come from a participant with the join code
+	if err := workflow.SetUpdateHandlerWithOptions(
+		ctx,
+		Updates.JOIN_GROUP,
+		func(ctx workflow.Context, req JoinRequest) error {
+			logger.Info("Participant joined group order", "participant", req.Name)
+			state.Group.Join(req)
+
+			return nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: rejectUpdateWith(func(ctx workflow.Context, req JoinRequest) error {
+				if state.Group == nil {
+					return fmt.Errorf("not a group order")
+				}
+				if checkedOut {
+					return fmt.Errorf("basket is locked")
+				}
+
+				return state.Group.ValidateJoin(req)
+			}),
+		},
+	); err != nil {
+		logger.Error("SetUpdateHandlerWithOptions failed.", "Error", err, "update", Updates.JOIN_GROUP)
+		return err
+	}
+
+	// Split payments - each participant pays for their own items
+	paymentWindowOpen := false
+	paymentsInProgress := 0
+	if err := workflow.SetUpdateHandlerWithOptions(
+		ctx,
+		Updates.PAY_SHARE,
+		func(ctx workflow.Context, name string) error {
+			paymentsInProgress++
+			defer func() {
+				paymentsInProgress--
+			}()
+
+			participant := state.Group.GetParticipant(name)
+			logger.Info("Participant paying share", "participant", participant.Name)
 
 			ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
 				StartToCloseTimeout: time.Minute,
 			})
 
-			if state.Status == OrderStatusRejected {
-				logger.Info("Order cancelled")
-				defer func() {
-					cancel()
-				}()
-
-				if err := workflow.ExecuteActivity(ctx, a.RefundPayment).Get(ctx, nil); err != nil {
-					logger.Error("Error refunding payment", "error", err)
-					return fmt.Errorf("error refunding payment: %w", err)
-				}
+			payment, err := takePayment(ctx, PaymentRequest{
+				AmountInPence: state.TotalFor(participant.Name),
+				Payer:         participant.Name,
+			})
+			if err != nil {
+				logger.Error("Error taking payment", "error", err)
+				return fmt.Errorf("error taking payment: %w", err)
 			}
 
-			if err := workflow.ExecuteActivity(ctx, a.SendTextMessage, state).Get(ctx, nil); err != nil {
-				logger.Error("Error notifying of status change", "error", err)
-				return fmt.Errorf("error notifying of status change: %w", err)
-			}
-			updateInProgress = false
+			state.Payments = append(state.Payments, *payment)
+			state.Group.GetParticipant(name).Paid = true
 
 			return nil
 		},
 		workflow.UpdateHandlerOptions{
-			Validator: func(ctx workflow.Context, input string) error {
-				if _, err := ParseOrderStatus(input); err != nil {
-					logger.Debug("Invalid status", "input", input)
-					return err
+			Validator: rejectUpdateWith(func(ctx workflow.Context, name string) error {
+				if state.Group == nil || !state.Group.SplitPayment {
+					return fmt.Errorf("not a split payment order")
+				}
+				if !paymentWindowOpen {
+					return fmt.Errorf("order is not waiting for payment")
+				}
+
+				participant := state.Group.GetParticipant(name)
+				if participant == nil {
+					return fmt.Errorf("%q has not joined the group order", name)
+				}
+				if participant.Paid {
+					return fmt.Errorf("%q has already paid", name)
+				}
+				if state.TotalFor(name) == 0 {
+					return fmt.Errorf("%q has nothing to pay for", name)
 				}
 
 				return nil
-			},
+			}),
 		},
 	); err != nil {
-		logger.Error("SetUpdateHandlerWithOptions failed.", "Error", err, "update", Updates.UPDATE_STATUS)
+		logger.Error("SetUpdateHandlerWithOptions failed.", "Error", err, "update", Updates.PAY_SHARE)
 		return err
 	}
 
-	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
-		StartToCloseTimeout: time.Minute,
-	})
+	// Long-poll for the next status change - returns the current state straight
+	// away if there's been a change since the given event
+	if err := workflow.SetUpdateHandlerWithOptions(
+		ctx,
+		Updates.WAIT_FOR_CHANGE,
+		func(ctx workflow.Context, afterEventID int) (OrderEvent, error) {
+			if _, err := workflow.AwaitWithTimeout(ctx, longPollTimeout, func() bool {
+				return state.LastEventID() > afterEventID
+			}); err != nil {
+				return OrderEvent{}, err
+			}
 
-	if err := workflow.ExecuteActivity(ctx, a.TakePayment).Get(ctx, nil); err != nil {
-		logger.Error("Error taking payment", "error", err)
-		return fmt.Errorf("error taking payment: %w", err)
+			return OrderEvent{
+				EventID: state.LastEventID(),
+				State:   state,
+			}, nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: rejectUpdateWith(func(ctx workflow.Context, afterEventID int) error {
+				if afterEventID < 0 {
+					return fmt.Errorf("event ID cannot be negative")
+				}
+				return nil
+			}),
+			// Watchers are told the workflow has finished by the update failing
+			UnfinishedPolicy: workflow.HandlerUnfinishedPolicyAbandon,
+		},
+	); err != nil {
+		logger.Error("SetUpdateHandlerWithOptions failed.", "Error", err, "update", Updates.WAIT_FOR_CHANGE)
+		return err
 	}
 
-	// Set order status to pending
-	state.Status = OrderStatusPending
+	// Rate the order - this will come from the customer
+	feedbackWindowOpen := false
+	if err := workflow.SetUpdateHandlerWithOptions(
+		ctx,
+		Updates.RATE,
+		func(ctx workflow.Context, rating Rating) error {
+			logger.Info("Order rated", "stars", rating.Stars)
+			rating.RatedAt = workflow.Now(ctx)
+			state.Rating = &rating
 
-	if err := workflow.ExecuteActivity(ctx, a.SendTextMessage, state).Get(ctx, nil); err != nil {
-		logger.Error("Error notifying of status change", "error", err)
-		return fmt.Errorf("error notifying of status change: %w", err)
+			return nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: rejectUpdateWith(func(ctx workflow.Context, rating Rating) error {
+				if !feedbackWindowOpen {
+					return fmt.Errorf("order cannot be rated")
+				}
+
+				return rating.Validate()
+			}),
+		},
+	); err != nil {
+		logger.Error("SetUpdateHandlerWithOptions failed.", "Error", err, "update", Updates.RATE)
+		return err
 	}
 
-	// Wait for the status to be completed
-	if err := workflow.Await(ctx, func() bool {
-		return state.Status == OrderStatusCompleted && !updateInProgress
-	}); err != nil {
-		logger.Error("Error waiting for workflow to complete", "error", err)
-		return fmt.Errorf("error waiting for workflow to complete: %w", err)
+	// Complain about items - this will come from the customer
+	if err := workflow.SetUpdateHandlerWithOptions(
+		ctx,
+		Updates.COMPLAINT,
+		func(ctx workflow.Context, req ComplaintRequest) (Complaint, error) {
+			complaint := state.NewComplaint(req, workflow.Now(ctx))
+			state.Complaints = append(state.Complaints, complaint)
+
+			logger.Info("Complaint received", "complaintId", complaint.ComplaintID, "refundInPence", complaint.RefundInPence)
+
+			if complaint.RefundInPence > restaurantConfig.ComplaintAutoRefund {
+				// Needs the restaurant to approve it
+				return complaint, nil
+			}
+
+			ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+				StartToCloseTimeout: time.Minute,
+			})
+
+			if err := approve(ctx, complaint.ComplaintID); err != nil {
+				logger.Error("Error approving complaint", "error", err)
+				return complaint, fmt.Errorf("error approving complaint: %w", err)
+			}
+
+			return *state.GetComplaint(complaint.ComplaintID), nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: rejectUpdateWith(func(ctx workflow.Context, req ComplaintRequest) error {
+				if !feedbackWindowOpen {
+					return fmt.Errorf("order cannot be complained about")
+				}
+
+				return state.ValidateComplaint(req)
+			}),
+		},
+	); err != nil {
+		logger.Error("SetUpdateHandlerWithOptions failed.", "Error", err, "update", Updates.COMPLAINT)
+		return err
 	}
 
-	return nil
+	// Tip the kitchen and courier - this will come from the customer
+	tipsAtCheckout := true
+	tipWindowOpen := false
+	if err := workflow.SetUpdateHandlerWithOptions(
+		ctx,
+		Updates.TIP,
+		func(ctx workflow.Context, req TipRequest) (Tip, error) {
+			tip := state.NewTip(req, restaurantConfig.Tips, workflow.Now(ctx))
+			state.Tips = append(state.Tips, tip)
+
+			logger.Info("Tip received", "tipId", tip.TipID, "amountInPence", tip.AmountInPence)
+
+			if tipsAtCheckout {
+				// Charged once the order's paid for
+				return tip, nil
+			}
+
+			ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+				StartToCloseTimeout: time.Minute,
+			})
+
+			if err := captureTip(ctx, tip.TipID); err != nil {
+				logger.Error("Error charging tip", "error", err, "tipId", tip.TipID)
+				return *state.GetTip(tip.TipID), fmt.Errorf("error charging tip: %w", err)
+			}
+
+			tip = *state.GetTip(tip.TipID)
+			outbox = append(outbox, NewTipChange(workflow.GetInfo(ctx).WorkflowExecution.ID, restaurantConfig.ID, state, tip))
+
+			return tip, nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: rejectUpdateWith(func(ctx workflow.Context, req TipRequest) error {
+				if !tipsAtCheckout && !tipWindowOpen {
+					return fmt.Errorf("order cannot be tipped")
+				}
+
+				return req.Validate(restaurantConfig.Tips)
+			}),
+		},
+	); err != nil {
+		logger.Error("SetUpdateHandlerWithOptions failed.", "Error", err, "update", Updates.TIP)
+		return err
+	}
+
+	// Approve or decline a complaint - this will come from the restaurant
+	if err := workflow.SetUpdateHandlerWithOptions(
+		ctx,
+		Updates.RESOLVE_COMPLAINT,
+		func(ctx workflow.Context, resolution ComplaintResolution) error {
+			logger.Info("Resolving complaint", "complaintId", resolution.ComplaintID, "approved", resolution.Approved)
+
+			if !resolution.Approved {
+				state.GetComplaint(resolution.ComplaintID).Status = ComplaintStatusDeclined
+				return nil
+			}
+
+			ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+				StartToCloseTimeout: time.Minute,
+			})
+
+			if err := approve(ctx, resolution.ComplaintID); err != nil {
+				logger.Error("Error approving complaint", "error", err)
+				return fmt.Errorf("error approving complaint: %w", err)
+			}
+
+			return nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: rejectUpdateWith(func(ctx workflow.Context, resolution ComplaintResolution) error {
+				if !feedbackWindowOpen {
+					return fmt.Errorf("feedback window has closed")
+				}
+
+				complaint := state.GetComplaint(resolution.ComplaintID)
+				if complaint == nil {
+					return fmt.Errorf("unknown complaint: %s", resolution.ComplaintID)
+				}
+				if complaint.Status != ComplaintStatusPending {
+					return fmt.Errorf("complaint already resolved")
+				}
+
+				return nil
+			}),
+		},
+	); err != nil {
+		logger.Error("SetUpdateHandlerWithOptions failed.", "Error", err, "update", Updates.RESOLVE_COMPLAINT)
+		return err
+	}
+
+	// Retry, resolve by hand or cancel a failed money operation - this will come from ops
+	if err := workflow.SetUpdateHandlerWithOptions(
+		ctx,
+		Updates.RESOLVE_INTERVENTION,
+		func(ctx workflow.Context, req InterventionRequest) error {
+			logger.Info("Intervention actioned", "interventionId", req.InterventionID, "action", req.Action, "operator", req.Operator)
+
+			intervention := state.GetIntervention(req.InterventionID)
+			intervention.Events = append(intervention.Events, InterventionEvent{
+				Action:    req.Action,
+				Notes:     req.Notes,
+				Operator:  req.Operator,
+				Reference: req.Reference,
+				Time:      workflow.Now(ctx),
+			})
+
+			return nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: rejectUpdateWith(func(ctx workflow.Context, req InterventionRequest) error {
+				return state.ValidateIntervention(req)
+			}),
+		},
+	); err != nil {
+		logger.Error("SetUpdateHandlerWithOptions failed.", "Error", err, "update", Updates.RESOLVE_INTERVENTION)
+		return err
+	}
+
+	// Approve or decline an order held by the fraud checks - this will come from an operator
+	if err := workflow.SetUpdateHandlerWithOptions(
+		ctx,
+		Updates.REVIEW_RISK,
+		func(ctx workflow.Context, review RiskReview) error {
+			logger.Info("Order reviewed", "approved", review.Approved, "reviewer", review.Reviewer)
+			review.ReviewedAt = workflow.Now(ctx)
+			state.Risk.Review = &review
+
+			return nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: rejectUpdateWith(func(ctx workflow.Context, review RiskReview) error {
+				if state.Status != OrderStatusReview || state.Risk.Review != nil {
+					return fmt.Errorf("order is not waiting for review")
+				}
+
+				return review.Validate()
+			}),
+		},
+	); err != nil {
+		logger.Error("SetUpdateHandlerWithOptions failed.", "Error", err, "update", Updates.REVIEW_RISK)
+		return err
+	}
+
+	// Lock the basket and go to payment - this will come from the customer or group organiser
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		workflow.GetSignalChannel(ctx, Signals.CHECKOUT).Receive(ctx, nil)
+		logger.Info("Checkout received")
+		checkedOut = true
+	})
+
+	// Update the order status - this will come from the restaurant
+	if err := workflow.SetUpdateHandlerWithOptions(
+		ctx,
+		Updates.UPDATE_STATUS,
+		func(ctx workflow.Context, input string) error {
+			updatesInProgress++
+			defer func() {
+				updatesInProgress--
+			}()
+			status, _ := ParseOrderStatus(input)
+
+			if status == OrderStatusRejected {
+				// The main loop refunds the order and finishes it
+				logger.Info("Order rejected")
+				setStatus(ctx, status)
+				refundPending = true
+
+				return nil
+			}
+
+			logger.Info("Updating order status", "status", status)
+			setStatus(ctx, status)
+
+			ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+				StartToCloseTimeout: time.Minute,
+			})
+
+			if state.Status == OrderStatusAccepted {
+				ticket := NewTicket(workflow.GetInfo(ctx).WorkflowExecution.ID, state, restaurantConfig, workflow.Now(ctx))
+
+				// A broken printer shouldn't hold up the order - it's still on the kitchen screens
+				if err := workflow.ExecuteActivity(
+					workflow.WithRetryPolicy(ctx, temporal.RetryPolicy{MaximumAttempts: 3}),
+					a.PrintTicket,
+					ticket,
+				).Get(ctx, nil); err != nil {
+					logger.Error("Error printing ticket", "error", err)
+				}
+			}
+
+			if err := workflow.ExecuteActivity(ctx, a.SendTextMessage, state).Get(ctx, nil); err != nil {
+				logger.Error("Error notifying of status change", "error", err)
+				return fmt.Errorf("error notifying of status change: %w", err)
+			}
+
+			return nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: rejectUpdateWith(func(ctx workflow.Context, input string) error {
+				if state.Status.IsTerminal() {
+					// A rejection may still be refunding - don't let the order carry on
+					logger.Debug("Order already finished", "status", state.Status)
+					return fmt.Errorf("order is already finished")
+				}
+
+				status, err := ParseOrderStatus(input)
+				if err != nil {
+					logger.Debug("Invalid status", "input", input)
+					return err
+				}
+
+				// Anything else, such as cancelling, is done by the workflow so the
+				// order's refunded and finished properly
+				if err := ValidateRestaurantTransition(state.Status, status); err != nil {
+					logger.Debug("Invalid status change", "status", state.Status, "requested", status)
+					return err
+				}
+
+				return nil
+			}),
+		},
+	); err != nil {
+		logger.Error("SetUpdateHandlerWithOptions failed.", "Error", err, "update", Updates.UPDATE_STATUS)
+		return err
+	}
+
+	// Cancel a scheduled order - this will come from the customer
+	if err := workflow.SetUpdateHandlerWithOptions(
+		ctx,
+		Updates.CANCEL,
+		func(ctx workflow.Context) error {
+			updatesInProgress++
+			defer func() {
+				updatesInProgress--
+			}()
+
+			// The main loop refunds the order and finishes it
+			logger.Info("Customer cancelled order")
+			setStatus(ctx, OrderStatusCancelled)
+			refundPending = true
+
+			return nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: rejectUpdate(func(ctx workflow.Context) error {
+				// Customers can cancel freely until the kitchen has the order
+				if state.Status != OrderStatusScheduled {
+					logger.Debug("Order cannot be cancelled", "status", state.Status)
+					return fmt.Errorf("order cannot be cancelled once released to the restaurant")
+				}
+
+				return nil
+			}),
+		},
+	); err != nil {
+		logger.Error("SetUpdateHandlerWithOptions failed.", "Error", err, "update", Updates.CANCEL)
+		return err
+	}
+
+	if err := workflow.ExecuteLocalActivity(
+		workflow.WithLocalActivityOptions(ctx, workflow.LocalActivityOptions{
+			StartToCloseTimeout: time.Second * 10,
+		}),
+		a.GetRestaurant,
+	).Get(ctx, &restaurantConfig); err != nil {
+		logger.Error("Error getting restaurant", "error", err)
+		return fmt.Errorf("error getting restaurant: %w", err)
+	}
+	upsertSearchAttributes(ctx)
+
+	// Check we can make the order for the requested time
+	if state.FulfilmentTime != nil {
+		if err := restaurantConfig.ValidateFulfilmentTime(*state.FulfilmentTime, workflow.Now(ctx)); err != nil {
+			logger.Error("Invalid fulfilment time", "error", err)
+			return fmt.Errorf("invalid fulfilment time: %w", err)
+		}
+	}
+
+	if state.RedeemPoints < 0 {
+		logger.Error("Invalid loyalty points", "points", state.RedeemPoints)
+		return fmt.Errorf("loyalty points to redeem cannot be negative")
+	}
+	if state.RedeemPoints > 0 && (state.CustomerID == "" || state.Group != nil) {
+		logger.Error("Loyalty points cannot be redeemed on this order")
+		return fmt.Errorf("loyalty points can only be redeemed by a customer on their own order")
+	}
+	if state.RedeemPoints > 0 {
+		// Turn the order away now, rather than once the customer's paying for it
+		var balance int
+		if err := workflow.ExecuteActivity(
+			workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+				StartToCloseTimeout: time.Minute,
+			}),
+			a.GetLoyaltyBalance,
+			state.CustomerID,
+		).Get(ctx, &balance); err != nil {
+			logger.Error("Error getting loyalty balance", "error", err)
+			return fmt.Errorf("error getting loyalty balance: %w", err)
+		}
+		if balance < state.RedeemPoints {
+			logger.Error("Not enough loyalty points", "points", state.RedeemPoints, "balance", balance)
+			return fmt.Errorf("%w: %d points available", ErrInsufficientPoints, balance)
+		}
+	}
+	if err := state.ValidatePaymentMethods(); err != nil {
+		logger.Error("Invalid payment methods", "error", err)
+		return fmt.Errorf("invalid payment methods: %w", err)
+	}
+
+	if state.ExpressCheckout && state.Group != nil {
+		logger.Error("Group orders cannot use express checkout")
+		return fmt.Errorf("group orders cannot use express checkout")
+	}
+
+	// The basket's built up until checkout, unless the customer's asked to pay
+	// for the products they sent straight away
+	if !state.ExpressCheckout {
+		logger.Info("Waiting for checkout")
+		if err := workflow.Await(ctx, func() bool {
+			return checkedOut
+		}); err != nil {
+			logger.Error("Error waiting for checkout", "error", err)
+			return fmt.Errorf("error waiting for checkout: %w", err)
+		}
+	}
+	checkedOut = true
+	if state.Group != nil {
+		state.Group.Locked = true
+	}
+
+	if err := state.ValidateBasket(); err != nil {
+		logger.Error("Invalid basket", "error", err)
+		return fmt.Errorf("invalid basket: %w", err)
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+	})
+
+	// Check the order isn't fraudulent before any money is taken
+	riskCheck := RiskCheckRequest{
+		Email:        state.Email,
+		IPAddress:    state.IPAddress,
+		OrderID:      workflow.GetInfo(ctx).WorkflowExecution.ID,
+		Rules:        restaurantConfig.Risk,
+		Time:         workflow.Now(ctx),
+		TotalInPence: state.Total(),
+	}
+	if state.DeliveryAddress != nil {
+		riskCheck.Postcode = state.DeliveryAddress.PostCode
+	}
+	if err := workflow.ExecuteActivity(ctx, a.CheckRisk, riskCheck).Get(ctx, &state.Risk); err != nil {
+		logger.Error("Error checking order risk", "error", err)
+		return fmt.Errorf("error checking order risk: %w", err)
+	}
+	tracing.WorkflowEvent(ctx, "RiskChecked", attribute.String("risk.decision", string(state.Risk.Decision)))
+
+	if state.Risk.Decision == RiskDecisionReview {
+		setStatus(ctx, OrderStatusReview)
+		logger.Info("Order held for review", "reasons", state.Risk.Reasons, "timeout", restaurantConfig.Risk.ReviewTimeout)
+
+		reviewed, err := workflow.AwaitWithTimeout(ctx, restaurantConfig.Risk.ReviewTimeout, func() bool {
+			return state.Risk.Review != nil
+		})
+		if err != nil {
+			logger.Error("Error waiting for review", "error", err)
+			return fmt.Errorf("error waiting for review: %w", err)
+		}
+		if !reviewed {
+			// Nobody's vouched for the order - err on the side of caution
+			logger.Info("Review timed out")
+			state.Risk.Review = &RiskReview{
+				Reason:     "Not reviewed in time",
+				ReviewedAt: workflow.Now(ctx),
+				Reviewer:   "system",
+			}
+		}
+	}
+
+	if !state.Risk.Allowed() {
+		logger.Info("Order declined by fraud checks", "decision", state.Risk.Decision, "reasons", state.Risk.Reasons)
+		setStatus(ctx, OrderStatusDeclined)
+
+		if err := workflow.ExecuteActivity(ctx, a.SendTextMessage, state).Get(ctx, nil); err != nil {
+			logger.Error("Error notifying of status change", "error", err)
+			return fmt.Errorf("error notifying of status change: %w", err)
+		}
+
+		return nil
+	}
+
+	if state.Group != nil && state.Group.SplitPayment {
+		// Wait for everyone to pay their share
+		paymentWindowOpen = true
+		logger.Info("Waiting for participants to pay", "timeout", restaurantConfig.GroupPaymentTimeout)
+
+		allPaid, err := workflow.AwaitWithTimeout(ctx, restaurantConfig.GroupPaymentTimeout, func() bool {
+			return len(state.UnpaidParticipants()) == 0
+		})
+		if err != nil {
+			logger.Error("Error waiting for payments", "error", err)
+			return fmt.Errorf("error waiting for payments: %w", err)
+		}
+		paymentWindowOpen = false
+
+		// Let any payments already started finish
+		if err := workflow.Await(ctx, func() bool {
+			return paymentsInProgress == 0
+		}); err != nil {
+			logger.Error("Error waiting for payments", "error", err)
+			return fmt.Errorf("error waiting for payments: %w", err)
+		}
+
+		if !allPaid {
+			for _, p := range state.UnpaidParticipants() {
+				logger.Info("Removing items from participant who didn't pay", "participant", p.Name)
+				state.RemoveItemsFor(p.Name)
+			}
+		}
+
+		if len(state.Products) == 0 {
+			logger.Info("Nobody paid for the group order")
+			setStatus(ctx, OrderStatusCancelled)
+
+			if err := workflow.ExecuteActivity(ctx, a.SendTextMessage, state).Get(ctx, nil); err != nil {
+				logger.Error("Error notifying of status change", "error", err)
+				return fmt.Errorf("error notifying of status change: %w", err)
+			}
+
+			return nil
+		}
+	} else {
+		payer := state.Payer()
+
+		if state.RedeemPoints > 0 {
+			// Never spend more points than the order is worth
+			points := min(state.RedeemPoints, state.Subtotal()/LoyaltyPointValue)
+
+			logger.Info("Redeeming loyalty points", "points", points)
+			if err := workflow.ExecuteActivity(ctx, a.RedeemLoyaltyPoints, LoyaltyRequest{
+				CustomerID: state.CustomerID,
+				Points:     points,
+				Reference:  loyaltyReference(ctx, "redeem"),
+			}).Get(ctx, nil); err != nil {
+				logger.Error("Error redeeming loyalty points", "error", err)
+				return fmt.Errorf("error redeeming loyalty points: %w", err)
+			}
+
+			state.Loyalty.PointsRedeemed = points
+			state.Discounts = append(state.Discounts, Discount{
+				AmountInPence: points * LoyaltyPointValue,
+				Description:   "Loyalty points",
+			})
+		}
+
+		if total := state.Total(); total > 0 {
+			if err := takeTenders(ctx, total, payer); err != nil {
+				logger.Error("Error taking payment", "error", err)
+
+				// Put back anything already taken from gift cards or wallets
+				if err := refundPayments(ctx, &state, intervene); err != nil {
+					logger.Error("Error returning partial payment", "error", err)
+				}
+
+				// Give the customer their points back
+				if err := reverseLoyaltyPoints(ctx, &state); err != nil {
+					logger.Error("Error reversing loyalty points", "error", err)
+				}
+
+				// The customer couldn't pay or ops gave up on taking the payment
+				if errors.Is(err, ErrPaymentDeclined) || errors.Is(err, ErrInterventionCancelled) {
+					setStatus(ctx, OrderStatusCancelled)
+
+					if err := workflow.ExecuteActivity(ctx, a.SendTextMessage, state).Get(ctx, nil); err != nil {
+						logger.Error("Error notifying of status change", "error", err)
+						return fmt.Errorf("error notifying of status change: %w", err)
+					}
+
+					return nil
+				}
+
+				return fmt.Errorf("error taking payment: %w", err)
+			}
+		}
+	}
+
+	// Gives back everything paid for a rejected or cancelled order
+	refundOrder := func(ctx workflow.Context) error {
+		refundedBefore := state.RefundedInPence()
+		err := refundPayments(ctx, &state, intervene)
+		publishRefund(ctx, refundedBefore)
+		if err != nil {
+			logger.Error("Error refunding payment", "error", err)
+			return fmt.Errorf("error refunding payment: %w", err)
+		}
+
+		if err := reverseLoyaltyPoints(ctx, &state); err != nil {
+			logger.Error("Error reversing loyalty points", "error", err)
+			return fmt.Errorf("error reversing loyalty points: %w", err)
+		}
+
+		if err := workflow.ExecuteActivity(ctx, a.SendTextMessage, state).Get(ctx, nil); err != nil {
+			logger.Error("Error notifying of status change", "error", err)
+			return fmt.Errorf("error notifying of status change: %w", err)
+		}
+
+		return nil
+	}
+
+	// Tips given at checkout are charged now the food's paid for
+	tipsAtCheckout = false
+	for _, tip := range state.Tips {
+		if err := captureTip(ctx, tip.TipID); err != nil {
+			// The customer still gets their food
+			logger.Warn("Error charging tip", "error", err, "tipId", tip.TipID)
+		}
+	}
+
+	if state.FulfilmentTime != nil {
+		// Order for later - hold it until it's time for the kitchen to start on it
+		setStatus(ctx, OrderStatusScheduled)
+
+		if err := workflow.ExecuteActivity(ctx, a.SendTextMessage, state).Get(ctx, nil); err != nil {
+			logger.Error("Error notifying of status change", "error", err)
+			return fmt.Errorf("error notifying of status change: %w", err)
+		}
+
+		releaseTime := restaurantConfig.ReleaseTime(*state.FulfilmentTime)
+		logger.Info("Order scheduled", "fulfilmentTime", *state.FulfilmentTime, "releaseTime", releaseTime)
+
+		if wait := releaseTime.Sub(workflow.Now(ctx)); wait > 0 {
+			if _, err := workflow.AwaitWithTimeout(ctx, wait, func() bool {
+				return state.Status == OrderStatusCancelled
+			}); err != nil {
+				logger.Error("Error waiting for release time", "error", err)
+				return fmt.Errorf("error waiting for release time: %w", err)
+			}
+		}
+
+		if state.Status == OrderStatusCancelled {
+			// Wait for the refund to be made
+			if err := workflow.Await(ctx, func() bool {
+				return updatesInProgress == 0
+			}); err != nil {
+				logger.Error("Error waiting for cancellation", "error", err)
+				return fmt.Errorf("error waiting for cancellation: %w", err)
+			}
+
+			logger.Info("Order cancelled before release")
+			if refundPending {
+				return refundOrder(ctx)
+			}
+			return nil
+		}
+	}
+
+	// Set order status to pending
+	setStatus(ctx, OrderStatusPending)
+
+	if err := workflow.ExecuteActivity(ctx, a.SendTextMessage, state).Get(ctx, nil); err != nil {
+		logger.Error("Error notifying of status change", "error", err)
+		return fmt.Errorf("error notifying of status change: %w", err)
+	}
+
+	// Wait for the status to be completed
+	if err := workflow.Await(ctx, func() bool {
+		return (state.Status == OrderStatusCompleted || refundPending) && updatesInProgress == 0
+	}); err != nil {
+		logger.Error("Error waiting for workflow to complete", "error", err)
+		return fmt.Errorf("error waiting for workflow to complete: %w", err)
+	}
+
+	if refundPending {
+		logger.Info("Refunding rejected order")
+		return refundOrder(ctx)
+	}
+
+	if state.CustomerID != "" {
+		points := LoyaltyPointsEarned(state.Total())
+
+		logger.Info("Crediting loyalty points", "points", points)
+		if err := workflow.ExecuteActivity(ctx, a.CreditLoyaltyPoints, LoyaltyRequest{
+			CustomerID: state.CustomerID,
+			Points:     points,
+			Reference:  loyaltyReference(ctx, "earn"),
+		}).Get(ctx, nil); err != nil {
+			logger.Error("Error crediting loyalty points", "error", err)
+			return fmt.Errorf("error crediting loyalty points: %w", err)
+		}
+		state.Loyalty.PointsEarned = points
+
+		// Add to the customer's order history
+		summary := OrderSummary{
+			Collection:      state.Collection,
+			CompletedAt:     workflow.Now(ctx),
+			DeliveryAddress: state.DeliveryAddress,
+			OrderID:         workflow.GetInfo(ctx).WorkflowExecution.ID,
+			Products:        state.Products,
+			TotalInPence:    state.Total(),
+		}
+		// The activity starts the customer's workflow if it's not running
+		if err := workflow.ExecuteActivity(
+			workflow.WithRetryPolicy(ctx, temporal.RetryPolicy{MaximumAttempts: 5}),
+			a.RecordCustomerOrder,
+			CustomerOrderRequest{
+				CustomerID: state.CustomerID,
+				Order:      summary,
+			},
+		).Get(ctx, nil); err != nil {
+			// The food's been eaten - don't fail the order because the history is unavailable
+			logger.Warn("Error adding order to customer history", "error", err, "customerId", state.CustomerID)
+		}
+	}
+
+	// Give the customer a chance to rate the order, complain or tip
+	feedbackWindowOpen = true
+	tipWindowOpen = true
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		_ = workflow.Sleep(ctx, restaurantConfig.Tips.Window)
+		tipWindowOpen = false
+	})
+
+	logger.Info("Waiting for feedback", "window", restaurantConfig.FeedbackWindow, "tipWindow", restaurantConfig.Tips.Window)
+	if err := workflow.Sleep(ctx, restaurantConfig.FeedbackWindow); err != nil {
+		logger.Error("Error waiting for feedback", "error", err)
+		return fmt.Errorf("error waiting for feedback: %w", err)
+	}
+	feedbackWindowOpen = false
+
+	if err := workflow.Await(ctx, func() bool {
+		return !tipWindowOpen
+	}); err != nil {
+		logger.Error("Error waiting for tips", "error", err)
+		return fmt.Errorf("error waiting for tips: %w", err)
+	}
+
+	// Let any complaints being made finish
+	if err := workflow.Await(ctx, func() bool {
+		return workflow.AllHandlersFinished(ctx)
+	}); err != nil {
+		logger.Error("Error waiting for handlers to finish", "error", err)
+		return fmt.Errorf("error waiting for handlers to finish: %w", err)
+	}
+
+	// Small refunds get the benefit of the doubt. Anything bigger is left for a
+	// person to look at rather than refunded without the restaurant agreeing.
+	for i, complaint := range state.Complaints {
+		if complaint.Status != ComplaintStatusPending {
+			continue
+		}
+
+		if complaint.RefundInPence > restaurantConfig.ComplaintAutoRefund {
+			logger.Warn("Complaint not resolved by the restaurant", "complaintId", complaint.ComplaintID, "refundInPence", complaint.RefundInPence)
+			state.Complaints[i].Status = ComplaintStatusUnresolved
+			continue
+		}
+
+		logger.Info("Approving unresolved complaint", "complaintId", complaint.ComplaintID)
+		if err := approve(ctx, complaint.ComplaintID); err != nil {
+			logger.Error("Error approving complaint", "error", err)
+			return fmt.Errorf("error approving complaint: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// CustomerWorkflow is a long-running entity workflow, one per customer
+func CustomerWorkflow(ctx workflow.Context, state CustomerState) error {
+	logger := workflow.GetLogger(ctx)
+
+	if state.CustomerID == "" {
+		state.CustomerID = strings.TrimPrefix(workflow.GetInfo(ctx).WorkflowExecution.ID, CustomerWorkflowID(""))
+	}
+
+	// Query to return the customer
+	if err := workflow.SetQueryHandler(ctx, Queries.GET_CUSTOMER, func() (CustomerState, error) {
+		logger.Debug("Returning customer")
+		return state, nil
+	}); err != nil {
+		logger.Error("SetQueryHandler failed.", "error", err, "query", Queries.GET_CUSTOMER)
+		return err
+	}
+
+	if err := workflow.SetUpdateHandlerWithOptions(
+		ctx,
+		Updates.SAVE_ADDRESS,
+		func(ctx workflow.Context, address SavedAddress) error {
+			logger.Info("Saving address", "label", address.Label)
+			state.SaveAddress(address)
+
+			return nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: rejectUpdateWith(func(ctx workflow.Context, address SavedAddress) error {
+				if strings.TrimSpace(address.Label) == "" {
+					return fmt.Errorf("address label is required")
+				}
+				if address.Address.AddressLine1 == "" || address.Address.PostCode == "" {
+					return fmt.Errorf("address must have a first line and post code")
+				}
+
+				return nil
+			}),
+		},
+	); err != nil {
+		logger.Error("SetUpdateHandlerWithOptions failed.", "Error", err, "update", Updates.SAVE_ADDRESS)
+		return err
+	}
+
+	if err := workflow.SetUpdateHandler(
+		ctx,
+		Updates.REMOVE_ADDRESS,
+		func(ctx workflow.Context, label string) error {
+			logger.Info("Removing address", "label", label)
+			state.RemoveAddress(label)
+
+			return nil
+		},
+	); err != nil {
+		logger.Error("SetUpdateHandler failed.", "Error", err, "update", Updates.REMOVE_ADDRESS)
+		return err
+	}
+
+	if err := workflow.SetUpdateHandler(
+		ctx,
+		Updates.UPDATE_CONTACT,
+		func(ctx workflow.Context, contact ContactDetails) error {
+			logger.Info("Updating contact details")
+			state.Contact = contact
+
+			return nil
+		},
+	); err != nil {
+		logger.Error("SetUpdateHandler failed.", "Error", err, "update", Updates.UPDATE_CONTACT)
+		return err
+	}
+
+	// Start a new order from one in the history, returning the new order's ID
+	if err := workflow.SetUpdateHandlerWithOptions(
+		ctx,
+		Updates.REORDER,
+		func(ctx workflow.Context, req ReorderRequest) (string, error) {
+			order, err := state.Reorder(req)
+			if err != nil {
+				return "", err
+			}
+
+			var newOrderID string
+			if err := workflow.SideEffect(ctx, func(ctx workflow.Context) any {
+				return "ORDER-" + uuid.NewString()
+			}).Get(&newOrderID); err != nil {
+				logger.Error("Error generating order ID", "error", err)
+				return "", fmt.Errorf("error generating order ID: %w", err)
+			}
+
+			logger.Info("Reordering", "orderId", req.OrderID, "newOrderId", newOrderID)
+
+			// The order outlives this workflow continuing as new
+			ctx = workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+				WorkflowID:        newOrderID,
+				TaskQueue:         OrderFoodTaskQueue,
+				ParentClosePolicy: enums.PARENT_CLOSE_POLICY_ABANDON,
+			})
+
+			var execution workflow.Execution
+			if err := workflow.ExecuteChildWorkflow(ctx, OrderWorkflow, *order).
+				GetChildWorkflowExecution().
+				Get(ctx, &execution); err != nil {
+				logger.Error("Error starting order", "error", err)
+				return "", fmt.Errorf("error starting order: %w", err)
+			}
+
+			return execution.ID, nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: rejectUpdateWith(func(ctx workflow.Context, req ReorderRequest) error {
+				_, err := state.Reorder(req)
+				return err
+			}),
+		},
+	); err != nil {
+		logger.Error("SetUpdateHandlerWithOptions failed.", "Error", err, "update", Updates.REORDER)
+		return err
+	}
+
+	orders := workflow.GetSignalChannel(ctx, Signals.ORDER_COMPLETED)
+	recordOrders := func() {
+		for {
+			var order OrderSummary
+			if !orders.ReceiveAsync(&order) {
+				return
+			}
+
+			logger.Info("Recording completed order", "orderId", order.OrderID)
+			state.RecordOrder(order)
+		}
+	}
+
+	for {
+		if err := workflow.Await(ctx, func() bool {
+			return orders.Len() > 0 || workflow.GetInfo(ctx).GetContinueAsNewSuggested()
+		}); err != nil {
+			logger.Error("Error waiting for orders", "error", err)
+			return fmt.Errorf("error waiting for orders: %w", err)
+		}
+
+		recordOrders()
+
+		if workflow.GetInfo(ctx).GetContinueAsNewSuggested() {
+			// Let any updates finish before the history is reset
+			if err := workflow.Await(ctx, func() bool {
+				return workflow.AllHandlersFinished(ctx)
+			}); err != nil {
+				logger.Error("Error waiting for handlers to finish", "error", err)
+				return fmt.Errorf("error waiting for handlers to finish: %w", err)
+			}
+
+			// Don't lose anything that arrived whilst waiting
+			recordOrders()
+
+			logger.Info("Continuing as new")
+			return workflow.NewContinueAsNewError(ctx, CustomerWorkflow, state)
+		}
+	}
+}
+
+// DailySalesReportWorkflow summarises a day's orders for the restaurant. It's
+// designed to run on a schedule shortly after midnight, reporting on yesterday.
+func DailySalesReportWorkflow(ctx workflow.Context, req DailySalesReportRequest) (*DailySalesReport, error) {
+	logger := workflow.GetLogger(ctx)
+
+	var a *activities
+	var restaurantConfig Restaurant
+
+	if err := workflow.ExecuteLocalActivity(
+		workflow.WithLocalActivityOptions(ctx, workflow.LocalActivityOptions{
+			StartToCloseTimeout: time.Second * 10,
+		}),
+		a.GetRestaurant,
+	).Get(ctx, &restaurantConfig); err != nil {
+		logger.Error("Error getting restaurant", "error", err)
+		return nil, fmt.Errorf("error getting restaurant: %w", err)
+	}
+
+	from, to, err := restaurantConfig.ReportDay(req.Date, workflow.Now(ctx))
+	if err != nil {
+		logger.Error("Invalid report date", "error", err)
+		return nil, err
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+	})
+
+	var report DailySalesReport
+	if err := workflow.ExecuteActivity(ctx, a.GetDailySales, SalesReportRequest{
+		Date:         from.Format(time.DateOnly),
+		From:         from,
+		RestaurantID: restaurantConfig.ID,
+		To:           to,
+	}).Get(ctx, &report); err != nil {
+		logger.Error("Error getting daily sales", "error", err)
+		return nil, fmt.Errorf("error getting daily sales: %w", err)
+	}
+
+	var files SalesReportFiles
+	if err := workflow.ExecuteActivity(ctx, a.WriteSalesReport, report).Get(ctx, &files); err != nil {
+		logger.Error("Error writing sales report", "error", err)
+		return nil, fmt.Errorf("error writing sales report: %w", err)
+	}
+	logger.Info("Sales report written", "csv", files.CSV, "json", files.JSON)
+
+	email := req.Email
+	if email == "" {
+		email = restaurantConfig.ReportEmail
+	}
+	if email != "" {
+		if err := workflow.ExecuteActivity(ctx, a.EmailSalesReport, EmailSalesReportRequest{
+			Report: report,
+			To:     email,
+		}).Get(ctx, nil); err != nil {
+			logger.Error("Error emailing sales report", "error", err)
+			return nil, fmt.Errorf("error emailing sales report: %w", err)
+		}
+	}
+
+	return &report, nil
+}
+
+// Each loyalty movement has a unique reference so retries are only applied once
+func loyaltyReference(ctx workflow.Context, movement string) string {
+	return workflow.GetInfo(ctx).WorkflowExecution.ID + "/" + movement
+}
+
+// Gives back any points spent on the order and takes back any earned from it
+func reverseLoyaltyPoints(ctx workflow.Context, state *OrderState) error {
+	var a *activities
+
+	if points := state.Loyalty.PointsRedeemed - state.Loyalty.PointsReturned; points > 0 {
+		if err := workflow.ExecuteActivity(ctx, a.CreditLoyaltyPoints, LoyaltyRequest{
+			CustomerID: state.CustomerID,
+			Points:     points,
+			Reference:  loyaltyReference(ctx, "return"),
+		}).Get(ctx, nil); err != nil {
+			return err
+		}
+		state.Loyalty.PointsReturned += points
+	}
+
+	return clawbackLoyaltyPoints(ctx, state, state.Loyalty.PointsEarned, "clawback")
+}
+
+// Takes back points earned from the order, up to the number not already taken
+func clawbackLoyaltyPoints(ctx workflow.Context, state *OrderState, points int, movement string) error {
+	var a *activities
+
+	points = min(points, state.Loyalty.PointsEarned-state.Loyalty.PointsClawedBack)
+	if points <= 0 {
+		return nil
+	}
+
+	var clawedBack int
+	if err := workflow.ExecuteActivity(ctx, a.ClawbackLoyaltyPoints, LoyaltyRequest{
+		CustomerID: state.CustomerID,
+		Points:     points,
+		Reference:  loyaltyReference(ctx, movement),
+	}).Get(ctx, &clawedBack); err != nil {
+		return err
+	}
+	state.Loyalty.PointsClawedBack += clawedBack
+
+	return nil
+}
+
+// Refunds a complaint and takes back the loyalty points earned on the refunded amount
+func approveComplaint(ctx workflow.Context, state *OrderState, complaintID string, intervene interventionFunc) error {
+	complaint := state.GetComplaint(complaintID)
+
+	refunded := 0
+	for _, item := range complaint.Items {
+		// Split payments are refunded to whoever paid for the item
+		payer := ""
+		if state.Group != nil && state.Group.SplitPayment {
+			payer = item.Owner
+		}
+
+		amount, err := refundAmount(ctx, state, item.RefundInPence(), payer, intervene)
+		if err != nil {
+			return err
+		}
+		refunded += amount
+	}
+
+	if err := clawbackLoyaltyPoints(ctx, state, LoyaltyPointsEarned(refunded), "clawback/"+complaintID); err != nil {
+		return err
+	}
+
+	complaint = state.GetComplaint(complaintID)
+	complaint.RefundInPence = refunded
+	complaint.Status = ComplaintStatusApproved
+
+	return nil
+}
+
+// Refunds up to the amount to the tenders it was paid with, in proportion to how much each paid,
+// optionally only to those paid by the payer. Returns how much was refunded, which may be less if
+// the order was partly paid with points or ops cancelled a refund that failed.
+func refundAmount(ctx workflow.Context, state *OrderState, amount int, payer string, intervene interventionFunc) (int, error) {
+	var a *activities
+
+	refunded := 0
+	for i, refund := range RefundSplit(state.Payments, amount, payer) {
+		if refund <= 0 {
+			continue
+		}
+		payment := state.Payments[i]
+
+		if _, err := intervene(ctx, Intervention{
+			AmountInPence: refund,
+			Operation:     MoneyOperationRefund,
+			TransactionID: payment.TransactionID,
+		}, func(ctx workflow.Context) error {
+			if payment.Method.IsStoredValue() {
+				// Reference is unique to this refund so a retry doesn't credit twice
+				return workflow.ExecuteActivity(ctx, a.CreditGiftCard, GiftCardRequest{
+					Account:       payment.Account,
+					AmountInPence: refund,
+					Reference:     fmt.Sprintf("%s/refund/%d", payment.TransactionID, payment.RefundedInPence),
+				}).Get(ctx, nil)
+			}
+			return workflow.ExecuteActivity(ctx, a.RefundPayment, RefundRequest{
+				AmountInPence: refund,
+				TransactionID: payment.TransactionID,
+			}).Get(ctx, nil)
+		}); errors.Is(err, ErrInterventionCancelled) {
+			continue
+		} else if err != nil {
+			return refunded, err
+		}
+		state.Payments[i].RefundedInPence += refund
+		refunded += refund
+	}
+
+	return refunded, nil
+}
+
+// Refunds everything taken against the order that hasn't already been refunded, including tips
+func refundPayments(ctx workflow.Context, state *OrderState, intervene interventionFunc) error {
+	remaining := 0
+	for _, payment := range state.Payments {
+		remaining += payment.Refundable()
+	}
+
+	if _, err := refundAmount(ctx, state, remaining, "", intervene); err != nil {
+		return err
+	}
+
+	return refundTips(ctx, state, intervene)
+}
+
+// Tips are only refunded if the order's cancelled or rejected - complaints are about the food
+func refundTips(ctx workflow.Context, state *OrderState, intervene interventionFunc) error {
+	var a *activities
+
+	for i, tip := range state.Tips {
+		if tip.Status != TipStatusPaid {
+			continue
+		}
+
+		if _, err := intervene(ctx, Intervention{
+			AmountInPence: tip.AmountInPence,
+			Operation:     MoneyOperationRefund,
+			TransactionID: tip.Payment.TransactionID,
+		}, func(ctx workflow.Context) error {
+			return workflow.ExecuteActivity(ctx, a.RefundPayment, RefundRequest{
+				AmountInPence: tip.AmountInPence,
+				TransactionID: tip.Payment.TransactionID,
+			}).Get(ctx, nil)
+		}); errors.Is(err, ErrInterventionCancelled) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		state.Tips[i].Payment.RefundedInPence = tip.AmountInPence
+		state.Tips[i].Status = TipStatusRefunded
+	}
+
+	return nil
+}
+
+// Validation errors are marked so that clients can tell a rejected update from
+// one that failed whilst running
+func newUpdateRejectedError(err error) error {
+	return temporal.NewApplicationErrorWithOptions(err.Error(), UpdateRejectedErrorType, temporal.ApplicationErrorOptions{
+		Cause:        err,
+		NonRetryable: true,
+	})
+}
+
+// rejectUpdate wraps a validator for an update that takes no input
+func rejectUpdate(validator func(ctx workflow.Context) error) func(ctx workflow.Context) error {
+	return func(ctx workflow.Context) error {
+		if err := validator(ctx); err != nil {
+			return newUpdateRejectedError(err)
+		}
+		return nil
+	}
+}
+
+// rejectUpdateWith wraps a validator for an update that takes an input
+func rejectUpdateWith[T any](validator func(ctx workflow.Context, input T) error) func(ctx workflow.Context, input T) error {
+	return func(ctx workflow.Context, input T) error {
+		if err := validator(ctx, input); err != nil {
+			return newUpdateRejectedError(err)
+		}
+		return nil
+	}
 }
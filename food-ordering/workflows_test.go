@@ -0,0 +1,1043 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package foodordering
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mrsimonemms/temporal-demos/food-ordering/webhook"
+	"github.com/mrsimonemms/temporal-demos/metrics"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// A Friday lunchtime, so the restaurant's open and scheduled orders can be made
+var testStartTime = time.Date(2025, time.June, 6, 12, 0, 0, 0, time.UTC)
+
+// updateResult records what happened to an update sent to the workflow
+type updateResult struct {
+	accepted  bool
+	completed bool
+	err       error // Set if the update was rejected or failed
+}
+
+func (r *updateResult) callbacks() *testsuite.TestUpdateCallback {
+	return &testsuite.TestUpdateCallback{
+		OnAccept: func() {
+			r.accepted = true
+		},
+		OnReject: func(err error) {
+			r.err = err
+		},
+		OnComplete: func(_ any, err error) {
+			r.completed = true
+			r.err = err
+		},
+	}
+}
+
+type OrderWorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	a       *activities
+	env     *testsuite.TestWorkflowEnvironment
+	metrics *metrics.Handler
+}
+
+// Metrics are reported often, so the tests don't wait long for them
+const testMetricsInterval = time.Millisecond * 10
+
+func TestOrderWorkflow(t *testing.T) {
+	suite.Run(t, new(OrderWorkflowTestSuite))
+}
+
+func (s *OrderWorkflowTestSuite) SetupTest() {
+	s.metrics = metrics.NewHandler(metrics.WithReportInterval(testMetricsInterval))
+	s.SetMetricsHandler(s.metrics)
+
+	s.env = s.NewTestWorkflowEnvironment()
+	s.env.SetStartTime(testStartTime)
+
+	s.a = &activities{}
+	s.env.RegisterActivity(s.a)
+}
+
+func (s *OrderWorkflowTestSuite) AfterTest(_, _ string) {
+	s.env.AssertExpectations(s.T())
+	s.NoError(s.metrics.Close())
+}
+
+// newTestOrder is a collection with a full basket, so it goes straight to payment
+func newTestOrder() OrderState {
+	state := NewOrderState()
+	state.Collection = true
+	state.Email = "test@test.com"
+	state.ExpressCheckout = true
+	state.AddItem(OrderProduct{ProductID: 1, Quantity: 2})
+	state.AddItem(OrderProduct{ProductID: 2, Quantity: 1})
+
+	return state
+}
+
+// Total of the test order
+const testOrderTotal = 1575
+
+func testPayment(amountInPence int) *Payment {
+	return &Payment{
+		AmountInPence: amountInPence,
+		Payer:         "test@test.com",
+		TransactionID: "txn-1",
+	}
+}
+
+// mockDefaults lets the order run through without anything going wrong. Tests
+// mock the activities they care about before running, which take precedence.
+func (s *OrderWorkflowTestSuite) mockDefaults() {
+	s.env.OnActivity(s.a.GetRestaurant, mock.Anything).Return(&restaurant, nil).Maybe()
+	s.env.OnActivity(s.a.CheckRisk, mock.Anything, mock.Anything).Return(&RiskAssessment{Decision: RiskDecisionAllow}, nil).Maybe()
+	s.env.OnActivity(s.a.TakePayment, mock.Anything, mock.Anything).Return(func(_ context.Context, req PaymentRequest) (*Payment, error) {
+		return testPayment(req.AmountInPence), nil
+	}).Maybe()
+	s.env.OnActivity(s.a.CancelPayment, mock.Anything, mock.Anything).Return(nil).Maybe()
+	s.env.OnActivity(s.a.RefundPayment, mock.Anything, mock.Anything).Return(nil).Maybe()
+	s.env.OnActivity(s.a.DebitGiftCard, mock.Anything, mock.Anything).Return(0, nil).Maybe()
+	s.env.OnActivity(s.a.CreditGiftCard, mock.Anything, mock.Anything).Return(0, nil).Maybe()
+	s.env.OnActivity(s.a.GetLoyaltyBalance, mock.Anything, mock.Anything).Return(1000, nil).Maybe()
+	s.env.OnActivity(s.a.RedeemLoyaltyPoints, mock.Anything, mock.Anything).Return(0, nil).Maybe()
+	s.env.OnActivity(s.a.CreditLoyaltyPoints, mock.Anything, mock.Anything).Return(0, nil).Maybe()
+	s.env.OnActivity(s.a.ClawbackLoyaltyPoints, mock.Anything, mock.Anything).Return(0, nil).Maybe()
+	s.env.OnActivity(s.a.PrintTicket, mock.Anything, mock.Anything).Return(nil).Maybe()
+	s.env.OnActivity(s.a.SendTextMessage, mock.Anything, mock.Anything).Return(nil).Maybe()
+	s.env.OnActivity(s.a.PublishOrderChange, mock.Anything, mock.Anything).Return(nil).Maybe()
+	s.env.OnActivity(s.a.SendWebhook, mock.Anything, mock.Anything).Return([]webhook.Delivery{}, nil).Maybe()
+	s.env.OnActivity(s.a.RecordCustomerOrder, mock.Anything, mock.Anything).Return(nil).Maybe()
+}
+
+func (s *OrderWorkflowTestSuite) run(state OrderState) {
+	s.mockDefaults()
+	s.env.ExecuteWorkflow(OrderWorkflow, state)
+	s.True(s.env.IsWorkflowCompleted())
+}
+
+// at runs the function once the workflow's been going for the duration
+func (s *OrderWorkflowTestSuite) at(d time.Duration, fn func()) {
+	s.env.RegisterDelayedCallback(fn, d)
+}
+
+// update sends the update - it's handled once the callback that sent it returns,
+// so check the result in a later callback or once the workflow's finished
+func (s *OrderWorkflowTestSuite) update(name string, args ...any) *updateResult {
+	var r updateResult
+	s.env.UpdateWorkflow(name, "", r.callbacks(), args...)
+	return &r
+}
+
+func (s *OrderWorkflowTestSuite) setStatus(status OrderStatus) *updateResult {
+	return s.update(Updates.UPDATE_STATUS, string(status))
+}
+
+// complete takes the order through the kitchen, starting at the duration
+func (s *OrderWorkflowTestSuite) complete(start time.Duration) {
+	for i, status := range []OrderStatus{OrderStatusAccepted, OrderStatusPreparing, OrderStatusReady, OrderStatusCompleted} {
+		s.at(start+time.Duration(i)*time.Minute, func() {
+			s.setStatus(status)
+		})
+	}
+}
+
+func (s *OrderWorkflowTestSuite) query() OrderState {
+	value, err := s.env.QueryWorkflow(Queries.GET_STATUS)
+	s.Require().NoError(err)
+
+	var state OrderState
+	s.Require().NoError(value.Get(&state))
+	return state
+}
+
+// statuses is the order's history, without the times
+func statuses(state OrderState) []OrderStatus {
+	list := make([]OrderStatus, 0, len(state.History))
+	for _, h := range state.History {
+		list = append(list, h.Status)
+	}
+	return list
+}
+
+func (s *OrderWorkflowTestSuite) Test_HappyPath() {
+	s.env.OnActivity(s.a.TakePayment, mock.Anything, PaymentRequest{
+		AmountInPence: testOrderTotal,
+		OrderID:       "default-test-workflow-id",
+		Payer:         "test@test.com",
+		Reference:     "default-test-workflow-id/charge/0",
+	}).Return(testPayment(testOrderTotal), nil).Once()
+	s.env.OnActivity(s.a.PrintTicket, mock.Anything, mock.Anything).Return(nil).Once()
+	s.env.OnActivity(s.a.RefundPayment, mock.Anything, mock.Anything).Never()
+
+	results := make([]*updateResult, 0)
+	previous := OrderStatusPending
+	for i, status := range []OrderStatus{OrderStatusAccepted, OrderStatusPreparing, OrderStatusReady, OrderStatusCompleted} {
+		s.at(time.Duration(i+1)*time.Minute, func() {
+			s.Equal(previous, s.query().Status)
+
+			results = append(results, s.setStatus(status))
+			previous = status
+		})
+	}
+
+	s.run(newTestOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+	for _, r := range results {
+		s.True(r.completed)
+		s.NoError(r.err)
+	}
+
+	state := s.query()
+	s.Equal([]OrderStatus{
+		OrderStatusDefault,
+		OrderStatusPending,
+		OrderStatusAccepted,
+		OrderStatusPreparing,
+		OrderStatusReady,
+		OrderStatusCompleted,
+	}, statuses(state))
+	s.Equal([]Payment{{
+		AmountInPence: testOrderTotal,
+		Method:        PaymentMethodCard,
+		Payer:         "test@test.com",
+		TransactionID: "txn-1",
+	}}, state.Payments)
+}
+
+func (s *OrderWorkflowTestSuite) Test_WaitsForCheckout() {
+	state := newTestOrder()
+	state.ExpressCheckout = false
+	state.Products = nil
+
+	var locked *updateResult
+	s.at(time.Minute, func() {
+		s.Equal(OrderStatusDefault, s.query().Status)
+		s.update(Updates.ADD_ITEM, OrderProduct{ProductID: 3, Quantity: 1})
+	})
+	s.at(time.Minute+time.Second, func() {
+		s.Len(s.query().Products, 1)
+		s.env.SignalWorkflow(Signals.CHECKOUT, nil)
+	})
+	s.at(2*time.Minute, func() {
+		s.Equal(OrderStatusPending, s.query().Status)
+
+		// The basket's locked once it's been paid for
+		locked = s.update(Updates.ADD_ITEM, OrderProduct{ProductID: 1, Quantity: 1})
+	})
+	s.complete(3 * time.Minute)
+
+	s.run(state)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.False(locked.accepted)
+	s.Error(locked.err)
+	s.Len(s.query().Products, 1)
+}
+
+func (s *OrderWorkflowTestSuite) Test_ProductsWaitForCheckout() {
+	state := newTestOrder()
+	state.ExpressCheckout = false
+
+	var removed *updateResult
+	s.at(time.Minute, func() {
+		// The customer can still change the basket they started with
+		s.Equal(OrderStatusDefault, s.query().Status)
+		removed = s.update(Updates.REMOVE_ITEM, OrderProduct{ProductID: 2, Quantity: 1})
+	})
+	s.at(time.Minute+time.Second, func() {
+		s.env.SignalWorkflow(Signals.CHECKOUT, nil)
+	})
+	s.complete(2 * time.Minute)
+
+	s.env.OnActivity(s.a.TakePayment, mock.Anything, mock.MatchedBy(func(req PaymentRequest) bool {
+		return req.AmountInPence == 700
+	})).Return(testPayment(700), nil).Once()
+
+	s.run(state)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.NoError(removed.err)
+	s.Len(s.query().Products, 1)
+}
+
+// The web app sends the basket and pays straight away, without a checkout
+func (s *OrderWorkflowTestSuite) Test_WebOrderPaysWithoutCheckout() {
+	var state OrderState
+	s.Require().NoError(json.Unmarshal([]byte(`{
+		"collection": true,
+		"deliveryAddress": null,
+		"email": "test@test.com",
+		"expressCheckout": true,
+		"products": [{"productId": 1, "quantity": 2}, {"productId": 2, "quantity": 1}]
+	}`), &state))
+
+	s.env.OnActivity(s.a.TakePayment, mock.Anything, mock.MatchedBy(func(req PaymentRequest) bool {
+		return req.AmountInPence == testOrderTotal
+	})).Return(testPayment(testOrderTotal), nil).Once()
+
+	s.at(time.Minute, func() {
+		s.Equal(OrderStatusPending, s.query().Status)
+	})
+	s.complete(2 * time.Minute)
+
+	s.run(state)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.Equal(OrderStatusCompleted, s.query().Status)
+}
+
+func (s *OrderWorkflowTestSuite) Test_GroupOrderCannotUseExpressCheckout() {
+	state := newTestOrder()
+	state.Group = &GroupOrder{Organiser: "test@test.com"}
+
+	s.run(state)
+
+	s.ErrorContains(s.env.GetWorkflowError(), "group orders cannot use express checkout")
+}
+
+func (s *OrderWorkflowTestSuite) Test_RejectionRefunds() {
+	s.env.OnActivity(s.a.RefundPayment, mock.Anything, RefundRequest{
+		AmountInPence: testOrderTotal,
+		TransactionID: "txn-1",
+	}).Return(nil).Once()
+	s.env.OnActivity(s.a.PrintTicket, mock.Anything, mock.Anything).Never()
+
+	var rejected *updateResult
+	s.at(time.Minute, func() {
+		rejected = s.setStatus(OrderStatusRejected)
+	})
+
+	s.run(newTestOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+	s.True(rejected.completed)
+	s.NoError(rejected.err)
+
+	state := s.query()
+	s.Equal(OrderStatusRejected, state.Status)
+	s.Equal(testOrderTotal, state.Payments[0].RefundedInPence)
+}
+
+func (s *OrderWorkflowTestSuite) Test_RefundFailureNeedsAttention() {
+	// Fails until ops step in
+	s.env.OnActivity(s.a.RefundPayment, mock.Anything, mock.Anything).Return(errors.New("provider down")).Times(int(moneyRetryPolicy.MaximumAttempts))
+
+	var rejected, completed, resolved *updateResult
+	s.at(time.Minute, func() {
+		rejected = s.setStatus(OrderStatusRejected)
+	})
+	s.at(time.Hour, func() {
+		// The restaurant isn't kept waiting whilst ops sort out the refund
+		s.True(rejected.completed)
+
+		state := s.query()
+		s.Equal(OrderStatusNeedsAttention, state.Status)
+		s.Require().Equal(1, state.OpenInterventions())
+
+		intervention := state.Interventions[0]
+		s.Equal(MoneyOperationRefund, intervention.Operation)
+		s.Equal(testOrderTotal, intervention.AmountInPence)
+		s.Equal(OrderStatusRejected, intervention.PreviousStatus)
+
+		// The restaurant can't move the order on whilst it's with ops
+		completed = s.setStatus(OrderStatusCompleted)
+
+		resolved = s.update(Updates.RESOLVE_INTERVENTION, InterventionRequest{
+			Action:         InterventionResolve,
+			InterventionID: intervention.ID,
+			Operator:       "ops@test.com",
+			Reference:      "manual-refund-1",
+		})
+	})
+
+	s.run(newTestOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+	s.NoError(rejected.err)
+	s.Error(completed.err)
+	s.NoError(resolved.err)
+
+	state := s.query()
+	s.Equal(OrderStatusRejected, state.Status)
+	s.Zero(state.OpenInterventions())
+	s.Equal(testOrderTotal, state.Payments[0].RefundedInPence)
+}
+
+func (s *OrderWorkflowTestSuite) Test_InvalidStatus() {
+	inputs := []string{
+		"",
+		"NOT_A_STATUS",
+		string(OrderStatusDefault),
+		string(OrderStatusReview),
+		string(OrderStatusScheduled),
+		string(OrderStatusCancelled),
+		string(OrderStatusNeedsAttention),
+		// Has to go through the kitchen first
+		string(OrderStatusCompleted),
+	}
+
+	results := make([]*updateResult, 0)
+	s.at(time.Minute, func() {
+		for _, input := range inputs {
+			results = append(results, s.setStatus(OrderStatus(input)))
+		}
+	})
+	s.at(time.Minute+time.Second, func() {
+		// Nothing changed
+		state := s.query()
+		s.Equal(OrderStatusPending, state.Status)
+		s.Len(state.History, 2)
+	})
+	s.complete(2 * time.Minute)
+
+	s.run(newTestOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+	for i, r := range results {
+		s.False(r.accepted, inputs[i])
+		s.Error(r.err, inputs[i])
+	}
+}
+
+func (s *OrderWorkflowTestSuite) Test_StatusTransitions() {
+	var backwards, rejectedWhenReady *updateResult
+	s.at(time.Minute, func() {
+		s.setStatus(OrderStatusAccepted)
+	})
+	s.at(2*time.Minute, func() {
+		backwards = s.setStatus(OrderStatusPending)
+		s.setStatus(OrderStatusPreparing)
+	})
+	s.at(3*time.Minute, func() {
+		s.setStatus(OrderStatusReady)
+	})
+	s.at(4*time.Minute, func() {
+		// The food's been made
+		rejectedWhenReady = s.setStatus(OrderStatusRejected)
+		s.setStatus(OrderStatusCompleted)
+	})
+
+	s.run(newTestOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+	s.Error(backwards.err)
+	s.Error(rejectedWhenReady.err)
+	s.Equal([]OrderStatus{
+		OrderStatusDefault,
+		OrderStatusPending,
+		OrderStatusAccepted,
+		OrderStatusPreparing,
+		OrderStatusReady,
+		OrderStatusCompleted,
+	}, statuses(s.query()))
+}
+
+func (s *OrderWorkflowTestSuite) Test_StatusRejectedBeforePayment() {
+	// The restaurant doesn't have the order yet, so there's nothing to refund
+	s.env.OnActivity(s.a.TakePayment, mock.Anything, mock.Anything).Never()
+	s.env.OnActivity(s.a.RefundPayment, mock.Anything, mock.Anything).Never()
+
+	state := newTestOrder()
+	state.ExpressCheckout = false
+	state.Products = nil
+
+	var rejected *updateResult
+	s.at(time.Minute, func() {
+		rejected = s.setStatus(OrderStatusRejected)
+	})
+	s.at(2*time.Minute, func() {
+		s.Equal(OrderStatusDefault, s.query().Status)
+		s.env.CancelWorkflow()
+	})
+
+	s.run(state)
+
+	s.False(rejected.accepted)
+	s.Error(rejected.err)
+}
+
+func (s *OrderWorkflowTestSuite) Test_ConcurrentUpdates() {
+	// Slow refund, so the next update arrives whilst it's in progress
+	s.env.OnActivity(s.a.RefundPayment, mock.Anything, mock.Anything).After(time.Minute).Return(nil).Once()
+	s.env.OnActivity(s.a.CreditLoyaltyPoints, mock.Anything, mock.Anything).Never()
+
+	var rejected, completed *updateResult
+	s.at(time.Minute, func() {
+		rejected = s.setStatus(OrderStatusRejected)
+	})
+	s.at(time.Minute+time.Second, func() {
+		s.True(rejected.completed)
+
+		state := s.query()
+		s.Equal(OrderStatusRejected, state.Status)
+		s.Zero(state.Payments[0].RefundedInPence)
+
+		// The order's already finished, even though the refund hasn't
+		completed = s.setStatus(OrderStatusCompleted)
+	})
+
+	state := newTestOrder()
+	state.CustomerID = "customer-1"
+	s.run(state)
+
+	s.True(rejected.completed)
+	s.NoError(rejected.err)
+	s.False(completed.accepted)
+	s.Error(completed.err)
+
+	state = s.query()
+	s.Equal(OrderStatusRejected, state.Status)
+	s.Equal(testOrderTotal, state.Payments[0].RefundedInPence)
+}
+
+func (s *OrderWorkflowTestSuite) Test_CompletionWaitsForUpdate() {
+	// Slow ticket printer
+	s.env.OnActivity(s.a.PrintTicket, mock.Anything, mock.Anything).After(time.Minute).Return(nil).Once()
+	s.env.OnActivity(s.a.CreditLoyaltyPoints, mock.Anything, mock.Anything).Return(LoyaltyPointsEarned(testOrderTotal), nil).Once()
+	s.env.OnActivity(s.a.RecordCustomerOrder, mock.Anything, mock.MatchedBy(func(req CustomerOrderRequest) bool {
+		return req.CustomerID == "customer-1" && req.Order.TotalInPence == testOrderTotal
+	})).Return(nil).Once()
+
+	// The kitchen moves the order on whilst the ticket's still printing
+	results := make([]*updateResult, 0)
+	for i, status := range []OrderStatus{OrderStatusAccepted, OrderStatusPreparing, OrderStatusReady, OrderStatusCompleted} {
+		s.at(time.Minute+time.Duration(i)*time.Second, func() {
+			results = append(results, s.setStatus(status))
+		})
+	}
+
+	state := newTestOrder()
+	state.CustomerID = "customer-1"
+	s.run(state)
+
+	s.NoError(s.env.GetWorkflowError())
+	for _, r := range results {
+		s.True(r.completed)
+		s.NoError(r.err)
+	}
+	s.Equal(LoyaltyPointsEarned(testOrderTotal), s.query().Loyalty.PointsEarned)
+}
+
+func (s *OrderWorkflowTestSuite) Test_PaymentDeclined() {
+	s.env.OnActivity(s.a.TakePayment, mock.Anything, mock.Anything).
+		Return(nil, temporal.NewNonRetryableApplicationError("card declined", PaymentDeclinedErrorType, ErrPaymentDeclined)).Once()
+
+	s.run(newTestOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+
+	state := s.query()
+	s.Equal(OrderStatusCancelled, state.Status)
+	s.Empty(state.Payments)
+}
+
+func (s *OrderWorkflowTestSuite) Test_PaymentFailureResolvedByOps() {
+	s.env.OnActivity(s.a.TakePayment, mock.Anything, mock.Anything).Return(nil, errors.New("provider down")).Times(int(moneyRetryPolicy.MaximumAttempts))
+
+	var noReference, resolved *updateResult
+	s.at(time.Hour, func() {
+		state := s.query()
+		s.Equal(OrderStatusNeedsAttention, state.Status)
+		s.Require().Equal(1, state.OpenInterventions())
+
+		intervention := state.Interventions[0]
+		s.Equal(MoneyOperationPayment, intervention.Operation)
+
+		// Taking a payment by hand needs its reference
+		noReference = s.update(Updates.RESOLVE_INTERVENTION, InterventionRequest{
+			Action:         InterventionResolve,
+			InterventionID: intervention.ID,
+			Operator:       "ops@test.com",
+		})
+		resolved = s.update(Updates.RESOLVE_INTERVENTION, InterventionRequest{
+			Action:         InterventionResolve,
+			InterventionID: intervention.ID,
+			Operator:       "ops@test.com",
+			Reference:      "phone-1",
+		})
+	})
+	s.complete(2 * time.Hour)
+
+	s.run(newTestOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+	s.Error(noReference.err)
+	s.NoError(resolved.err)
+	s.Equal("phone-1", s.query().Payments[0].TransactionID)
+}
+
+func (s *OrderWorkflowTestSuite) Test_RiskReview() {
+	s.env.OnActivity(s.a.CheckRisk, mock.Anything, mock.Anything).
+		Return(&RiskAssessment{Decision: RiskDecisionReview, Reasons: []string{"first order"}}, nil).Once()
+
+	var reviewed *updateResult
+	s.at(time.Minute, func() {
+		s.Equal(OrderStatusReview, s.query().Status)
+		reviewed = s.update(Updates.REVIEW_RISK, RiskReview{Approved: true, Reviewer: "ops@test.com"})
+	})
+	s.complete(2 * time.Minute)
+
+	s.run(newTestOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+	s.NoError(reviewed.err)
+	s.Contains(statuses(s.query()), OrderStatusReview)
+}
+
+func (s *OrderWorkflowTestSuite) Test_RiskReviewTimesOut() {
+	s.env.OnActivity(s.a.CheckRisk, mock.Anything, mock.Anything).
+		Return(&RiskAssessment{Decision: RiskDecisionReview, Reasons: []string{"first order"}}, nil).Once()
+	s.env.OnActivity(s.a.TakePayment, mock.Anything, mock.Anything).Never()
+
+	s.run(newTestOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+
+	state := s.query()
+	s.Equal(OrderStatusDeclined, state.Status)
+	s.Equal("system", state.Risk.Review.Reviewer)
+}
+
+func (s *OrderWorkflowTestSuite) Test_PaymentChallenge() {
+	challenge := &PaymentChallenge{ChargeID: "ch_1", Payer: "test@test.com", URL: "http://localhost/challenge/ch_1"}
+	s.env.OnActivity(s.a.TakePayment, mock.Anything, mock.Anything).
+		Return(&Payment{AmountInPence: testOrderTotal, Challenge: challenge, Payer: "test@test.com", TransactionID: "ch_1"}, nil).Once()
+
+	s.at(time.Minute, func() {
+		state := s.query()
+		s.Equal(OrderStatusDefault, state.Status)
+		s.Equal([]PaymentChallenge{*challenge}, state.PaymentChallenges)
+
+		s.env.SignalWorkflow(Signals.PAYMENT_CALLBACK, PaymentCallback{ChargeID: "ch_1", Status: PaymentStatusSucceeded})
+	})
+	s.complete(2 * time.Minute)
+
+	s.run(newTestOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+
+	state := s.query()
+	s.Empty(state.PaymentChallenges)
+	s.Nil(state.Payments[0].Challenge)
+}
+
+func (s *OrderWorkflowTestSuite) Test_PaymentChallengeTimesOut() {
+	s.env.OnActivity(s.a.TakePayment, mock.Anything, mock.Anything).
+		Return(&Payment{AmountInPence: testOrderTotal, Challenge: &PaymentChallenge{ChargeID: "ch_1"}, TransactionID: "ch_1"}, nil).Once()
+	s.env.OnActivity(s.a.CancelPayment, mock.Anything, "ch_1").Return(nil).Once()
+
+	s.run(newTestOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+
+	state := s.query()
+	s.Equal(OrderStatusCancelled, state.Status)
+	s.Empty(state.PaymentChallenges)
+}
+
+func (s *OrderWorkflowTestSuite) Test_SplitTenderUnwound() {
+	s.env.OnActivity(s.a.DebitGiftCard, mock.Anything, GiftCardRequest{
+		Account:       "giftcard/GIFT-1000",
+		AmountInPence: testOrderTotal,
+		Reference:     "default-test-workflow-id/tender/0",
+	}).Return(1000, nil).Once()
+	s.env.OnActivity(s.a.TakePayment, mock.Anything, mock.MatchedBy(func(req PaymentRequest) bool {
+		return req.AmountInPence == testOrderTotal-1000
+	})).Return(nil, temporal.NewNonRetryableApplicationError("card declined", PaymentDeclinedErrorType, ErrPaymentDeclined)).Once()
+	s.env.OnActivity(s.a.CreditGiftCard, mock.Anything, GiftCardRequest{
+		Account:       "giftcard/GIFT-1000",
+		AmountInPence: 1000,
+		Reference:     "default-test-workflow-id/tender/0/refund/0",
+	}).Return(1000, nil).Once()
+
+	state := newTestOrder()
+	state.PaymentMethods = []PaymentMethod{
+		{Type: PaymentMethodCard},
+		{Type: PaymentMethodGiftCard, Code: "gift-1000"},
+	}
+	s.run(state)
+
+	s.NoError(s.env.GetWorkflowError())
+
+	state = s.query()
+	s.Equal(OrderStatusCancelled, state.Status)
+	s.Equal(1000, state.Payments[0].RefundedInPence)
+}
+
+func (s *OrderWorkflowTestSuite) Test_TipsAtCheckoutAndAfterCompletion() {
+	s.env.OnActivity(s.a.TakePayment, mock.Anything, mock.MatchedBy(func(req PaymentRequest) bool {
+		return req.AmountInPence == 300 || req.AmountInPence == 200
+	})).Return(func(_ context.Context, req PaymentRequest) (*Payment, error) {
+		return testPayment(req.AmountInPence), nil
+	}).Twice()
+
+	state := newTestOrder()
+	state.ExpressCheckout = false
+	state.Products = nil
+
+	var atCheckout, whilstCooking, afterCompletion, tooLate *updateResult
+	s.at(time.Minute, func() {
+		s.update(Updates.ADD_ITEM, OrderProduct{ProductID: 1, Quantity: 2})
+		atCheckout = s.update(Updates.TIP, TipRequest{AmountInPence: 300})
+		s.env.SignalWorkflow(Signals.CHECKOUT, nil)
+	})
+	s.at(2*time.Minute, func() {
+		whilstCooking = s.update(Updates.TIP, TipRequest{AmountInPence: 200})
+	})
+	s.complete(3 * time.Minute)
+	s.at(time.Hour, func() {
+		afterCompletion = s.update(Updates.TIP, TipRequest{AmountInPence: 200})
+	})
+	s.at(restaurant.Tips.Window+time.Hour, func() {
+		tooLate = s.update(Updates.TIP, TipRequest{AmountInPence: 200})
+	})
+
+	s.run(state)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.NoError(atCheckout.err)
+	s.Error(whilstCooking.err)
+	s.NoError(afterCompletion.err)
+	s.Error(tooLate.err)
+
+	state = s.query()
+	s.Require().Len(state.Tips, 2)
+	for _, tip := range state.Tips {
+		s.Equal(TipStatusPaid, tip.Status)
+		s.Equal(tip.AmountInPence, tip.Payment.AmountInPence)
+	}
+	// Tips aren't part of the food payment
+	s.Equal(700, state.Payments[0].AmountInPence)
+}
+
+func (s *OrderWorkflowTestSuite) Test_ScheduledOrderCancelled() {
+	s.env.OnActivity(s.a.RefundPayment, mock.Anything, RefundRequest{
+		AmountInPence: testOrderTotal,
+		TransactionID: "txn-1",
+	}).Return(nil).Once()
+	s.env.OnActivity(s.a.PrintTicket, mock.Anything, mock.Anything).Never()
+
+	var cancelled *updateResult
+	s.at(time.Hour, func() {
+		s.Equal(OrderStatusScheduled, s.query().Status)
+		cancelled = s.update(Updates.CANCEL)
+	})
+
+	state := newTestOrder()
+	fulfilmentTime := testStartTime.Add(6 * time.Hour)
+	state.FulfilmentTime = &fulfilmentTime
+	s.run(state)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.True(cancelled.completed)
+	s.NoError(cancelled.err)
+
+	state = s.query()
+	s.Equal(OrderStatusCancelled, state.Status)
+	s.Equal(testOrderTotal, state.Payments[0].RefundedInPence)
+}
+
+func (s *OrderWorkflowTestSuite) Test_FailedCancellationFinishesOrder() {
+	s.env.OnActivity(s.a.RedeemLoyaltyPoints, mock.Anything, mock.Anything).Return(100, nil).Once()
+	s.env.OnActivity(s.a.CreditLoyaltyPoints, mock.Anything, mock.Anything).
+		Return(0, temporal.NewNonRetryableApplicationError("ledger unavailable", "LedgerError", nil)).Once()
+	s.env.OnActivity(s.a.PrintTicket, mock.Anything, mock.Anything).Never()
+
+	var cancelled *updateResult
+	s.at(time.Hour, func() {
+		cancelled = s.update(Updates.CANCEL)
+	})
+
+	state := newTestOrder()
+	state.CustomerID = "customer-1"
+	state.RedeemPoints = 100
+	fulfilmentTime := testStartTime.Add(6 * time.Hour)
+	state.FulfilmentTime = &fulfilmentTime
+	s.run(state)
+
+	// The customer isn't kept waiting for the refund, and the failure finishes the order
+	s.ErrorContains(s.env.GetWorkflowError(), "error reversing loyalty points")
+	s.True(cancelled.completed)
+	s.NoError(cancelled.err)
+	s.Equal(OrderStatusCancelled, s.query().Status)
+}
+
+func (s *OrderWorkflowTestSuite) Test_NotEnoughLoyaltyPoints() {
+	s.env.OnActivity(s.a.GetLoyaltyBalance, mock.Anything, "customer-1").Return(50, nil).Once()
+	s.env.OnActivity(s.a.RedeemLoyaltyPoints, mock.Anything, mock.Anything).Never()
+	s.env.OnActivity(s.a.TakePayment, mock.Anything, mock.Anything).Never()
+
+	state := newTestOrder()
+	state.CustomerID = "customer-1"
+	state.RedeemPoints = 100
+	s.run(state)
+
+	s.ErrorContains(s.env.GetWorkflowError(), "50 points available")
+}
+
+func (s *OrderWorkflowTestSuite) Test_UnresolvedComplaintNotRefunded() {
+	// Keeps the order open after the feedback window's closed
+	config := restaurant
+	config.Tips.Window = config.FeedbackWindow * 2
+	s.env.OnActivity(s.a.GetRestaurant, mock.Anything).Return(&config, nil)
+	s.env.OnActivity(s.a.RefundPayment, mock.Anything, mock.Anything).Never()
+
+	var complained, tooLate *updateResult
+	s.complete(time.Minute)
+	s.at(time.Hour, func() {
+		// Over the auto-refund limit, so the restaurant has to approve it
+		complained = s.update(Updates.COMPLAINT, ComplaintRequest{
+			Items:  []ComplaintItem{{ProductID: 2, Quantity: 1}},
+			Reason: "Cold",
+		})
+	})
+	s.at(restaurant.FeedbackWindow+time.Hour, func() {
+		tooLate = s.update(Updates.RESOLVE_COMPLAINT, ComplaintResolution{Approved: true, ComplaintID: "C1"})
+	})
+
+	s.run(newTestOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+	s.NoError(complained.err)
+	s.Error(tooLate.err)
+
+	state := s.query()
+	s.Require().Len(state.Complaints, 1)
+	s.Equal(ComplaintStatusUnresolved, state.Complaints[0].Status)
+}
+
+// newTestGroupOrder is an empty group basket that Alice organises and everyone pays into
+func newTestGroupOrder() OrderState {
+	state := newTestOrder()
+	state.ExpressCheckout = false
+	state.Group = &GroupOrder{Organiser: "Alice", SplitPayment: true}
+	state.Products = nil
+
+	return state
+}
+
+func (s *OrderWorkflowTestSuite) Test_GroupOrderSplitPayment() {
+	for _, payer := range []string{"Alice", "Bob"} {
+		s.env.OnActivity(s.a.TakePayment, mock.Anything, mock.MatchedBy(func(req PaymentRequest) bool {
+			return req.Payer == payer
+		})).Return(func(_ context.Context, req PaymentRequest) (*Payment, error) {
+			payment := testPayment(req.AmountInPence)
+			payment.Payer = req.Payer
+			return payment, nil
+		}).Once()
+	}
+
+	var badCode, joined, stranger, locked *updateResult
+	s.at(time.Minute, func() {
+		code := s.query().Group.JoinCode
+		s.Len(code, joinCodeLength)
+
+		badCode = s.update(Updates.JOIN_GROUP, JoinRequest{JoinCode: "WRONG1", Name: "Bob"})
+		joined = s.update(Updates.JOIN_GROUP, JoinRequest{JoinCode: code, Name: "Bob"})
+	})
+	s.at(time.Minute+time.Second, func() {
+		s.update(Updates.ADD_ITEM, OrderProduct{ProductID: 1, Quantity: 2, Owner: "alice"})
+		s.update(Updates.ADD_ITEM, OrderProduct{ProductID: 2, Quantity: 1, Owner: "bob"})
+		stranger = s.update(Updates.ADD_ITEM, OrderProduct{ProductID: 3, Quantity: 1, Owner: "Carol"})
+	})
+	s.at(time.Minute+2*time.Second, func() {
+		s.env.SignalWorkflow(Signals.CHECKOUT, nil)
+	})
+	s.at(2*time.Minute, func() {
+		locked = s.update(Updates.JOIN_GROUP, JoinRequest{JoinCode: s.query().Group.JoinCode, Name: "Carol"})
+		s.update(Updates.PAY_SHARE, "alice")
+	})
+	s.at(2*time.Minute+time.Second, func() {
+		s.update(Updates.PAY_SHARE, "Bob")
+	})
+	s.complete(3 * time.Minute)
+
+	s.run(newTestGroupOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+	s.Error(badCode.err)
+	s.NoError(joined.err)
+	s.Error(stranger.err)
+	s.Error(locked.err)
+
+	state := s.query()
+	s.Equal(OrderStatusCompleted, state.Status)
+	s.Equal([]OrderProduct{
+		{ProductID: 1, Quantity: 2, Owner: "Alice"},
+		{ProductID: 2, Quantity: 1, Owner: "Bob"},
+	}, state.Products)
+
+	s.Require().Len(state.Payments, 2)
+	s.Equal("Alice", state.Payments[0].Payer)
+	s.Equal(700, state.Payments[0].AmountInPence)
+	s.Equal("Bob", state.Payments[1].Payer)
+	s.Equal(875, state.Payments[1].AmountInPence)
+}
+
+func (s *OrderWorkflowTestSuite) Test_GroupOrderUnpaidItemsRemoved() {
+	s.env.OnActivity(s.a.TakePayment, mock.Anything, mock.MatchedBy(func(req PaymentRequest) bool {
+		return req.Payer == "Alice" && req.AmountInPence == 700
+	})).Return(testPayment(700), nil).Once()
+
+	s.at(time.Minute, func() {
+		s.update(Updates.JOIN_GROUP, JoinRequest{JoinCode: s.query().Group.JoinCode, Name: "Bob"})
+	})
+	s.at(time.Minute+time.Second, func() {
+		s.update(Updates.ADD_ITEM, OrderProduct{ProductID: 1, Quantity: 2, Owner: "Alice"})
+		s.update(Updates.ADD_ITEM, OrderProduct{ProductID: 2, Quantity: 1, Owner: "Bob"})
+		s.env.SignalWorkflow(Signals.CHECKOUT, nil)
+	})
+	s.at(2*time.Minute, func() {
+		s.update(Updates.PAY_SHARE, "Alice")
+	})
+	// Bob never pays, so the order goes ahead without his items
+	s.complete(restaurant.GroupPaymentTimeout + 5*time.Minute)
+
+	s.run(newTestGroupOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+
+	state := s.query()
+	s.Equal(OrderStatusCompleted, state.Status)
+	s.Equal([]OrderProduct{{ProductID: 1, Quantity: 2, Owner: "Alice"}}, state.Products)
+}
+
+func (s *OrderWorkflowTestSuite) Test_LoyaltyPointsRedeemedAndEarned() {
+	s.env.OnActivity(s.a.RedeemLoyaltyPoints, mock.Anything, LoyaltyRequest{
+		CustomerID: "customer-1",
+		Points:     500,
+		Reference:  "default-test-workflow-id/redeem",
+	}).Return(500, nil).Once()
+	s.env.OnActivity(s.a.TakePayment, mock.Anything, mock.MatchedBy(func(req PaymentRequest) bool {
+		return req.AmountInPence == testOrderTotal-500
+	})).Return(testPayment(testOrderTotal-500), nil).Once()
+	s.env.OnActivity(s.a.CreditLoyaltyPoints, mock.Anything, LoyaltyRequest{
+		CustomerID: "customer-1",
+		Points:     LoyaltyPointsEarned(testOrderTotal - 500),
+		Reference:  "default-test-workflow-id/earn",
+	}).Return(0, nil).Once()
+
+	s.complete(time.Minute)
+
+	state := newTestOrder()
+	state.CustomerID = "customer-1"
+	state.RedeemPoints = 500
+	s.run(state)
+
+	s.NoError(s.env.GetWorkflowError())
+
+	state = s.query()
+	s.Equal(500, state.Loyalty.PointsRedeemed)
+	s.Equal(LoyaltyPointsEarned(testOrderTotal-500), state.Loyalty.PointsEarned)
+	s.Equal([]Discount{{AmountInPence: 500, Description: "Loyalty points"}}, state.Discounts)
+}
+
+func (s *OrderWorkflowTestSuite) Test_RatingOnlyInFeedbackWindow() {
+	var tooEarly, invalid, rated, tooLate *updateResult
+	s.at(30*time.Second, func() {
+		tooEarly = s.update(Updates.RATE, Rating{Stars: 5})
+	})
+	s.complete(time.Minute)
+	s.at(time.Hour, func() {
+		invalid = s.update(Updates.RATE, Rating{Stars: 6})
+		rated = s.update(Updates.RATE, Rating{Comment: "Lovely", Stars: 4})
+	})
+	s.at(restaurant.FeedbackWindow+time.Hour, func() {
+		tooLate = s.update(Updates.RATE, Rating{Stars: 1})
+	})
+
+	// Keeps the order open after the feedback window's closed
+	config := restaurant
+	config.Tips.Window = config.FeedbackWindow * 2
+	s.env.OnActivity(s.a.GetRestaurant, mock.Anything).Return(&config, nil)
+
+	s.run(newTestOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+	s.Error(tooEarly.err)
+	s.Error(invalid.err)
+	s.NoError(rated.err)
+	s.Error(tooLate.err)
+
+	state := s.query()
+	s.Require().NotNil(state.Rating)
+	s.Equal(4, state.Rating.Stars)
+	s.Equal(testStartTime.Add(time.Hour), state.Rating.RatedAt)
+}
+
+func (s *OrderWorkflowTestSuite) Test_SmallComplaintRefunded() {
+	s.env.OnActivity(s.a.RefundPayment, mock.Anything, RefundRequest{
+		AmountInPence: 350,
+		TransactionID: "txn-1",
+	}).Return(nil).Once()
+	s.env.OnActivity(s.a.ClawbackLoyaltyPoints, mock.Anything, LoyaltyRequest{
+		CustomerID: "customer-1",
+		Points:     LoyaltyPointsEarned(350),
+		Reference:  "default-test-workflow-id/clawback/C1",
+	}).Return(LoyaltyPointsEarned(350), nil).Once()
+	// There's no status change, so the refund's published on its own
+	s.env.OnActivity(s.a.PublishOrderChange, mock.Anything, mock.MatchedBy(func(change OrderChange) bool {
+		return change.Type == OrderRefundedEvent
+	})).Return(func(_ context.Context, change OrderChange) error {
+		s.Equal("default-test-workflow-id/refund/350", change.ID)
+		s.Equal(350, change.Order.RefundedInPence())
+		return nil
+	}).Once()
+
+	var complained, again *updateResult
+	s.complete(time.Minute)
+	s.at(time.Hour, func() {
+		// Under the auto-refund limit, so there's no need to wait for the restaurant
+		complained = s.update(Updates.COMPLAINT, ComplaintRequest{
+			Items:  []ComplaintItem{{ProductID: 1, Quantity: 1}},
+			Reason: "Soggy",
+		})
+	})
+	s.at(2*time.Hour, func() {
+		again = s.update(Updates.COMPLAINT, ComplaintRequest{
+			Items:  []ComplaintItem{{ProductID: 1, Quantity: 2}},
+			Reason: "Still soggy",
+		})
+	})
+
+	state := newTestOrder()
+	state.CustomerID = "customer-1"
+	s.run(state)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.NoError(complained.err)
+	s.Error(again.err)
+
+	state = s.query()
+	s.Require().Len(state.Complaints, 1)
+	s.Equal(ComplaintStatusApproved, state.Complaints[0].Status)
+	s.Equal(350, state.Complaints[0].RefundInPence)
+	s.Equal(350, state.Payments[0].RefundedInPence)
+	s.Equal(LoyaltyPointsEarned(350), state.Loyalty.PointsClawedBack)
+}
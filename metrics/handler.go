@@ -0,0 +1,102 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"io"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/uber-go/tally/v4"
+	tallyprom "github.com/uber-go/tally/v4/prometheus"
+	"go.temporal.io/sdk/client"
+	sdktally "go.temporal.io/sdk/contrib/tally"
+)
+
+// Timers are histograms in seconds. Workflows can wait for hours, so the
+// buckets go up further than Prometheus's defaults.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 1800, 3600, 21600}
+
+// How often the recorded metrics are copied to Prometheus
+const DefaultReportInterval = time.Second
+
+// Handler records the SDK and workflow metrics for Prometheus to scrape. It's
+// used as the client's MetricsHandler.
+type Handler struct {
+	client.MetricsHandler
+
+	closer   io.Closer
+	registry *prometheus.Registry
+}
+
+type Option func(*options)
+
+type options struct {
+	reportInterval time.Duration
+}
+
+// WithReportInterval changes how often the metrics are copied to Prometheus
+func WithReportInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.reportInterval = interval
+	}
+}
+
+func NewHandler(opts ...Option) *Handler {
+	o := &options{
+		reportInterval: DefaultReportInterval,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	reporter := tallyprom.NewReporter(tallyprom.Options{
+		DefaultHistogramBuckets: DefaultBuckets,
+		DefaultTimerType:        tallyprom.HistogramTimerType,
+		OnRegisterError: func(err error) {
+			// Prometheus won't take a metric with different tags - it's dropped
+			log.Println("Unable to register metric", err)
+		},
+		Registerer: registry,
+	})
+
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		CachedReporter:  reporter,
+		SanitizeOptions: &sdktally.PrometheusSanitizeOptions,
+		Separator:       tallyprom.DefaultSeparator,
+	}, o.reportInterval)
+
+	return &Handler{
+		// Counters end in _total and timers in _seconds
+		MetricsHandler: sdktally.NewMetricsHandler(sdktally.NewPrometheusNamingScope(scope)),
+		closer:         closer,
+		registry:       registry,
+	}
+}
+
+// Close reports the last of the metrics and stops reporting
+func (h *Handler) Close() error {
+	return h.closer.Close()
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func scrape(t *testing.T, h *Handler) string {
+	t.Helper()
+
+	srv := httptest.NewServer(NewHTTPHandler(h))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/metrics")
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	body, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+// Reported often, so the tests don't wait long
+func newTestHandler(t *testing.T) *Handler {
+	h := NewHandler(WithReportInterval(time.Millisecond * 10))
+	t.Cleanup(func() {
+		assert.NoError(t, h.Close())
+	})
+	return h
+}
+
+func TestHandler(t *testing.T) {
+	h := newTestHandler(t)
+
+	h.WithTags(map[string]string{"status": "PENDING"}).Counter("orders").Inc(1)
+	h.WithTags(map[string]string{"status": "COMPLETED"}).Counter("orders").Inc(2)
+	h.WithTags(map[string]string{"task-queue": "orders"}).Gauge("slots").Update(5)
+	h.Timer("latency").Record(time.Millisecond * 20)
+	h.Timer("latency").Record(time.Second * 2)
+
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		body := scrape(t, h)
+		assert.Contains(c, body, `orders_total{status="PENDING"} 1`)
+		assert.Contains(c, body, `orders_total{status="COMPLETED"} 2`)
+		assert.Contains(c, body, `slots{task_queue="orders"} 5`)
+		assert.Contains(c, body, `latency_seconds_bucket{le="0.025"} 1`)
+		assert.Contains(c, body, `latency_seconds_bucket{le="2.5"} 2`)
+		assert.Contains(c, body, `latency_seconds_count 2`)
+		assert.Contains(c, body, `go_goroutines`)
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestHandlerWithTestEnvironment(t *testing.T) {
+	h := newTestHandler(t)
+
+	var s testsuite.WorkflowTestSuite
+	s.SetMetricsHandler(h)
+	env := s.NewTestWorkflowEnvironment()
+
+	env.ExecuteWorkflow(func(ctx workflow.Context) error {
+		workflow.GetMetricsHandler(ctx).WithTags(map[string]string{"outcome": "succeeded"}).Counter("greetings").Inc(1)
+		return nil
+	})
+	assert.NoError(t, env.GetWorkflowError())
+
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.Regexp(c, `greetings_total\{.*outcome="succeeded".*\} 1`, scrape(t, h))
+	}, time.Second, time.Millisecond*10)
+}
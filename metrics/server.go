@@ -14,16 +14,23 @@
  * limitations under the License.
  */
 
-import { ensureConnection } from '$lib/server/temporal';
-import type { ServerInit } from '@sveltejs/kit';
-import process from 'node:process';
+package metrics
 
-export const init: ServerInit = async () => {
-  console.log('Connecting to Temporal service');
-  const temporal = await ensureConnection();
+import (
+	"net/http"
 
-  process.on('sveltekit:shutdown', async () => {
-    console.log('Closing Temporal connection');
-    await temporal.connection.close();
-  });
-};
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// NewHTTPHandler serves the metrics, with the Go runtime and process metrics,
+// in the Prometheus format
+func NewHTTPHandler(h *Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.HandlerFor(h.registry, promhttp.HandlerOpts{}))
+	return mux
+}
+
+// ListenAndServe serves the metrics on /metrics
+func ListenAndServe(addr string, h *Handler) error {
+	return http.ListenAndServe(addr, NewHTTPHandler(h))
+}
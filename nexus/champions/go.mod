@@ -1,30 +1,65 @@
 module github.com/mrsimonemms/temporal-demos/nexus/champions
 
-go 1.24.0
+go 1.24.5
 
-replace github.com/mrsimonemms/temporal-demos/nexus/shared => ../shared
+replace (
+	github.com/mrsimonemms/temporal-demos/codec => ../../codec
+	github.com/mrsimonemms/temporal-demos/metrics => ../../metrics
+	github.com/mrsimonemms/temporal-demos/nexus/greeter => ../greeter
+	github.com/mrsimonemms/temporal-demos/nexus/shared => ../shared
+	github.com/mrsimonemms/temporal-demos/tracing => ../../tracing
+)
 
 require (
+	github.com/mrsimonemms/temporal-demos/codec v0.0.0-00010101000000-000000000000
+	github.com/mrsimonemms/temporal-demos/metrics v0.0.0-00010101000000-000000000000
+	github.com/mrsimonemms/temporal-demos/nexus/greeter v0.0.0-00010101000000-000000000000
 	github.com/mrsimonemms/temporal-demos/nexus/shared v0.0.0-00010101000000-000000000000
+	github.com/mrsimonemms/temporal-demos/tracing v0.0.0-00010101000000-000000000000
+	github.com/nexus-rpc/sdk-go v0.4.0
+	github.com/stretchr/testify v1.11.1
+	go.opentelemetry.io/otel v1.37.0
+	go.opentelemetry.io/otel/sdk v1.37.0
+	go.temporal.io/api v1.53.0
 	go.temporal.io/sdk v1.36.0
 	go.temporal.io/sdk/contrib/envconfig v0.1.0
 )
 
 require (
 	github.com/BurntSushi/toml v1.5.0 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cenkalti/backoff/v5 v5.0.2 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/facebookgo/clock v0.0.0-20150410010913-600d898af40a // indirect
+	github.com/go-logr/logr v1.4.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
 	github.com/gogo/protobuf v1.3.2 // indirect
 	github.com/golang/mock v1.6.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/grpc-ecosystem/go-grpc-middleware/v2 v2.3.2 // indirect
 	github.com/grpc-ecosystem/grpc-gateway/v2 v2.27.2 // indirect
-	github.com/nexus-rpc/sdk-go v0.4.0 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/prometheus/client_golang v1.22.0 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.62.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
 	github.com/robfig/cron v1.2.0 // indirect
 	github.com/stretchr/objx v0.5.2 // indirect
-	github.com/stretchr/testify v1.11.1 // indirect
-	go.temporal.io/api v1.53.0 // indirect
+	github.com/twmb/murmur3 v1.1.8 // indirect
+	github.com/uber-go/tally/v4 v4.1.17 // indirect
+	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace v1.37.0 // indirect
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.37.0 // indirect
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.37.0 // indirect
+	go.opentelemetry.io/otel/metric v1.37.0 // indirect
+	go.opentelemetry.io/otel/trace v1.37.0 // indirect
+	go.opentelemetry.io/proto/otlp v1.7.0 // indirect
+	go.temporal.io/sdk/contrib/opentelemetry v0.6.0 // indirect
+	go.temporal.io/sdk/contrib/tally v0.2.0 // indirect
+	go.uber.org/atomic v1.11.0 // indirect
 	golang.org/x/net v0.44.0 // indirect
 	golang.org/x/sync v0.17.0 // indirect
 	golang.org/x/sys v0.36.0 // indirect
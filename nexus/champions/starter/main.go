@@ -20,14 +20,38 @@ import (
 	"context"
 	"log"
 
+	"github.com/mrsimonemms/temporal-demos/codec"
 	"github.com/mrsimonemms/temporal-demos/nexus/champions"
+	"github.com/mrsimonemms/temporal-demos/tracing"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/contrib/envconfig"
 )
 
 func main() {
+	// Payloads are encrypted before they're stored in the workflow history
+	dataConverter, err := codec.DataConverterFromEnv()
+	if err != nil {
+		log.Fatalln("Unable to create data converter", err)
+	}
+
+	// Traces are only exported if OTEL_TRACES_EXPORTER is set
+	shutdownTracing, err := tracing.Setup(context.Background(), "champions-starter")
+	if err != nil {
+		log.Fatalln("Unable to configure tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	tracingInterceptor, err := tracing.NewInterceptor()
+	if err != nil {
+		log.Fatalln("Unable to create tracing interceptor", err)
+	}
+
+	opts := envconfig.MustLoadDefaultClientOptions()
+	opts.DataConverter = dataConverter
+	opts.Interceptors = append(opts.Interceptors, tracingInterceptor)
+
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(envconfig.MustLoadDefaultClientOptions())
+	c, err := client.Dial(opts)
 	if err != nil {
 		log.Fatalln("Unable to create Temporal client", err)
 	}
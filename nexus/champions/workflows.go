@@ -21,6 +21,8 @@ import (
 	"time"
 
 	"github.com/mrsimonemms/temporal-demos/nexus/shared"
+	"github.com/mrsimonemms/temporal-demos/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.temporal.io/sdk/workflow"
 )
 
@@ -53,11 +55,17 @@ func SayHelloToChampionWorkflow(ctx workflow.Context) (string, error) {
 
 	future := c.ExecuteOperation(ctx, shared.HelloOperationName, champion, workflow.NexusOperationOptions{})
 
+	// Ignored by the metrics handler while the workflow's replaying
+	metrics := workflow.GetMetricsHandler(ctx).WithTags(map[string]string{"operation": shared.HelloOperationName})
+
 	var result string
 	if err := future.Get(ctx, &result); err != nil {
+		metrics.WithTags(map[string]string{"outcome": "failed"}).Counter("nexus_operations").Inc(1)
 		return "", fmt.Errorf("nexus operation failed: %w", err)
 	}
+	metrics.WithTags(map[string]string{"outcome": "succeeded"}).Counter("nexus_operations").Inc(1)
 
+	tracing.WorkflowEvent(ctx, "ChampionGreeted", attribute.String("champion", champion))
 	logger.Info("SayHelloToChampionWorkflow workflow completed.", "name", result)
 
 	return result, nil
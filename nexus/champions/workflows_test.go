@@ -0,0 +1,144 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package champions
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mrsimonemms/temporal-demos/nexus/greeter"
+	"github.com/mrsimonemms/temporal-demos/nexus/shared"
+	"github.com/mrsimonemms/temporal-demos/tracing"
+	"github.com/nexus-rpc/sdk-go/nexus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	nexuspb "go.temporal.io/api/nexus/v1"
+	"go.temporal.io/api/operatorservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+)
+
+// The test environment doesn't pass headers to workflows started by Nexus
+// operations, so the trace is checked against a dev server. Set
+// TEMPORAL_CLI_PATH to use an installed CLI instead of downloading it.
+func TestTraceSpansNexusOperation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a dev server")
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+	})
+
+	tracingInterceptor, err := tracing.NewInterceptor()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	server, err := testsuite.StartDevServer(ctx, testsuite.DevServerOptions{
+		ClientOptions: &client.Options{
+			Interceptors: []interceptor.ClientInterceptor{tracingInterceptor},
+		},
+		ExistingPath: os.Getenv("TEMPORAL_CLI_PATH"),
+	})
+	if err != nil && os.Getenv("TEMPORAL_CLI_PATH") == "" {
+		// Most likely offline
+		t.Skipf("unable to download the Temporal CLI: %v", err)
+	}
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, server.Stop())
+	}()
+	c := server.Client()
+
+	_, err = c.OperatorService().CreateNexusEndpoint(ctx, &operatorservice.CreateNexusEndpointRequest{
+		Spec: &nexuspb.EndpointSpec{
+			Name: endpointName,
+			Target: &nexuspb.EndpointTarget{
+				Variant: &nexuspb.EndpointTarget_Worker_{
+					Worker: &nexuspb.EndpointTarget_Worker{
+						Namespace: "default",
+						TaskQueue: greeter.TASK_QUEUE_NAME,
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	// The greeter, as its worker registers it
+	svc := nexus.NewService(shared.ServiceName)
+	require.NoError(t, svc.Register(greeter.HelloWorldOperation))
+	greeterActivities, err := greeter.NewActivities()
+	require.NoError(t, err)
+
+	greeterWorker := worker.New(c, greeter.TASK_QUEUE_NAME, worker.Options{})
+	greeterWorker.RegisterNexusService(svc)
+	greeterWorker.RegisterWorkflow(greeter.HelloWorldWorkflow)
+	greeterWorker.RegisterActivity(greeterActivities)
+	require.NoError(t, greeterWorker.Start())
+	defer greeterWorker.Stop()
+
+	championsActivities, err := NewActivities()
+	require.NoError(t, err)
+
+	championsWorker := worker.New(c, TASK_QUEUE_NAME, worker.Options{})
+	championsWorker.RegisterWorkflow(SayHelloToChampionWorkflow)
+	championsWorker.RegisterActivity(championsActivities)
+	require.NoError(t, championsWorker.Start())
+	defer championsWorker.Stop()
+
+	run, err := c.ExecuteWorkflow(ctx, client.StartWorkflowOptions{TaskQueue: TASK_QUEUE_NAME}, SayHelloToChampionWorkflow)
+	require.NoError(t, err)
+
+	var result string
+	require.NoError(t, run.Get(ctx, &result))
+
+	name := shared.ServiceName + "/" + shared.HelloOperationName
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		spans := make(map[string]tracetest.SpanStub)
+		for _, s := range exporter.GetSpans() {
+			spans[s.Name] = s
+		}
+
+		start := spans["StartWorkflow:SayHelloToChampionWorkflow"]
+		startOperation := spans["StartNexusOperation:"+name]
+		handler := spans["RunStartNexusOperationHandler:"+name]
+		greeterWorkflow := spans["RunWorkflow:HelloWorldWorkflow"]
+		greeterActivity := spans["RunActivity:SayName"]
+
+		// The greeter's workflow and activity are in the champion's trace
+		require.True(c, start.SpanContext.IsValid())
+		for _, s := range []tracetest.SpanStub{startOperation, handler, greeterWorkflow, greeterActivity} {
+			assert.Equal(c, start.SpanContext.TraceID(), s.SpanContext.TraceID(), s.Name)
+		}
+		assert.Equal(c, startOperation.SpanContext.SpanID(), handler.Parent.SpanID())
+	}, 10*time.Second, 100*time.Millisecond)
+}
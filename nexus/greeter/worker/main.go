@@ -17,10 +17,15 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
 
+	"github.com/mrsimonemms/temporal-demos/codec"
+	"github.com/mrsimonemms/temporal-demos/metrics"
 	"github.com/mrsimonemms/temporal-demos/nexus/greeter"
 	"github.com/mrsimonemms/temporal-demos/nexus/shared"
+	"github.com/mrsimonemms/temporal-demos/tracing"
 	"github.com/nexus-rpc/sdk-go/nexus"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/contrib/envconfig"
@@ -28,14 +33,53 @@ import (
 )
 
 func main() {
+	// Payloads are encrypted before they're stored in the workflow history
+	dataConverter, err := codec.DataConverterFromEnv()
+	if err != nil {
+		log.Fatalln("Unable to create data converter", err)
+	}
+
+	// Traces are only exported if OTEL_TRACES_EXPORTER is set
+	shutdownTracing, err := tracing.Setup(context.Background(), "greeter-worker")
+	if err != nil {
+		log.Fatalln("Unable to configure tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	tracingInterceptor, err := tracing.NewInterceptor()
+	if err != nil {
+		log.Fatalln("Unable to create tracing interceptor", err)
+	}
+
+	// Prometheus scrapes the worker's metrics from /metrics
+	metricsHandler := metrics.NewHandler()
+	defer metricsHandler.Close()
+	metricsAddr := os.Getenv("METRICS_ADDRESS")
+	if metricsAddr == "" {
+		metricsAddr = ":9091"
+	}
+
+	go func() {
+		log.Println("Starting metrics server", "address", metricsAddr)
+		if err := metrics.ListenAndServe(metricsAddr, metricsHandler); err != nil {
+			log.Fatalln("Unable to start metrics server", err)
+		}
+	}()
+
+	opts := envconfig.MustLoadDefaultClientOptions()
+	opts.DataConverter = dataConverter
+	opts.Interceptors = append(opts.Interceptors, tracingInterceptor)
+	opts.MetricsHandler = metricsHandler
+
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(envconfig.MustLoadDefaultClientOptions())
+	c, err := client.Dial(opts)
 	if err != nil {
 		log.Fatalln("Unable to create Temporal client", err)
 	}
 	defer c.Close()
 
-	// Create the workflow with the task queue "hackathon"
+	// Create the workflow with the task queue "hackathon". The client's
+	// interceptors trace the worker's workflows, activities and Nexus operations too.
 	w := worker.New(c, greeter.TASK_QUEUE_NAME, worker.Options{})
 
 	// Register Nexus services
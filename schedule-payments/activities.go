@@ -21,6 +21,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/mrsimonemms/temporal-demos/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type activities struct {
@@ -64,10 +66,16 @@ func (a *activities) FindPaymentsForDay(ctx context.Context, startTime, endTime
 func (a *activities) SendPayment(ctx context.Context, payment PaymentData) (*SendPaymentResult, error) {
 	time.Sleep(time.Second * 2)
 
-	return &SendPaymentResult{
+	result := &SendPaymentResult{
 		AmountInPence: payment.AmountInPence,
 		TransactionID: uuid.New(),
-	}, nil
+	}
+	tracing.AddEvent(ctx, "PaymentSent",
+		attribute.Int("payment.amountInPence", result.AmountInPence),
+		attribute.String("payment.transactionId", result.TransactionID.String()),
+	)
+
+	return result, nil
 }
 
 func NewActivities(data []PaymentData) (*activities, error) {
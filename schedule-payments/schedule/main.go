@@ -22,8 +22,11 @@ import (
 	"os"
 	"time"
 
+	"github.com/mrsimonemms/temporal-demos/codec"
 	schedulepayments "github.com/mrsimonemms/temporal-demos/schedule-payments"
+	"github.com/mrsimonemms/temporal-demos/tracing"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
 )
 
 const scheduleID = "trigger_payments"
@@ -36,9 +39,29 @@ const scheduleID = "trigger_payments"
 // and deletion is out of the scope of this demo. You MUST manually delete it if
 // you are using a long-running Temporal service (eg, Temporal Cloud).
 func main() {
+	// Payloads are encrypted before they're stored in the workflow history
+	dataConverter, err := codec.DataConverterFromEnv()
+	if err != nil {
+		log.Fatalln("Unable to create data converter", err)
+	}
+
+	// Traces are only exported if OTEL_TRACES_EXPORTER is set
+	shutdownTracing, err := tracing.Setup(context.Background(), "schedule-payments-schedule")
+	if err != nil {
+		log.Fatalln("Unable to configure tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	tracingInterceptor, err := tracing.NewInterceptor()
+	if err != nil {
+		log.Fatalln("Unable to create tracing interceptor", err)
+	}
+
 	// The client is a heavyweight object that should be created once per process.
 	c, err := client.Dial(client.Options{
-		HostPort: os.Getenv("TEMPORAL_ADDRESS"),
+		DataConverter: dataConverter,
+		HostPort:      os.Getenv("TEMPORAL_ADDRESS"),
+		Interceptors:  []interceptor.ClientInterceptor{tracingInterceptor},
 	})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
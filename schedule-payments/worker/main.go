@@ -17,24 +17,66 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
+	"github.com/mrsimonemms/temporal-demos/codec"
+	"github.com/mrsimonemms/temporal-demos/metrics"
 	schedulepayments "github.com/mrsimonemms/temporal-demos/schedule-payments"
+	"github.com/mrsimonemms/temporal-demos/tracing"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/worker"
 )
 
 func main() {
+	// Payloads are encrypted before they're stored in the workflow history
+	dataConverter, err := codec.DataConverterFromEnv()
+	if err != nil {
+		log.Fatalln("Unable to create data converter", err)
+	}
+
+	// Traces are only exported if OTEL_TRACES_EXPORTER is set
+	shutdownTracing, err := tracing.Setup(context.Background(), "schedule-payments-worker")
+	if err != nil {
+		log.Fatalln("Unable to configure tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	tracingInterceptor, err := tracing.NewInterceptor()
+	if err != nil {
+		log.Fatalln("Unable to create tracing interceptor", err)
+	}
+
+	// Prometheus scrapes the worker's metrics from /metrics
+	metricsHandler := metrics.NewHandler()
+	defer metricsHandler.Close()
+	metricsAddr := os.Getenv("METRICS_ADDRESS")
+	if metricsAddr == "" {
+		metricsAddr = ":9093"
+	}
+
+	go func() {
+		log.Println("Starting metrics server", "address", metricsAddr)
+		if err := metrics.ListenAndServe(metricsAddr, metricsHandler); err != nil {
+			log.Fatalln("Unable to start metrics server", err)
+		}
+	}()
+
 	// The client and worker are heavyweight objects that should be created once per process.
 	c, err := client.Dial(client.Options{
-		HostPort: os.Getenv("TEMPORAL_ADDRESS"),
+		DataConverter:  dataConverter,
+		HostPort:       os.Getenv("TEMPORAL_ADDRESS"),
+		Interceptors:   []interceptor.ClientInterceptor{tracingInterceptor},
+		MetricsHandler: metricsHandler,
 	})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
 	defer c.Close()
 
+	// The client's interceptors trace the worker's workflows and activities too
 	w := worker.New(c, "payments", worker.Options{})
 
 	w.RegisterWorkflow(schedulepayments.FindDuePaymentsWorkflow)
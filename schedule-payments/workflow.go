@@ -20,6 +20,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/mrsimonemms/temporal-demos/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.temporal.io/sdk/workflow"
 )
 
@@ -49,6 +51,7 @@ func FindDuePaymentsWorkflow(ctx workflow.Context) error {
 	}
 
 	logger.Debug("Making payments", "count", len(payments))
+	tracing.WorkflowEvent(ctx, "PaymentsDue", attribute.Int("payments.count", len(payments)))
 
 	workflow.Sleep(ctx, time.Second*5)
 
@@ -82,11 +85,20 @@ func MakePayment(ctx workflow.Context, payment PaymentData) error {
 		StartToCloseTimeout: time.Second * 10,
 	})
 
+	// Ignored by the metrics handler while the workflow's replaying
+	metrics := workflow.GetMetricsHandler(ctx)
+
 	var a *activities
 	if err := workflow.ExecuteActivity(ctx, a.SendPayment, payment).Get(ctx, nil); err != nil {
+		metrics.Counter("payments_failed").Inc(1)
+		metrics.Counter("payments_failed_pence").Inc(int64(payment.AmountInPence))
+
 		logger.Error("Error sending payment", "error", err)
 		return fmt.Errorf("error getting payments due today: %w", err)
 	}
 
+	metrics.Counter("payments_sent").Inc(1)
+	metrics.Counter("payments_sent_pence").Inc(int64(payment.AmountInPence))
+
 	return nil
 }
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Middleware starts a span for each request, continuing the caller's trace if
+// there is one. Any workflows started with the request's context are part of
+// the same trace.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, s := otel.Tracer(instrumentationName).Start(ctx, r.Method,
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			),
+			trace.WithSpanKind(trace.SpanKindServer),
+		)
+		defer s.End()
+
+		rw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		r = r.WithContext(ctx)
+		next.ServeHTTP(rw, r)
+
+		// The pattern's only known once the request's been routed
+		if r.Pattern != "" {
+			s.SetName(r.Pattern)
+		}
+		s.SetAttributes(attribute.Int("http.response.status_code", rw.status))
+		if rw.status >= http.StatusInternalServerError {
+			s.SetStatus(codes.Error, http.StatusText(rw.status))
+		}
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the original writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// AddEvent records a domain event on the activity or request's span
+func AddEvent(ctx context.Context, name string, attrs ...attribute.KeyValue) {
+	trace.SpanFromContext(ctx).AddEvent(name, trace.WithAttributes(attrs...))
+}
+
+// WorkflowEvent records a domain event in a workflow as a span of its own. The
+// workflow's span isn't exported until the workflow finishes, so the events
+// would be lost if they were put on it.
+//
+// Nothing's recorded while the workflow's replaying, so each event is only
+// recorded once.
+func WorkflowEvent(ctx workflow.Context, name string, attrs ...attribute.KeyValue) {
+	if workflow.IsReplaying(ctx) {
+		return
+	}
+
+	parent, ok := ctx.Value(spanContextKey{}).(trace.Span)
+	if !ok {
+		// Tracing isn't enabled
+		return
+	}
+
+	now := workflow.Now(ctx)
+	_, s := otel.Tracer(instrumentationName).Start(trace.ContextWithSpan(context.Background(), parent), name,
+		trace.WithAttributes(attrs...),
+		trace.WithTimestamp(now),
+	)
+	s.End(trace.WithTimestamp(now))
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"go.temporal.io/sdk/contrib/opentelemetry"
+	"go.temporal.io/sdk/interceptor"
+)
+
+// The span is passed between clients, workflows, activities and Nexus
+// operations on this header. It's the default for every Temporal SDK.
+const HeaderKey = "_tracer-data"
+
+const instrumentationName = "github.com/mrsimonemms/temporal-demos/tracing"
+
+// Spans are put on the workflow context with this key
+type spanContextKey struct{}
+
+// NewInterceptor traces the client and any workers created from it. It uses
+// the global tracer provider and propagator, so call it after Setup.
+func NewInterceptor() (interceptor.Interceptor, error) {
+	return opentelemetry.NewTracingInterceptor(opentelemetry.TracerOptions{
+		HeaderKey:         HeaderKey,
+		SpanContextKey:    spanContextKey{},
+		SpanStarter:       startSpan,
+		TextMapPropagator: otel.GetTextMapPropagator(),
+		Tracer:            otel.Tracer(instrumentationName),
+	})
+}
+
+// Spans are named "Operation:Name". Workflows, activities and handlers are
+// run by the server, everything else is a call to it.
+func startSpan(ctx context.Context, t trace.Tracer, name string, opts ...trace.SpanStartOption) trace.Span {
+	kind := trace.SpanKindClient
+	if strings.HasPrefix(name, "Run") || strings.HasPrefix(name, "Handle") || strings.HasPrefix(name, "Validate") {
+		kind = trace.SpanKindServer
+	}
+
+	_, s := t.Start(ctx, name, append(opts, trace.WithSpanKind(kind))...)
+	return s
+}
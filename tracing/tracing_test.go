@@ -0,0 +1,160 @@
+/*
+ * Copyright 2025 Simon Emms <simon@simonemms.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+func newTestExporter(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+	})
+
+	return exporter
+}
+
+func spansByName(exporter *tracetest.InMemoryExporter) map[string]tracetest.SpanStub {
+	spans := make(map[string]tracetest.SpanStub)
+	for _, s := range exporter.GetSpans() {
+		spans[s.Name] = s
+	}
+	return spans
+}
+
+func chargeActivity(ctx context.Context, amount int) error {
+	AddEvent(ctx, "PaymentSent", attribute.Int("amountInPence", amount))
+	return nil
+}
+
+func paymentWorkflow(ctx workflow.Context, amount int) error {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+	})
+	return workflow.ExecuteActivity(ctx, chargeActivity, amount).Get(ctx, nil)
+}
+
+func parentWorkflow(ctx workflow.Context) error {
+	WorkflowEvent(ctx, "PaymentsDue", attribute.Int("count", 1))
+	return workflow.ExecuteChildWorkflow(ctx, paymentWorkflow, 1000).Get(ctx, nil)
+}
+
+func TestTracePropagatesToChildWorkflows(t *testing.T) {
+	exporter := newTestExporter(t)
+
+	tracingInterceptor, err := NewInterceptor()
+	assert.NoError(t, err)
+
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(worker.Options{
+		Interceptors: []interceptor.WorkerInterceptor{tracingInterceptor},
+	})
+	env.RegisterWorkflow(paymentWorkflow)
+	env.RegisterActivity(chargeActivity)
+
+	env.ExecuteWorkflow(parentWorkflow)
+	assert.True(t, env.IsWorkflowCompleted())
+	assert.NoError(t, env.GetWorkflowError())
+
+	spans := spansByName(exporter)
+	parent := spans["RunWorkflow:parentWorkflow"]
+	event := spans["PaymentsDue"]
+	startChild := spans["StartChildWorkflow:paymentWorkflow"]
+	child := spans["RunWorkflow:paymentWorkflow"]
+	activity := spans["RunActivity:chargeActivity"]
+
+	// Everything's in the one trace, each span the child of the one before
+	assert.True(t, parent.SpanContext.IsValid())
+	for _, s := range []tracetest.SpanStub{event, startChild, child, activity} {
+		assert.Equal(t, parent.SpanContext.TraceID(), s.SpanContext.TraceID(), s.Name)
+	}
+	assert.Equal(t, parent.SpanContext.SpanID(), event.Parent.SpanID())
+	assert.Equal(t, parent.SpanContext.SpanID(), startChild.Parent.SpanID())
+	assert.Equal(t, startChild.SpanContext.SpanID(), child.Parent.SpanID())
+
+	assert.Equal(t, trace.SpanKindServer, activity.SpanKind)
+	assert.Len(t, activity.Events, 1)
+	assert.Equal(t, "PaymentSent", activity.Events[0].Name)
+}
+
+func TestWorkflowEventWithoutTracing(t *testing.T) {
+	exporter := newTestExporter(t)
+
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(func(ctx workflow.Context) error {
+		WorkflowEvent(ctx, "PaymentsDue")
+		return nil
+	})
+	assert.NoError(t, env.GetWorkflowError())
+	assert.Empty(t, exporter.GetSpans())
+}
+
+func TestMiddleware(t *testing.T) {
+	exporter := newTestExporter(t)
+
+	var requestSpan trace.SpanContext
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		requestSpan = trace.SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	// Continues the caller's trace
+	caller := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/orders/1", nil)
+	otel.GetTextMapPropagator().Inject(trace.ContextWithSpanContext(context.Background(), caller), propagation.HeaderCarrier(req.Header))
+
+	rec := httptest.NewRecorder()
+	Middleware(mux).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "POST /orders/{id}", spans[0].Name)
+	assert.Equal(t, caller.TraceID(), spans[0].SpanContext.TraceID())
+	assert.Equal(t, caller.SpanID(), spans[0].Parent.SpanID())
+	assert.Equal(t, spans[0].SpanContext.SpanID(), requestSpan.SpanID())
+	assert.Contains(t, spans[0].Attributes, attribute.Int("http.response.status_code", http.StatusAccepted))
+}